@@ -0,0 +1,475 @@
+// Package audit checks a cluster snapshot for rack-awareness problems and
+// reports them as typed violations.
+//
+// Unlike the fixed len(racks) == 3 assertions in the integration tests, the
+// checks derive what "good" means from the snapshot itself: a partition is
+// expected to span min(RF, racks) racks.
+package audit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"kafka-rack-awareness/topology"
+)
+
+// Severity ranks how urgently a violation needs attention.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = []string{"info", "warning", "critical"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// MarshalText encodes the severity by name.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity name.
+func (s *Severity) UnmarshalText(text []byte) error {
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// ParseSeverity parses a severity name as produced by String.
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(n, name) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q", name)
+}
+
+// Kind identifies which check produced a violation.
+type Kind string
+
+const (
+	// KindSharedRack means replicas of a partition share a rack although
+	// enough racks exist to separate them.
+	KindSharedRack Kind = "replicas-share-rack"
+	// KindRFExceedsRacks means a topic's replication factor is larger than
+	// the number of racks, so some replicas must share a rack.
+	KindRFExceedsRacks Kind = "rf-exceeds-racks"
+	// KindLeaderRackSkew means a topic's leaders are unevenly spread over
+	// the racks that host its replicas.
+	KindLeaderRackSkew Kind = "leader-rack-skew"
+	// KindISRSingleRack means a partition's replicas span several racks but
+	// its in-sync replicas are confined to one rack or none.
+	KindISRSingleRack Kind = "isr-collapsed-to-one-rack"
+	// KindBrokerWithoutRack means a broker has no broker.rack configured.
+	KindBrokerWithoutRack Kind = "broker-without-rack"
+	// KindSharedDomain means replicas of a partition share a datacenter,
+	// zone or other level of a rack hierarchy although enough domains exist
+	// at that level to separate them.
+	KindSharedDomain Kind = "replicas-share-domain"
+	// KindRackOutsideHierarchy means a broker's rack name does not fit the
+	// configured rack hierarchy, so it is left out of domain checks.
+	KindRackOutsideHierarchy Kind = "rack-outside-hierarchy"
+)
+
+// NoPartition and NoBroker mark Violation fields that do not apply.
+const (
+	NoPartition int32 = -1
+	NoBroker    int32 = -1
+)
+
+// Violation is a single finding. Topic is empty for cluster-level findings
+// and Partition is NoPartition for topic-level ones.
+type Violation struct {
+	Kind      Kind     `json:"kind"`
+	Severity  Severity `json:"severity"`
+	Topic     string   `json:"topic,omitempty"`
+	Partition int32    `json:"partition"`
+	Broker    int32    `json:"broker"`
+	Message   string   `json:"message"`
+}
+
+func (v Violation) String() string {
+	var where string
+	switch {
+	case v.Topic == "" && v.Broker != NoBroker:
+		where = fmt.Sprintf("broker %d", v.Broker)
+	case v.Topic == "":
+		where = "cluster"
+	case v.Partition == NoPartition:
+		where = v.Topic
+	default:
+		where = fmt.Sprintf("%s/%d", v.Topic, v.Partition)
+	}
+	return fmt.Sprintf("[%s] %s %s: %s", v.Severity, where, v.Kind, v.Message)
+}
+
+// AuditReport is the result of auditing one snapshot.
+type AuditReport struct {
+	Brokers    int         `json:"brokers"`
+	Racks      int         `json:"racks"`
+	Topics     int         `json:"topics"`
+	Partitions int         `json:"partitions"`
+	Violations []Violation `json:"violations"`
+}
+
+// Count returns the number of violations at or above the given severity.
+func (r *AuditReport) Count(at Severity) int {
+	n := 0
+	for _, v := range r.Violations {
+		if v.Severity >= at {
+			n++
+		}
+	}
+	return n
+}
+
+// HasViolations reports whether any violation is at or above the given
+// severity.
+func (r *AuditReport) HasViolations(at Severity) bool {
+	return r.Count(at) > 0
+}
+
+// ForTopic returns the violations reported against a topic or its
+// partitions.
+func (r *AuditReport) ForTopic(topic string) []Violation {
+	var vs []Violation
+	for _, v := range r.Violations {
+		if v.Topic == topic {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+// OfKind returns the violations produced by one check.
+func (r *AuditReport) OfKind(kind Kind) []Violation {
+	var vs []Violation
+	for _, v := range r.Violations {
+		if v.Kind == kind {
+			vs = append(vs, v)
+		}
+	}
+	return vs
+}
+
+// Auditor runs the rack checks. The zero value is not usable; use New.
+type Auditor struct {
+	includeInternal   bool
+	topics            map[string]bool
+	leaderSkewAllowed int
+	hierarchy         *topology.Hierarchy
+}
+
+// Option configures an Auditor.
+type Option func(*Auditor)
+
+// WithInternalTopics includes internal topics such as __consumer_offsets.
+func WithInternalTopics() Option {
+	return func(a *Auditor) { a.includeInternal = true }
+}
+
+// WithTopics restricts the audit to the named topics.
+func WithTopics(topics ...string) Option {
+	return func(a *Auditor) {
+		for _, t := range topics {
+			a.topics[t] = true
+		}
+	}
+}
+
+// WithLeaderSkew sets how many more leaders the busiest rack may have than
+// the idlest one before a topic is reported. The default is 1.
+func WithLeaderSkew(allowed int) Option {
+	return func(a *Auditor) { a.leaderSkewAllowed = allowed }
+}
+
+// WithHierarchy also checks spread at every level of a rack hierarchy above
+// the rack itself, such as the datacenter in "dc1-rackA".
+func WithHierarchy(h *topology.Hierarchy) Option {
+	return func(a *Auditor) { a.hierarchy = h }
+}
+
+// New returns an auditor with the given options.
+func New(opts ...Option) *Auditor {
+	a := &Auditor{
+		topics:            make(map[string]bool),
+		leaderSkewAllowed: 1,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Audit checks the snapshot and returns a report with violations ordered by
+// topic, partition and kind.
+func (a *Auditor) Audit(c *topology.Cluster) *AuditReport {
+	racks := c.RackNames()
+	report := &AuditReport{
+		Brokers: len(c.Brokers),
+		Racks:   len(racks),
+	}
+
+	report.Violations = append(report.Violations, a.checkBrokers(c)...)
+	report.Violations = append(report.Violations, a.checkHierarchy(c)...)
+	levels := a.domainLevels(c)
+
+	for _, topic := range c.Topics {
+		if topic.Internal && !a.includeInternal {
+			continue
+		}
+		if len(a.topics) > 0 && !a.topics[topic.Name] {
+			continue
+		}
+		report.Topics++
+		report.Partitions += len(topic.Partitions)
+
+		if len(racks) == 0 {
+			continue
+		}
+		report.Violations = append(report.Violations, a.checkTopic(c, topic, len(racks), levels)...)
+	}
+
+	sort.SliceStable(report.Violations, func(i, j int) bool {
+		vi, vj := report.Violations[i], report.Violations[j]
+		if vi.Topic != vj.Topic {
+			return vi.Topic < vj.Topic
+		}
+		if vi.Partition != vj.Partition {
+			return vi.Partition < vj.Partition
+		}
+		return vi.Kind < vj.Kind
+	})
+	return report
+}
+
+func (a *Auditor) checkBrokers(c *topology.Cluster) []Violation {
+	missing := c.BrokersWithoutRack()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// A cluster where no broker has a rack is merely rack-unaware; mixing
+	// racked and rack-less brokers silently breaks placement guarantees.
+	severity := SeverityCritical
+	message := "broker.rack is not set while other brokers have one"
+	if len(missing) == len(c.Brokers) {
+		severity = SeverityWarning
+		message = "broker.rack is not set; rack awareness is disabled"
+	}
+
+	vs := make([]Violation, 0, len(missing))
+	for _, id := range missing {
+		vs = append(vs, Violation{
+			Kind:      KindBrokerWithoutRack,
+			Severity:  severity,
+			Partition: NoPartition,
+			Broker:    id,
+			Message:   message,
+		})
+	}
+	return vs
+}
+
+// domainLevel is one level of the rack hierarchy above the rack itself.
+type domainLevel struct {
+	name    string
+	brokers map[int32]string
+	count   int
+}
+
+// domainLevels resolves every broker's domain at each level above the rack.
+func (a *Auditor) domainLevels(c *topology.Cluster) []domainLevel {
+	if a.hierarchy == nil {
+		return nil
+	}
+	names := a.hierarchy.Levels()
+	levels := make([]domainLevel, 0, len(names)-1)
+	for i, name := range names[:len(names)-1] {
+		brokers := a.hierarchy.BrokerDomains(c, i)
+		distinct := make(map[string]bool)
+		for _, d := range brokers {
+			distinct[d] = true
+		}
+		levels = append(levels, domainLevel{name: name, brokers: brokers, count: len(distinct)})
+	}
+	return levels
+}
+
+func (a *Auditor) checkHierarchy(c *topology.Cluster) []Violation {
+	if a.hierarchy == nil {
+		return nil
+	}
+	var vs []Violation
+	for _, b := range c.Brokers {
+		if !b.HasRack() {
+			continue
+		}
+		if _, err := a.hierarchy.Parse(b.Rack); err != nil {
+			vs = append(vs, Violation{
+				Kind:      KindRackOutsideHierarchy,
+				Severity:  SeverityWarning,
+				Partition: NoPartition,
+				Broker:    b.ID,
+				Message:   err.Error(),
+			})
+		}
+	}
+	return vs
+}
+
+func (a *Auditor) checkTopic(c *topology.Cluster, topic topology.Topic, rackCount int, levels []domainLevel) []Violation {
+	var vs []Violation
+
+	rf := 0
+	for _, p := range topic.Partitions {
+		if len(p.Replicas) > rf {
+			rf = len(p.Replicas)
+		}
+	}
+	if rf > rackCount {
+		vs = append(vs, Violation{
+			Kind:      KindRFExceedsRacks,
+			Severity:  SeverityWarning,
+			Topic:     topic.Name,
+			Partition: NoPartition,
+			Broker:    NoBroker,
+			Message:   fmt.Sprintf("replication factor %d exceeds %d racks", rf, rackCount),
+		})
+	}
+
+	for _, p := range topic.Partitions {
+		vs = append(vs, a.checkDomains(p, levels)...)
+		vs = append(vs, a.checkPartition(c, p, rackCount)...)
+	}
+
+	if v, ok := a.checkLeaderSkew(c, topic); ok {
+		vs = append(vs, v)
+	}
+	return vs
+}
+
+func (a *Auditor) checkPartition(c *topology.Cluster, p topology.Partition, rackCount int) []Violation {
+	var vs []Violation
+
+	racks := c.RacksForPartition(p)
+	expected := len(p.Replicas)
+	if expected > rackCount {
+		expected = rackCount
+	}
+	if len(racks) < expected {
+		vs = append(vs, Violation{
+			Kind:      KindSharedRack,
+			Severity:  SeverityCritical,
+			Topic:     p.Topic,
+			Partition: p.ID,
+			Broker:    NoBroker,
+			Message: fmt.Sprintf("replicas %v span %d rack(s) %v, expected %d",
+				p.Replicas, len(racks), racks, expected),
+		})
+	}
+
+	isrRacks := c.RacksForISR(p)
+	if len(racks) > 1 && len(isrRacks) <= 1 {
+		vs = append(vs, Violation{
+			Kind:      KindISRSingleRack,
+			Severity:  SeverityCritical,
+			Topic:     p.Topic,
+			Partition: p.ID,
+			Broker:    NoBroker,
+			Message: fmt.Sprintf("ISR %v spans %v while replicas span %v",
+				p.ISR, isrRacks, racks),
+		})
+	}
+	return vs
+}
+
+// checkDomains checks that p spans min(RF, domains) domains at every level
+// above the rack, outermost first.
+func (a *Auditor) checkDomains(p topology.Partition, levels []domainLevel) []Violation {
+	var vs []Violation
+	for _, level := range levels {
+		domains := topology.RacksForBrokers(level.brokers, p.Replicas)
+		expected := min(len(p.Replicas), level.count)
+		if len(domains) >= expected {
+			continue
+		}
+		vs = append(vs, Violation{
+			Kind:      KindSharedDomain,
+			Severity:  SeverityCritical,
+			Topic:     p.Topic,
+			Partition: p.ID,
+			Broker:    NoBroker,
+			Message: fmt.Sprintf("replicas %v span %d %s(s) %v, expected %d",
+				p.Replicas, len(domains), level.name, domains, expected),
+		})
+	}
+	return vs
+}
+
+func (a *Auditor) checkLeaderSkew(c *topology.Cluster, topic topology.Topic) (Violation, bool) {
+	leaders := c.LeadersByRack(topic.Partitions)
+
+	// Only racks that host replicas of this topic can hold its leaders.
+	counts := make(map[string]int)
+	for rack := range c.ReplicasByRack(topic.Partitions) {
+		counts[rack] = leaders[rack]
+	}
+	if len(counts) < 2 {
+		return Violation{}, false
+	}
+
+	fewest, most := -1, 0
+	var busiest string
+	for _, rack := range sortedKeys(counts) {
+		n := counts[rack]
+		if fewest < 0 || n < fewest {
+			fewest = n
+		}
+		if n > most {
+			most, busiest = n, rack
+		}
+	}
+	if most-fewest <= a.leaderSkewAllowed {
+		return Violation{}, false
+	}
+	return Violation{
+		Kind:      KindLeaderRackSkew,
+		Severity:  SeverityWarning,
+		Topic:     topic.Name,
+		Partition: NoPartition,
+		Broker:    NoBroker,
+		Message: fmt.Sprintf("leaders per rack %v: %s leads %d more than the least loaded rack",
+			formatCounts(counts), busiest, most-fewest),
+	}, true
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatCounts(m map[string]int) string {
+	parts := make([]string, 0, len(m))
+	for _, k := range sortedKeys(m) {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, m[k]))
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
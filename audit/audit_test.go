@@ -0,0 +1,204 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cluster(brokers []topology.Broker, partitions ...topology.Partition) *topology.Cluster {
+	c := topology.NewCluster(brokers...)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: partitions})
+	return c
+}
+
+var threeRacks = []topology.Broker{
+	{ID: 1, Rack: "rack-a"},
+	{ID: 2, Rack: "rack-b"},
+	{ID: 3, Rack: "rack-c"},
+	{ID: 4, Rack: "rack-a"},
+}
+
+func TestAuditHealthyCluster(t *testing.T) {
+	c := cluster(threeRacks,
+		topology.Partition{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}},
+		topology.Partition{ID: 1, Leader: 2, Replicas: []int32{2, 3, 4}, ISR: []int32{2, 3, 4}},
+		topology.Partition{ID: 2, Leader: 3, Replicas: []int32{3, 4, 2}, ISR: []int32{3, 4, 2}},
+	)
+
+	report := New().Audit(c)
+
+	assert.Empty(t, report.Violations)
+	assert.Equal(t, 4, report.Brokers)
+	assert.Equal(t, 3, report.Racks)
+	assert.Equal(t, 1, report.Topics)
+	assert.Equal(t, 3, report.Partitions)
+}
+
+func TestAuditSharedRack(t *testing.T) {
+	c := cluster(threeRacks,
+		topology.Partition{ID: 0, Leader: 1, Replicas: []int32{1, 4, 2}, ISR: []int32{1, 4, 2}},
+	)
+
+	report := New().Audit(c)
+
+	shared := report.OfKind(KindSharedRack)
+	require.Len(t, shared, 1)
+	assert.Equal(t, SeverityCritical, shared[0].Severity)
+	assert.Equal(t, "orders", shared[0].Topic)
+	assert.Equal(t, int32(0), shared[0].Partition)
+}
+
+func TestAuditRFExceedsRacks(t *testing.T) {
+	brokers := []topology.Broker{
+		{ID: 1, Rack: "rack-a"}, {ID: 2, Rack: "rack-a"},
+		{ID: 3, Rack: "rack-b"}, {ID: 4, Rack: "rack-b"},
+	}
+	c := cluster(brokers,
+		topology.Partition{ID: 0, Leader: 1, Replicas: []int32{1, 3, 2}, ISR: []int32{1, 3, 2}},
+	)
+
+	report := New().Audit(c)
+
+	require.Len(t, report.Violations, 1)
+	v := report.Violations[0]
+	assert.Equal(t, KindRFExceedsRacks, v.Kind)
+	assert.Equal(t, SeverityWarning, v.Severity)
+	assert.Equal(t, NoPartition, v.Partition)
+}
+
+func TestAuditISRCollapsed(t *testing.T) {
+	c := cluster(threeRacks,
+		topology.Partition{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1}},
+		topology.Partition{ID: 1, Leader: -1, Replicas: []int32{2, 3, 1}, ISR: []int32{}},
+		topology.Partition{ID: 2, Leader: 3, Replicas: []int32{3, 1, 2}, ISR: []int32{3, 1}},
+	)
+
+	report := New().Audit(c)
+
+	collapsed := report.OfKind(KindISRSingleRack)
+	require.Len(t, collapsed, 2)
+	assert.Equal(t, int32(0), collapsed[0].Partition)
+	assert.Equal(t, int32(1), collapsed[1].Partition)
+}
+
+func TestAuditLeaderSkew(t *testing.T) {
+	var partitions []topology.Partition
+	for i := int32(0); i < 6; i++ {
+		partitions = append(partitions, topology.Partition{
+			ID: i, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3},
+		})
+	}
+	c := cluster(threeRacks, partitions...)
+
+	report := New().Audit(c)
+	skew := report.OfKind(KindLeaderRackSkew)
+	require.Len(t, skew, 1)
+	assert.Contains(t, skew[0].Message, "rack-a=6")
+
+	assert.Empty(t, New(WithLeaderSkew(6)).Audit(c).OfKind(KindLeaderRackSkew))
+}
+
+func TestAuditBrokersWithoutRack(t *testing.T) {
+	mixed := topology.NewCluster(topology.Broker{ID: 1, Rack: "rack-a"}, topology.Broker{ID: 2})
+	report := New().Audit(mixed)
+	require.Len(t, report.Violations, 1)
+	assert.Equal(t, KindBrokerWithoutRack, report.Violations[0].Kind)
+	assert.Equal(t, SeverityCritical, report.Violations[0].Severity)
+	assert.Equal(t, int32(2), report.Violations[0].Broker)
+
+	none := topology.NewCluster(topology.Broker{ID: 1}, topology.Broker{ID: 2})
+	none.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1}},
+	}})
+	report = New().Audit(none)
+	require.Len(t, report.Violations, 2)
+	for _, v := range report.Violations {
+		assert.Equal(t, KindBrokerWithoutRack, v.Kind)
+		assert.Equal(t, SeverityWarning, v.Severity)
+	}
+}
+
+func TestAuditTopicFilters(t *testing.T) {
+	c := topology.NewCluster(threeRacks...)
+	bad := []topology.Partition{{ID: 0, Leader: 1, Replicas: []int32{1, 4}, ISR: []int32{1, 4}}}
+	c.AddTopic(topology.Topic{Name: "__consumer_offsets", Internal: true, Partitions: bad})
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: bad})
+	c.AddTopic(topology.Topic{Name: "payments", Partitions: bad})
+
+	assert.Len(t, New().Audit(c).Violations, 2)
+	assert.Len(t, New(WithInternalTopics()).Audit(c).Violations, 3)
+
+	report := New(WithTopics("payments")).Audit(c)
+	require.Len(t, report.Violations, 1)
+	assert.Equal(t, "payments", report.Violations[0].Topic)
+	assert.Len(t, report.ForTopic("payments"), 1)
+	assert.Equal(t, 1, report.Topics)
+}
+
+func TestSeverityJSON(t *testing.T) {
+	v := Violation{Kind: KindSharedRack, Severity: SeverityCritical, Topic: "orders", Partition: 3, Broker: NoBroker}
+
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"severity":"critical"`)
+
+	var decoded Violation
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, v, decoded)
+
+	_, err = ParseSeverity("fatal")
+	assert.Error(t, err)
+	assert.Equal(t, "[critical] orders/3 replicas-share-rack: ", v.String())
+}
+
+func TestReportCounts(t *testing.T) {
+	report := &AuditReport{Violations: []Violation{
+		{Severity: SeverityInfo},
+		{Severity: SeverityWarning},
+		{Severity: SeverityCritical},
+	}}
+
+	assert.Equal(t, 3, report.Count(SeverityInfo))
+	assert.Equal(t, 1, report.Count(SeverityCritical))
+	assert.True(t, report.HasViolations(SeverityWarning))
+	assert.False(t, (&AuditReport{}).HasViolations(SeverityInfo))
+}
+
+func TestAuditHierarchy(t *testing.T) {
+	brokers := []topology.Broker{
+		{ID: 1, Rack: "dc1-a"},
+		{ID: 2, Rack: "dc1-b"},
+		{ID: 3, Rack: "dc2-a"},
+		{ID: 4, Rack: "dc2-b"},
+	}
+	c := cluster(brokers,
+		// Two racks, but both in dc1.
+		topology.Partition{ID: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1, 2}},
+		topology.Partition{ID: 1, Leader: 1, Replicas: []int32{1, 3}, ISR: []int32{1, 3}},
+		// RF 3 over two datacenters is as good as it gets.
+		topology.Partition{ID: 2, Leader: 2, Replicas: []int32{2, 3, 4}, ISR: []int32{2, 3, 4}},
+	)
+	h, err := topology.SplitHierarchy("-", "dc", "rack")
+	require.NoError(t, err)
+
+	// Flat racks see nothing wrong.
+	assert.Empty(t, New().Audit(c).OfKind(KindSharedRack))
+
+	report := New(WithHierarchy(h)).Audit(c)
+	shared := report.OfKind(KindSharedDomain)
+	require.Len(t, shared, 1)
+	assert.Equal(t, int32(0), shared[0].Partition)
+	assert.Equal(t, SeverityCritical, shared[0].Severity)
+	assert.Equal(t, "replicas [1 2] span 1 dc(s) [dc1], expected 2", shared[0].Message)
+
+	c.Brokers = append(c.Brokers, topology.Broker{ID: 5, Rack: "legacy"})
+	outside := New(WithHierarchy(h)).Audit(c).OfKind(KindRackOutsideHierarchy)
+	require.Len(t, outside, 1)
+	assert.Equal(t, int32(5), outside[0].Broker)
+	assert.Equal(t, SeverityWarning, outside[0].Severity)
+}
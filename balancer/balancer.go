@@ -0,0 +1,308 @@
+// Package balancer assigns consumer group partitions to members in the same
+// rack as one of the partition's replicas (KIP-881).
+//
+// With a rack-aware replica selector on the brokers (KIP-392), a consumer
+// that sets kgo.Rack fetches from a replica in its own rack, but only if the
+// partition has one there. The built-in balancers ignore that: a consumer in
+// rack-a is as likely to be given a partition whose replicas are all in
+// rack-b and rack-c as one it can read locally. The balancer here matches
+// partitions to members by rack first and keeps the rest of the assignment
+// cooperative and sticky (see "Consumer Group Locality" in
+// docs/APPLICATION_AWARENESS.md).
+package balancer
+
+import (
+	"sort"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// ProtocolName is the group protocol the balancer registers. Every member
+// of a group must use this balancer for it to be chosen.
+const ProtocolName = "rack-cooperative-sticky"
+
+// Balancer is a cooperative kgo.GroupBalancer that prefers giving each
+// member partitions with a replica in the member's rack.
+//
+// Each member sends its rack in its join metadata; the group leader reads
+// the partitions' replicas and the brokers' racks from its metadata cache.
+// Partitions go, in order of preference, to a member in a replica's rack
+// that has room, to the member that owned them last generation, and to the
+// least-loaded member, so members never differ by more than one partition
+// when they consume the same topics. When no member reports a rack or no
+// broker has one, the group is balanced exactly as by
+// kgo.CooperativeStickyBalancer.
+type Balancer struct {
+	rack   string
+	sticky kgo.GroupBalancer
+}
+
+// New returns a balancer for a member in rack. An empty rack falls back to
+// the rack given to kgo.Rack, which the client adds to the join metadata
+// itself.
+func New(rack string) *Balancer {
+	return &Balancer{rack: rack, sticky: kgo.CooperativeStickyBalancer()}
+}
+
+// ProtocolName implements kgo.GroupBalancer.
+func (*Balancer) ProtocolName() string { return ProtocolName }
+
+// IsCooperative implements kgo.GroupBalancer.
+func (*Balancer) IsCooperative() bool { return true }
+
+// JoinGroupMetadata implements kgo.GroupBalancer. The metadata is that of
+// the cooperative-sticky balancer with the member's rack added.
+func (b *Balancer) JoinGroupMetadata(interests []string, current map[string][]int32, generation int32) []byte {
+	data := b.sticky.JoinGroupMetadata(interests, current, generation)
+	if b.rack == "" {
+		return data
+	}
+	var meta kmsg.ConsumerMemberMetadata
+	if err := meta.ReadFrom(data); err != nil {
+		return data
+	}
+	meta.Version = max(meta.Version, 3)
+	meta.Rack = &b.rack
+	return meta.AppendTo(nil)
+}
+
+// ParseSyncAssignment implements kgo.GroupBalancer.
+func (*Balancer) ParseSyncAssignment(assignment []byte) (map[string][]int32, error) {
+	return kgo.ParseConsumerSyncAssignment(assignment)
+}
+
+// MemberBalancer implements kgo.GroupBalancer.
+func (b *Balancer) MemberBalancer(members []kmsg.JoinGroupResponseMember) (kgo.GroupMemberBalancer, map[string]struct{}, error) {
+	cb, err := kgo.NewConsumerBalancer(b, members)
+	return cb, cb.MemberTopics(), err
+}
+
+// Balance implements kgo.ConsumerBalancerBalance.
+func (b *Balancer) Balance(cb *kgo.ConsumerBalancer, topics map[string]int32) kgo.IntoSyncAssignment {
+	racks := replicaRacks(cb, topics)
+	var ms []*member
+	anyRack := false
+	cb.EachMember(func(m *kmsg.JoinGroupResponseMember, meta *kmsg.ConsumerMemberMetadata) {
+		mb := &member{id: m, topics: make(map[string]bool, len(meta.Topics))}
+		if meta.Rack != nil && *meta.Rack != "" {
+			mb.rack = *meta.Rack
+			anyRack = true
+		}
+		for _, t := range meta.Topics {
+			mb.topics[t] = true
+		}
+		ms = append(ms, mb)
+	})
+	if !anyRack || racks == nil {
+		return b.sticky.(kgo.ConsumerBalancerBalance).Balance(cb, topics)
+	}
+
+	plan := cb.NewPlan()
+	for _, a := range assign(ms, topics, racks, owners(cb)) {
+		plan.AddPartition(a.member.id, a.topic, a.partition)
+	}
+	plan.AdjustCooperative(cb)
+	return plan
+}
+
+type member struct {
+	id     *kmsg.JoinGroupResponseMember
+	rack   string
+	topics map[string]bool
+	count  int
+}
+
+type assignment struct {
+	member    *member
+	topic     string
+	partition int32
+}
+
+// replicaRacks returns topic => partition => racks of its replicas. It
+// falls back to the leader racks kgo provides when the balance info has no
+// replicas, and returns nil if no partition has a rack.
+func replicaRacks(cb *kgo.ConsumerBalancer, topics map[string]int32) map[string][]map[string]bool {
+	out := make(map[string][]map[string]bool, len(topics))
+	found := false
+	add := func(topic string, partition int32, rack string) {
+		if rack == "" || partition < 0 || partition >= topics[topic] {
+			return
+		}
+		if out[topic] == nil {
+			out[topic] = make([]map[string]bool, topics[topic])
+		}
+		if out[topic][partition] == nil {
+			out[topic][partition] = make(map[string]bool)
+		}
+		out[topic][partition][rack] = true
+		found = true
+	}
+
+	info := cb.Info()
+	brokers := info.Brokers()
+	for topic, md := range info.Topics() {
+		if _, ok := topics[topic]; !ok {
+			continue
+		}
+		for _, p := range md.Partitions {
+			for _, id := range p.Replicas {
+				if b, ok := brokers[id]; ok && b.Rack != nil {
+					add(topic, p.Partition, *b.Rack)
+				}
+			}
+		}
+	}
+	if !found {
+		for topic, leaders := range cb.PartitionRacks() {
+			for p, rack := range leaders {
+				add(topic, int32(p), rack)
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	return out
+}
+
+// owners returns topic => partition => the member that owned it in the
+// latest generation that claims it.
+func owners(cb *kgo.ConsumerBalancer) map[string]map[int32]*kmsg.JoinGroupResponseMember {
+	out := make(map[string]map[int32]*kmsg.JoinGroupResponseMember)
+	gens := make(map[string]map[int32]int32)
+	cb.EachMember(func(m *kmsg.JoinGroupResponseMember, meta *kmsg.ConsumerMemberMetadata) {
+		for _, owned := range meta.OwnedPartitions {
+			if out[owned.Topic] == nil {
+				out[owned.Topic] = make(map[int32]*kmsg.JoinGroupResponseMember)
+				gens[owned.Topic] = make(map[int32]int32)
+			}
+			for _, p := range owned.Partitions {
+				if gen, ok := gens[owned.Topic][p]; !ok || meta.Generation > gen {
+					out[owned.Topic][p] = m
+					gens[owned.Topic][p] = meta.Generation
+				}
+			}
+		}
+	})
+	return out
+}
+
+// assign places every partition of topics on a subscribed member. Each
+// member may take floor(partitions/members) partitions, and as many members
+// as the remainder one more; a partition only goes past that limit when
+// every member subscribed to it is full, which happens only when members
+// consume different topics.
+func assign(ms []*member, topics map[string]int32, racks map[string][]map[string]bool, owned map[string]map[int32]*kmsg.JoinGroupResponseMember) []assignment {
+	names := make([]string, 0, len(topics))
+	total := 0
+	for t, n := range topics {
+		subscribed := false
+		for _, m := range ms {
+			subscribed = subscribed || m.topics[t]
+		}
+		if subscribed {
+			names = append(names, t)
+			total += int(n)
+		}
+	}
+	sort.Strings(names)
+	if len(ms) == 0 {
+		return nil
+	}
+	floor, extra := total/len(ms), total%len(ms)
+	room := func(m *member) bool { return m.count < floor || m.count == floor && extra > 0 }
+	take := func(m *member) {
+		if m.count == floor {
+			extra--
+		}
+		m.count++
+	}
+
+	type tp struct {
+		topic     string
+		partition int32
+	}
+	var pending []tp
+	for _, t := range names {
+		for p := int32(0); p < topics[t]; p++ {
+			pending = append(pending, tp{t, p})
+		}
+	}
+	var out []assignment
+	// pass assigns each pending partition to the member choose returns, if
+	// any, and keeps the rest pending.
+	pass := func(choose func(tp) *member) {
+		rest := pending[:0]
+		for _, x := range pending {
+			if m := choose(x); m != nil {
+				take(m)
+				out = append(out, assignment{member: m, topic: x.topic, partition: x.partition})
+			} else {
+				rest = append(rest, x)
+			}
+		}
+		pending = rest
+	}
+	local := func(m *member, x tp) bool {
+		rs := racks[x.topic]
+		return rs != nil && rs[x.partition][m.rack]
+	}
+	owner := func(x tp) *member {
+		id := owned[x.topic][x.partition]
+		for _, m := range ms {
+			if m.id == id && m.topics[x.topic] {
+				return m
+			}
+		}
+		return nil
+	}
+	least := func(x tp, ok func(*member) bool) *member {
+		var best *member
+		for _, m := range ms {
+			if m.topics[x.topic] && ok(m) && (best == nil || m.count < best.count) {
+				best = m
+			}
+		}
+		return best
+	}
+
+	// Local owners keep their partitions, then local members take what is
+	// left, then owners in other racks, then whoever has room. Partitions
+	// that fewer members can read locally are placed first, so they are not
+	// crowded out by partitions every member could take.
+	pass(func(x tp) *member {
+		if m := owner(x); m != nil && local(m, x) && room(m) {
+			return m
+		}
+		return nil
+	})
+	choices := func(x tp) int {
+		n := 0
+		for _, m := range ms {
+			if m.topics[x.topic] && local(m, x) {
+				n++
+			}
+		}
+		return n
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return choices(pending[i]) < choices(pending[j]) })
+	pass(func(x tp) *member {
+		return least(x, func(m *member) bool { return local(m, x) && room(m) })
+	})
+	pass(func(x tp) *member {
+		if m := owner(x); m != nil && room(m) {
+			return m
+		}
+		return nil
+	})
+	pass(func(x tp) *member { return least(x, room) })
+	pass(func(x tp) *member { return least(x, func(*member) bool { return true }) })
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].topic != out[j].topic {
+			return out[i].topic < out[j].topic
+		}
+		return out[i].partition < out[j].partition
+	})
+	return out
+}
@@ -0,0 +1,238 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"kafka-rack-awareness/kafkatest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// joined is a group member for balancing: its rack and what it owned in
+// the previous generation.
+type joined struct {
+	id    string
+	rack  string
+	owned map[string][]int32
+}
+
+// balance runs the balancer's leader side for members consuming topic,
+// whose partitions live on the given replicas. Broker IDs index racks.
+func balance(t *testing.T, members []joined, topic string, replicas [][]int32, racks []string) map[string][]int32 {
+	t.Helper()
+	var jm []kmsg.JoinGroupResponseMember
+	for _, m := range members {
+		jm = append(jm, kmsg.JoinGroupResponseMember{
+			MemberID:         m.id,
+			ProtocolMetadata: New(m.rack).JoinGroupMetadata([]string{topic}, m.owned, 1),
+		})
+	}
+	mb, topics, err := New("").MemberBalancer(jm)
+	require.NoError(t, err)
+	require.Contains(t, topics, topic)
+
+	brokers := make(map[int32]kgo.BrokerMetadata)
+	for id, rack := range racks {
+		brokers[int32(id)] = kgo.BrokerMetadata{NodeID: int32(id), Rack: &rack}
+	}
+	md := kgo.TopicMetadata{Topic: topic}
+	for p, rs := range replicas {
+		md.Partitions = append(md.Partitions, kgo.PartitionMetadata{Topic: topic, Partition: int32(p), Leader: rs[0], Replicas: rs})
+	}
+	cb := mb.(*kgo.ConsumerBalancer)
+	cb.SetBalanceInfo(kgo.BalanceInfo{
+		Topics:  func() map[string]kgo.TopicMetadata { return map[string]kgo.TopicMetadata{topic: md} },
+		Brokers: func() map[int32]kgo.BrokerMetadata { return brokers },
+	})
+
+	into, err := cb.BalanceOrError(map[string]int32{topic: int32(len(replicas))})
+	require.NoError(t, err)
+	out := make(map[string][]int32)
+	for id, ts := range into.(*kgo.BalancePlan).AsMemberIDMap() {
+		ps := append([]int32(nil), ts[topic]...)
+		sort.Slice(ps, func(i, j int) bool { return ps[i] < ps[j] })
+		out[id] = ps
+	}
+	return out
+}
+
+func TestAssignsPartitionsToMembersInReplicaRack(t *testing.T) {
+	// Six single-replica partitions, two per rack.
+	replicas := [][]int32{{0}, {1}, {2}, {0}, {1}, {2}}
+	got := balance(t, []joined{
+		{id: "a", rack: "rack-a"},
+		{id: "b", rack: "rack-b"},
+		{id: "c", rack: "rack-c"},
+	}, "orders", replicas, []string{"rack-a", "rack-b", "rack-c"})
+
+	assert.Equal(t, map[string][]int32{
+		"a": {0, 3},
+		"b": {1, 4},
+		"c": {2, 5},
+	}, got)
+}
+
+func TestUsesFollowersNotJustLeaders(t *testing.T) {
+	// Every partition is led from rack-a, but each has a follower in
+	// rack-b or rack-c.
+	replicas := [][]int32{{0, 1}, {0, 2}, {0, 1}, {0, 2}}
+	got := balance(t, []joined{
+		{id: "b", rack: "rack-b"},
+		{id: "c", rack: "rack-c"},
+	}, "orders", replicas, []string{"rack-a", "rack-b", "rack-c"})
+
+	assert.Equal(t, map[string][]int32{"b": {0, 2}, "c": {1, 3}}, got)
+}
+
+func TestPlacesScarcePartitionsFirst(t *testing.T) {
+	// Partitions 0 and 1 can be read from either rack, 2 and 3 only from
+	// rack-a, so "a" must take 2 and 3 for both members to read locally.
+	replicas := [][]int32{{0, 1}, {0, 1}, {0}, {0}}
+	got := balance(t, []joined{
+		{id: "a", rack: "rack-a"},
+		{id: "b", rack: "rack-b"},
+	}, "orders", replicas, []string{"rack-a", "rack-b"})
+
+	assert.Equal(t, map[string][]int32{"a": {2, 3}, "b": {0, 1}}, got)
+}
+
+func TestStaysBalancedWhenOneRackHoldsEverything(t *testing.T) {
+	replicas := [][]int32{{0}, {0}, {0}, {0}, {0}}
+	got := balance(t, []joined{
+		{id: "a", rack: "rack-a"},
+		{id: "b", rack: "rack-b"},
+	}, "orders", replicas, []string{"rack-a", "rack-b"})
+
+	assert.Len(t, got["a"], 3)
+	assert.Len(t, got["b"], 2)
+}
+
+func TestRemoteOwnerKeepsPartitionsNoLocalMemberHasRoomFor(t *testing.T) {
+	// Both partitions live in rack-a; "a" has room for only one, so "b"
+	// keeps the one it owned instead of being handed the other.
+	replicas := [][]int32{{0}, {0}}
+	got := balance(t, []joined{
+		{id: "a", rack: "rack-a"},
+		{id: "b", rack: "rack-b", owned: map[string][]int32{"orders": {1}}},
+	}, "orders", replicas, []string{"rack-a"})
+
+	assert.Equal(t, map[string][]int32{"a": {0}, "b": {1}}, got)
+}
+
+func TestRevokesBeforeMovingCooperatively(t *testing.T) {
+	// "b" owns partition 0, which belongs in rack-a: the first rebalance
+	// only revokes it, so nobody consumes it twice.
+	replicas := [][]int32{{0}, {1}}
+	got := balance(t, []joined{
+		{id: "a", rack: "rack-a", owned: map[string][]int32{"orders": {1}}},
+		{id: "b", rack: "rack-b", owned: map[string][]int32{"orders": {0}}},
+	}, "orders", replicas, []string{"rack-a", "rack-b"})
+
+	assert.Empty(t, got["a"])
+	assert.Empty(t, got["b"])
+}
+
+func TestFallsBackToCooperativeStickyWithoutRacks(t *testing.T) {
+	replicas := [][]int32{{0}, {1}, {0}, {1}}
+	got := balance(t, []joined{
+		{id: "a", owned: map[string][]int32{"orders": {0, 1}}},
+		{id: "b", owned: map[string][]int32{"orders": {2, 3}}},
+	}, "orders", replicas, []string{"rack-a", "rack-b"})
+
+	assert.Equal(t, map[string][]int32{"a": {0, 1}, "b": {2, 3}}, got)
+}
+
+func TestJoinGroupMetadataCarriesRack(t *testing.T) {
+	var meta kmsg.ConsumerMemberMetadata
+	require.NoError(t, meta.ReadFrom(New("rack-a").JoinGroupMetadata([]string{"orders"}, map[string][]int32{"orders": {1}}, 4)))
+	require.NotNil(t, meta.Rack)
+	assert.Equal(t, "rack-a", *meta.Rack)
+	assert.Equal(t, []string{"orders"}, meta.Topics)
+	require.Len(t, meta.OwnedPartitions, 1)
+	assert.Equal(t, []int32{1}, meta.OwnedPartitions[0].Partitions)
+}
+
+func TestGroupConsumesFromOwnRack(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	admin, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	t.Cleanup(admin.Close)
+	// Two partitions led from each rack.
+	require.NoError(t, kafkatest.CreateTopic(ctx, admin, "orders", nil, []int32{0}, []int32{1}, []int32{2}, []int32{0}, []int32{1}, []int32{2}))
+
+	var mu sync.Mutex
+	assigned := make(map[string]map[int32]bool)
+	racks := []string{"rack-a", "rack-b", "rack-c"}
+	for _, rack := range racks {
+		cl, err := kgo.NewClient(
+			kgo.SeedBrokers(c.Addrs()...),
+			kgo.ConsumerGroup("rack-group"),
+			kgo.ConsumeTopics("orders"),
+			kgo.Balancers(New(rack)),
+			kgo.OnPartitionsAssigned(func(_ context.Context, _ *kgo.Client, m map[string][]int32) {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, p := range m["orders"] {
+					assigned[rack][p] = true
+				}
+			}),
+			kgo.OnPartitionsRevoked(func(_ context.Context, _ *kgo.Client, m map[string][]int32) {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, p := range m["orders"] {
+					delete(assigned[rack], p)
+				}
+			}),
+		)
+		require.NoError(t, err)
+		assigned[rack] = make(map[int32]bool)
+		go func() {
+			for ctx.Err() == nil && !cl.PollFetches(ctx).IsClientClosed() {
+			}
+		}()
+		t.Cleanup(cl.Close)
+	}
+
+	brokerRacks := c.Racks()
+	var md kmsg.MetadataResponse
+	require.Eventually(t, func() bool {
+		req := kmsg.NewPtrMetadataRequest()
+		rt := kmsg.NewMetadataRequestTopic()
+		rt.Topic = kmsg.StringPtr("orders")
+		req.Topics = append(req.Topics, rt)
+		resp, err := req.RequestWith(ctx, admin)
+		if err != nil {
+			return false
+		}
+		md = *resp
+
+		mu.Lock()
+		defer mu.Unlock()
+		total := 0
+		for _, rack := range racks {
+			if len(assigned[rack]) != 2 {
+				return false
+			}
+			total += len(assigned[rack])
+		}
+		return total == 6
+	}, 25*time.Second, 100*time.Millisecond, "every member should end up with two partitions")
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range md.Topics[0].Partitions {
+		rack := brokerRacks[p.Replicas[0]]
+		assert.True(t, assigned[rack][p.Partition], fmt.Sprintf("partition %d lives in %s", p.Partition, rack))
+	}
+}
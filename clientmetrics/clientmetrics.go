@@ -0,0 +1,161 @@
+// Package clientmetrics measures a franz-go client's produce and fetch
+// traffic by the rack it crosses, as Prometheus metrics.
+//
+// docs/APPLICATION_AWARENESS.md says setting client.rack lowers latency and
+// cross-rack traffic. A Metrics, installed with kgo.WithHooks, puts numbers
+// on that: every produce and fetch request is recorded with the client's
+// rack and the rack of the broker that answered it, so dashboards can show
+// request latency, bytes and errors per rack pair and how much of the
+// traffic crosses racks (see "Measuring Client Traffic by Rack" there).
+package clientmetrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const namespace = "kafka_rack_client"
+
+// labels are the labels of every metric. request is "produce" or "fetch";
+// cross_rack is "true" when both racks are known and differ, "false" when
+// they are known and equal, and "unknown" otherwise.
+var labels = []string{"request", "client_rack", "broker_rack", "cross_rack"}
+
+// requests are the request keys that are measured.
+var requests = map[int16]string{
+	0: "produce",
+	1: "fetch",
+}
+
+// config holds the settings of a Metrics.
+type config struct {
+	latencyBuckets []float64
+	byteBuckets    []float64
+	constLabels    prometheus.Labels
+}
+
+// Option configures a Metrics.
+type Option func(*config)
+
+// WithLatencyBuckets sets the buckets, in seconds, of the request duration
+// histogram. The default is prometheus.DefBuckets.
+func WithLatencyBuckets(buckets ...float64) Option {
+	return func(c *config) { c.latencyBuckets = buckets }
+}
+
+// WithByteBuckets sets the buckets of the request and response size
+// histograms. The default is powers of four from 256 bytes to 16MiB.
+func WithByteBuckets(buckets ...float64) Option {
+	return func(c *config) { c.byteBuckets = buckets }
+}
+
+// WithConstLabels adds labels to every metric, such as the client ID, for
+// processes that register more than one client.
+func WithConstLabels(l prometheus.Labels) Option {
+	return func(c *config) { c.constLabels = l }
+}
+
+// Metrics records produce and fetch requests by client and broker rack.
+// It implements kgo.HookBrokerE2E and prometheus.Collector.
+type Metrics struct {
+	rack string
+
+	duration *prometheus.HistogramVec
+	sent     *prometheus.HistogramVec
+	received *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+var (
+	_ kgo.HookBrokerE2E    = (*Metrics)(nil)
+	_ prometheus.Collector = (*Metrics)(nil)
+)
+
+// New returns metrics for a client in rack, the rack given to kgo.Rack. An
+// empty rack is reported as such, and all its traffic as cross_rack
+// "unknown". Register the result with a prometheus.Registerer and pass it
+// to kgo.WithHooks.
+func New(rack string, opts ...Option) *Metrics {
+	cfg := config{
+		latencyBuckets: prometheus.DefBuckets,
+		byteBuckets:    prometheus.ExponentialBuckets(256, 4, 9),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Metrics{
+		rack: rack,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "request_duration_seconds",
+			Help:        "Time from writing a produce or fetch request to reading its response. Fetches include the time the broker waits for data, up to fetch.max.wait.ms.",
+			Buckets:     cfg.latencyBuckets,
+			ConstLabels: cfg.constLabels,
+		}, labels),
+		sent: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "request_bytes",
+			Help:        "Size of produce and fetch requests written to brokers.",
+			Buckets:     cfg.byteBuckets,
+			ConstLabels: cfg.constLabels,
+		}, labels),
+		received: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Name:        "response_bytes",
+			Help:        "Size of produce and fetch responses read from brokers.",
+			Buckets:     cfg.byteBuckets,
+			ConstLabels: cfg.constLabels,
+		}, labels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        "request_errors_total",
+			Help:        "Produce and fetch requests that failed to be written or whose response failed to be read.",
+			ConstLabels: cfg.constLabels,
+		}, labels),
+	}
+}
+
+// OnBrokerE2E implements kgo.HookBrokerE2E. Requests other than produce and
+// fetch are ignored. A failed request counts as an error; its latency and
+// sizes are not observed, since they describe a partial exchange.
+func (m *Metrics) OnBrokerE2E(meta kgo.BrokerMetadata, key int16, e2e kgo.BrokerE2E) {
+	request, ok := requests[key]
+	if !ok {
+		return
+	}
+	var brokerRack string
+	if meta.Rack != nil {
+		brokerRack = *meta.Rack
+	}
+	cross := "unknown"
+	if m.rack != "" && brokerRack != "" {
+		cross = strconv.FormatBool(m.rack != brokerRack)
+	}
+	l := prometheus.Labels{"request": request, "client_rack": m.rack, "broker_rack": brokerRack, "cross_rack": cross}
+
+	if e2e.Err() != nil {
+		m.errors.With(l).Inc()
+		return
+	}
+	m.duration.With(l).Observe(e2e.DurationE2E().Seconds())
+	m.sent.With(l).Observe(float64(e2e.BytesWritten))
+	m.received.With(l).Observe(float64(e2e.BytesRead))
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.duration.Describe(ch)
+	m.sent.Describe(ch)
+	m.received.Describe(ch)
+	m.errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.duration.Collect(ch)
+	m.sent.Collect(ch)
+	m.received.Collect(ch)
+	m.errors.Collect(ch)
+}
@@ -0,0 +1,139 @@
+package clientmetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-rack-awareness/kafkatest"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func broker(id int32, rack string) kgo.BrokerMetadata {
+	m := kgo.BrokerMetadata{NodeID: id}
+	if rack != "" {
+		m.Rack = &rack
+	}
+	return m
+}
+
+func TestRecordsRequestsByRackPair(t *testing.T) {
+	m := New("rack-a", WithLatencyBuckets(0.01, 0.1), WithByteBuckets(1000))
+	m.OnBrokerE2E(broker(1, "rack-a"), 0, kgo.BrokerE2E{BytesWritten: 500, BytesRead: 50, TimeToWrite: time.Millisecond, ReadWait: 4 * time.Millisecond})
+	m.OnBrokerE2E(broker(2, "rack-b"), 0, kgo.BrokerE2E{BytesWritten: 2000, BytesRead: 50, ReadWait: 50 * time.Millisecond})
+	m.OnBrokerE2E(broker(2, "rack-b"), 1, kgo.BrokerE2E{WriteErr: errors.New("broken pipe")})
+	m.OnBrokerE2E(broker(3, ""), 1, kgo.BrokerE2E{BytesWritten: 100, BytesRead: 800})
+	// Metadata requests are not measured.
+	m.OnBrokerE2E(broker(2, "rack-b"), 3, kgo.BrokerE2E{BytesWritten: 100})
+
+	err := testutil.CollectAndCompare(m, strings.NewReader(`
+# HELP kafka_rack_client_request_bytes Size of produce and fetch requests written to brokers.
+# TYPE kafka_rack_client_request_bytes histogram
+kafka_rack_client_request_bytes_bucket{broker_rack="",client_rack="rack-a",cross_rack="unknown",request="fetch",le="1000"} 1
+kafka_rack_client_request_bytes_bucket{broker_rack="",client_rack="rack-a",cross_rack="unknown",request="fetch",le="+Inf"} 1
+kafka_rack_client_request_bytes_sum{broker_rack="",client_rack="rack-a",cross_rack="unknown",request="fetch"} 100
+kafka_rack_client_request_bytes_count{broker_rack="",client_rack="rack-a",cross_rack="unknown",request="fetch"} 1
+kafka_rack_client_request_bytes_bucket{broker_rack="rack-a",client_rack="rack-a",cross_rack="false",request="produce",le="1000"} 1
+kafka_rack_client_request_bytes_bucket{broker_rack="rack-a",client_rack="rack-a",cross_rack="false",request="produce",le="+Inf"} 1
+kafka_rack_client_request_bytes_sum{broker_rack="rack-a",client_rack="rack-a",cross_rack="false",request="produce"} 500
+kafka_rack_client_request_bytes_count{broker_rack="rack-a",client_rack="rack-a",cross_rack="false",request="produce"} 1
+kafka_rack_client_request_bytes_bucket{broker_rack="rack-b",client_rack="rack-a",cross_rack="true",request="produce",le="1000"} 0
+kafka_rack_client_request_bytes_bucket{broker_rack="rack-b",client_rack="rack-a",cross_rack="true",request="produce",le="+Inf"} 1
+kafka_rack_client_request_bytes_sum{broker_rack="rack-b",client_rack="rack-a",cross_rack="true",request="produce"} 2000
+kafka_rack_client_request_bytes_count{broker_rack="rack-b",client_rack="rack-a",cross_rack="true",request="produce"} 1
+# HELP kafka_rack_client_request_errors_total Produce and fetch requests that failed to be written or whose response failed to be read.
+# TYPE kafka_rack_client_request_errors_total counter
+kafka_rack_client_request_errors_total{broker_rack="rack-b",client_rack="rack-a",cross_rack="true",request="fetch"} 1
+`), "kafka_rack_client_request_bytes", "kafka_rack_client_request_errors_total")
+	require.NoError(t, err)
+
+	err = testutil.CollectAndCompare(m, strings.NewReader(`
+# HELP kafka_rack_client_request_duration_seconds Time from writing a produce or fetch request to reading its response. Fetches include the time the broker waits for data, up to fetch.max.wait.ms.
+# TYPE kafka_rack_client_request_duration_seconds histogram
+kafka_rack_client_request_duration_seconds_bucket{broker_rack="",client_rack="rack-a",cross_rack="unknown",request="fetch",le="0.01"} 1
+kafka_rack_client_request_duration_seconds_bucket{broker_rack="",client_rack="rack-a",cross_rack="unknown",request="fetch",le="0.1"} 1
+kafka_rack_client_request_duration_seconds_bucket{broker_rack="",client_rack="rack-a",cross_rack="unknown",request="fetch",le="+Inf"} 1
+kafka_rack_client_request_duration_seconds_sum{broker_rack="",client_rack="rack-a",cross_rack="unknown",request="fetch"} 0
+kafka_rack_client_request_duration_seconds_count{broker_rack="",client_rack="rack-a",cross_rack="unknown",request="fetch"} 1
+kafka_rack_client_request_duration_seconds_bucket{broker_rack="rack-a",client_rack="rack-a",cross_rack="false",request="produce",le="0.01"} 1
+kafka_rack_client_request_duration_seconds_bucket{broker_rack="rack-a",client_rack="rack-a",cross_rack="false",request="produce",le="0.1"} 1
+kafka_rack_client_request_duration_seconds_bucket{broker_rack="rack-a",client_rack="rack-a",cross_rack="false",request="produce",le="+Inf"} 1
+kafka_rack_client_request_duration_seconds_sum{broker_rack="rack-a",client_rack="rack-a",cross_rack="false",request="produce"} 0.005
+kafka_rack_client_request_duration_seconds_count{broker_rack="rack-a",client_rack="rack-a",cross_rack="false",request="produce"} 1
+kafka_rack_client_request_duration_seconds_bucket{broker_rack="rack-b",client_rack="rack-a",cross_rack="true",request="produce",le="0.01"} 0
+kafka_rack_client_request_duration_seconds_bucket{broker_rack="rack-b",client_rack="rack-a",cross_rack="true",request="produce",le="0.1"} 1
+kafka_rack_client_request_duration_seconds_bucket{broker_rack="rack-b",client_rack="rack-a",cross_rack="true",request="produce",le="+Inf"} 1
+kafka_rack_client_request_duration_seconds_sum{broker_rack="rack-b",client_rack="rack-a",cross_rack="true",request="produce"} 0.05
+kafka_rack_client_request_duration_seconds_count{broker_rack="rack-b",client_rack="rack-a",cross_rack="true",request="produce"} 1
+`), "kafka_rack_client_request_duration_seconds")
+	require.NoError(t, err)
+}
+
+func TestClientWithoutRack(t *testing.T) {
+	m := New("", WithConstLabels(prometheus.Labels{"client_id": "billing"}))
+	m.OnBrokerE2E(broker(1, "rack-a"), 1, kgo.BrokerE2E{ReadErr: errors.New("EOF")})
+
+	err := testutil.CollectAndCompare(m, strings.NewReader(`
+# HELP kafka_rack_client_request_errors_total Produce and fetch requests that failed to be written or whose response failed to be read.
+# TYPE kafka_rack_client_request_errors_total counter
+kafka_rack_client_request_errors_total{broker_rack="rack-a",client_id="billing",client_rack="",cross_rack="unknown",request="fetch"} 1
+`), "kafka_rack_client_request_errors_total")
+	require.NoError(t, err)
+}
+
+func TestProducerTrafficByRack(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	m := New("rack-a")
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(m))
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.Addrs()...),
+		kgo.DefaultProduceTopic("orders"),
+		kgo.WithHooks(m),
+	)
+	require.NoError(t, err)
+	defer cl.Close()
+
+	require.NoError(t, kafkatest.CreateTopic(ctx, cl, "orders", nil,
+		[]int32{0, 1, 2}, []int32{1, 2, 0}, []int32{2, 0, 1}, []int32{0, 1, 2}, []int32{1, 2, 0}, []int32{2, 0, 1}))
+	for i := 0; i < 60; i++ {
+		require.NoError(t, cl.ProduceSync(ctx, &kgo.Record{Key: []byte(fmt.Sprintf("key-%d", i))}).FirstErr())
+	}
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	requests := make(map[string]uint64)
+	for _, f := range families {
+		if f.GetName() != "kafka_rack_client_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range f.GetMetric() {
+			l := make(map[string]string)
+			for _, p := range metric.GetLabel() {
+				l[p.GetName()] = p.GetValue()
+			}
+			assert.Equal(t, "produce", l["request"])
+			assert.Equal(t, l["broker_rack"] != "rack-a", l["cross_rack"] == "true")
+			requests[l["broker_rack"]] += metric.GetHistogram().GetSampleCount()
+		}
+	}
+	// Leaders are spread over every rack, so a producer in rack-a writes to
+	// all three.
+	assert.Len(t, requests, 3)
+	var total uint64
+	for _, n := range requests {
+		total += n
+	}
+	assert.Equal(t, uint64(60), total)
+}
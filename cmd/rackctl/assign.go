@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/policy"
+	"kafka-rack-awareness/readiness"
+	"kafka-rack-awareness/reassign"
+	"kafka-rack-awareness/source"
+	"kafka-rack-awareness/topology"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func runAssign(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("assign", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	var hf hierarchyFlags
+	hf.register(fs)
+	topic := fs.String("topic", "", "name of the topic to assign (required)")
+	partitions := fs.Int("partitions", 1, "number of partitions")
+	rf := fs.Int("replication-factor", 3, "replication factor")
+	fresh := fs.Bool("ignore-existing", false, "do not balance against replicas of existing topics")
+	output := fs.String("output", "", "write the assignment as kafka-reassign-partitions JSON to this file (- for stdout)")
+	create := fs.Bool("create", false, "create the topic with this assignment and wait until it is ready")
+	configs := make(configMap)
+	fs.Var(configs, "config", "topic config for -create as key=value (repeatable)")
+	var pf policyFlags
+	pf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *topic == "" {
+		return fmt.Errorf("-topic is required")
+	}
+	hierarchy, err := hf.hierarchy()
+	if err != nil {
+		return err
+	}
+
+	cluster, err := cf.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	var opts []planner.Option
+	if !*fresh {
+		opts = append(opts, planner.WithLoad(planner.LoadOf(cluster)))
+	}
+	if hierarchy != nil {
+		opts = append(opts, planner.WithHierarchy(hierarchy))
+	}
+	p, err := planner.New(cluster.Brokers, opts...)
+	if err != nil {
+		return err
+	}
+	assignment, err := p.Assign(planner.Request{Topic: *topic, Partitions: *partitions, ReplicationFactor: *rf})
+	if err != nil {
+		return err
+	}
+
+	plan := reassign.FromAssignments(assignment)
+	switch *output {
+	case "":
+		err = writeAssignmentTable(stdout, cluster, assignment)
+	case "-":
+		err = plan.Write(stdout)
+	default:
+		if err = plan.WriteFile(*output); err == nil {
+			fmt.Fprintf(stdout, "Wrote %d partition(s) to %s\n", len(plan.Partitions), *output)
+		}
+	}
+	if err != nil || !*create {
+		return err
+	}
+	proposed := policy.Topic{Name: *topic, Partitions: *partitions, ReplicationFactor: *rf, Configs: configs}
+	if err := pf.validate(cluster, proposed, assignment); err != nil {
+		return err
+	}
+	return cf.createTopic(ctx, stdout, cluster, assignment, configs)
+}
+
+// createTopic creates a topic with an explicit replica assignment on
+// cluster c and waits until every partition has a leader, a full ISR and
+// the planned rack spread.
+func (f *clusterFlags) createTopic(ctx context.Context, stdout io.Writer, c *topology.Cluster, a planner.Assignment, configs map[string]string) error {
+	client, err := f.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	req := kmsg.NewPtrCreateTopicsRequest()
+	req.TimeoutMillis = int32(f.timeout.Milliseconds())
+	rt := kmsg.NewCreateTopicsRequestTopic()
+	rt.Topic, rt.NumPartitions, rt.ReplicationFactor = a.Topic, -1, -1
+	for i, replicas := range a.Replicas {
+		ra := kmsg.NewCreateTopicsRequestTopicReplicaAssignment()
+		ra.Partition, ra.Replicas = int32(i), replicas
+		rt.ReplicaAssignment = append(rt.ReplicaAssignment, ra)
+	}
+	for k, v := range configs {
+		rc := kmsg.NewCreateTopicsRequestTopicConfig()
+		rc.Name, rc.Value = k, kmsg.StringPtr(v)
+		rt.Configs = append(rt.Configs, rc)
+	}
+	req.Topics = append(req.Topics, rt)
+
+	resp, err := req.RequestWith(ctx, client)
+	if err != nil {
+		return fmt.Errorf("creating topic %q: %w", a.Topic, err)
+	}
+	for _, t := range resp.Topics {
+		if err := kerr.ErrorForCode(t.ErrorCode); err != nil {
+			if t.ErrorMessage != nil {
+				err = fmt.Errorf("%w (%s)", err, *t.ErrorMessage)
+			}
+			return fmt.Errorf("creating topic %q: %w", a.Topic, err)
+		}
+	}
+
+	// A hierarchy or placement.racks can plan fewer racks per partition
+	// than the default of min(RF, racks); wait for what was planned.
+	spread := minRackSpread(c.BrokerRacks(), a.Replicas)
+	src := source.NewFranz(client)
+	if err := readiness.WaitForTopicReady(ctx, src, []string{a.Topic}, readiness.WithRackSpread(spread)); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Created topic %s; all %d partition(s) are led and fully in sync\n", a.Topic, len(a.Replicas))
+	return nil
+}
+
+// minRackSpread returns the fewest racks any of the replica lists spans.
+func minRackSpread(racks map[int32]string, replicas [][]int32) int {
+	spread := 0
+	for i, r := range replicas {
+		if n := len(topology.RacksForBrokers(racks, r)); i == 0 || n < spread {
+			spread = n
+		}
+	}
+	return spread
+}
+
+func writeAssignmentTable(w io.Writer, cluster *topology.Cluster, a planner.Assignment) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tPARTITION\tREPLICAS\tRACKS")
+	for _, p := range a.AsTopic().Partitions {
+		racks := make([]string, 0, len(p.Replicas))
+		for _, id := range p.Replicas {
+			racks = append(racks, dash(cluster.RackOf(id)))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%v\n", a.Topic, p.ID, p.Replicas, racks)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/kafkatest"
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAssignmentTable(t *testing.T) {
+	cluster := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3},
+	)
+	a := planner.Assignment{Topic: "orders", Replicas: [][]int32{{1, 2}, {3, 1}}}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeAssignmentTable(&buf, cluster, a))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 3)
+	assert.Regexp(t, `^orders\s+0\s+\[1 2\]\s+\[rack-a rack-b\]$`, string(lines[1]))
+	assert.Regexp(t, `^orders\s+1\s+\[3 1\]\s+\[- rack-a\]$`, string(lines[2]))
+}
+
+func TestMinRackSpread(t *testing.T) {
+	racks := map[int32]string{1: "rack-a", 2: "rack-b", 3: "rack-c", 4: "rack-a"}
+	assert.Equal(t, 2, minRackSpread(racks, [][]int32{{1, 2, 3}, {1, 4, 2}}))
+	assert.Equal(t, 0, minRackSpread(racks, nil))
+}
+
+func TestAssignHierarchy(t *testing.T) {
+	c := kafkatest.Start(t, "dc1-a", "dc1-b", "dc1-c", "dc2-a")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"assign", "-bootstrap", strings.Join(c.Addrs(), ","),
+		"-topic", "orders", "-partitions", "3", "-replication-factor", "2", "-rack-delimiter", "-"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	require.Len(t, lines, 4)
+	for _, line := range lines[1:] {
+		assert.Contains(t, line, "dc2-a")
+	}
+
+	stderr.Reset()
+	code = run(context.Background(), []string{"assign", "-bootstrap", strings.Join(c.Addrs(), ","),
+		"-topic", "orders", "-rack-pattern", `^(?P<dc>dc\d)(?P<rack>\d)$`}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), `rack "dc1-a" does not match the dc/rack hierarchy`)
+}
+
+func TestAssignCreateValidatesPlannedLayout(t *testing.T) {
+	// Spread over zones first, z2 gets two of the four replicas in its only
+	// rack, which min.insync.replicas=3 cannot lose. A flat plan would
+	// have used every rack once and passed.
+	c := kafkatest.Start(t, "z1-a", "z1-b", "z1-c", "z2-a", "z2-a")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"assign", "-bootstrap", strings.Join(c.Addrs(), ","),
+		"-topic", "orders", "-partitions", "2", "-replication-factor", "4", "-config", "min.insync.replicas=3",
+		"-rack-delimiter", "-", "-rack-levels", "zone,rack", "-create"}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	assert.Contains(t, stderr.String(), "losing z2-a")
+	assert.NotContains(t, stdout.String(), "Created topic")
+}
+
+func TestHierarchyFlags(t *testing.T) {
+	var hf hierarchyFlags
+	h, err := hf.hierarchy()
+	require.NoError(t, err)
+	assert.Nil(t, h)
+
+	hf = hierarchyFlags{delimiter: "/", levels: "region, zone, rack"}
+	h, err = hf.hierarchy()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"region", "zone", "rack"}, h.Levels())
+
+	hf.pattern = `^(?P<dc>\w+)-(?P<rack>\w+)$`
+	_, err = hf.hierarchy()
+	assert.EqualError(t, err, "-rack-delimiter and -rack-pattern are mutually exclusive")
+}
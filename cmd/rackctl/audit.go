@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"kafka-rack-awareness/audit"
+	"kafka-rack-awareness/reassign"
+)
+
+func runAudit(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	var hf hierarchyFlags
+	hf.register(fs)
+	var topics stringList
+	fs.Var(&topics, "topic", "topic to audit (repeatable; default all topics)")
+	format := fs.String("format", formatTable, "output format: table or json")
+	failOn := fs.String("fail-on", "warning", "lowest severity that makes rackctl exit 1: info, warning or critical")
+	internal := fs.Bool("include-internal", false, "also audit internal topics such as __consumer_offsets")
+	leaderSkew := fs.Int("leader-skew", 1, "allowed difference in leaders between the busiest and idlest rack")
+	planFile := fs.String("plan", "", "audit the cluster as it will look after this kafka-reassign-partitions JSON plan")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	threshold, err := audit.ParseSeverity(*failOn)
+	if err != nil {
+		return err
+	}
+	hierarchy, err := hf.hierarchy()
+	if err != nil {
+		return err
+	}
+
+	var plan *reassign.Plan
+	if *planFile != "" {
+		if plan, err = reassign.ReadFile(*planFile); err != nil {
+			return err
+		}
+		if len(topics) == 0 {
+			topics = plan.Topics()
+		}
+	}
+
+	cluster, err := cf.snapshot(ctx, topics...)
+	if err != nil {
+		return err
+	}
+	if plan != nil {
+		if err := plan.Check(cluster); err != nil {
+			return err
+		}
+		cluster = plan.Project(cluster)
+	}
+
+	opts := []audit.Option{audit.WithTopics(topics...), audit.WithLeaderSkew(*leaderSkew)}
+	if *internal {
+		opts = append(opts, audit.WithInternalTopics())
+	}
+	if hierarchy != nil {
+		opts = append(opts, audit.WithHierarchy(hierarchy))
+	}
+	report := audit.New(opts...).Audit(cluster)
+
+	if *format == formatJSON {
+		err = writeJSON(stdout, report)
+	} else {
+		err = writeAuditTable(stdout, report)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.HasViolations(threshold) {
+		return fmt.Errorf("%d violation(s) at or above %s: %w", report.Count(threshold), threshold, errFindings)
+	}
+	return nil
+}
+
+func writeAuditTable(w io.Writer, report *audit.AuditReport) error {
+	fmt.Fprintf(w, "Audited %d broker(s) in %d rack(s), %d topic(s), %d partition(s)\n\n",
+		report.Brokers, report.Racks, report.Topics, report.Partitions)
+
+	if len(report.Violations) == 0 {
+		_, err := fmt.Fprintln(w, "No rack-awareness violations found.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEVERITY\tTOPIC\tPARTITION\tBROKER\tKIND\tMESSAGE")
+	for _, v := range report.Violations {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			v.Severity, dash(v.Topic), optionalID(v.Partition), optionalID(v.Broker), v.Kind, v.Message)
+	}
+	return tw.Flush()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func optionalID(id int32) string {
+	if id < 0 {
+		return "-"
+	}
+	return fmt.Sprint(id)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"kafka-rack-awareness/audit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAuditTable(t *testing.T) {
+	var buf bytes.Buffer
+	report := &audit.AuditReport{Brokers: 3, Racks: 3, Topics: 1, Partitions: 6}
+
+	require.NoError(t, writeAuditTable(&buf, report))
+	assert.Contains(t, buf.String(), "Audited 3 broker(s) in 3 rack(s), 1 topic(s), 6 partition(s)")
+	assert.Contains(t, buf.String(), "No rack-awareness violations found.")
+
+	buf.Reset()
+	report.Violations = []audit.Violation{
+		{Kind: audit.KindBrokerWithoutRack, Severity: audit.SeverityCritical, Partition: audit.NoPartition, Broker: 4, Message: "no rack"},
+		{Kind: audit.KindSharedRack, Severity: audit.SeverityCritical, Topic: "orders", Partition: 2, Broker: audit.NoBroker, Message: "shared"},
+	}
+	require.NoError(t, writeAuditTable(&buf, report))
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 5)
+	assert.Regexp(t, `^SEVERITY\s+TOPIC\s+PARTITION\s+BROKER\s+KIND\s+MESSAGE$`, string(lines[2]))
+	assert.Regexp(t, `^critical\s+-\s+-\s+4\s+broker-without-rack\s+no rack$`, string(lines[3]))
+	assert.Regexp(t, `^critical\s+orders\s+2\s+-\s+replicas-share-rack\s+shared$`, string(lines[4]))
+}
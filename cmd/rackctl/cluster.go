@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kafka-rack-awareness/source"
+	"kafka-rack-awareness/topology"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// clusterFlags are the connection flags shared by every command that talks
+// to a cluster.
+type clusterFlags struct {
+	bootstrap string
+	timeout   time.Duration
+}
+
+func (f *clusterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.bootstrap, "bootstrap", "localhost:9092", "comma-separated list of seed brokers")
+	fs.DurationVar(&f.timeout, "timeout", 60*time.Second, "overall deadline for talking to the cluster")
+}
+
+func (f *clusterFlags) seeds() []string {
+	var seeds []string
+	for _, s := range strings.Split(f.bootstrap, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			seeds = append(seeds, s)
+		}
+	}
+	return seeds
+}
+
+// client connects a franz-go client the same way the integration tests do
+// in createFranzAdminClient.
+func (f *clusterFlags) client() (*kgo.Client, error) {
+	seeds := f.seeds()
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("no bootstrap brokers given")
+	}
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(seeds...),
+		kgo.RequestTimeoutOverhead(10*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating client: %w", err)
+	}
+	return client, nil
+}
+
+// snapshot connects, takes one snapshot of the given topics (or all topics)
+// and disconnects.
+func (f *clusterFlags) snapshot(ctx context.Context, topics ...string) (*topology.Cluster, error) {
+	client, err := f.client()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	return source.NewFranz(client).Snapshot(ctx, topics...)
+}
+
+// hierarchyFlags describe how rack names nest, such as "dc1-rackA" for
+// datacenter dc1, rack rackA.
+type hierarchyFlags struct {
+	delimiter string
+	levels    string
+	pattern   string
+}
+
+func (f *hierarchyFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.delimiter, "rack-delimiter", "", "split rack names into -rack-levels at this delimiter, e.g. - for dc1-rackA")
+	fs.StringVar(&f.levels, "rack-levels", "dc,rack", "comma-separated names of the levels split by -rack-delimiter, outermost first")
+	fs.StringVar(&f.pattern, "rack-pattern", "", "regexp whose named groups are the rack name levels, outermost first, e.g. ^(?P<dc>[^-]+)-(?P<rack>.+)$")
+}
+
+// hierarchy returns the configured rack hierarchy, or nil if rack names
+// are flat.
+func (f *hierarchyFlags) hierarchy() (*topology.Hierarchy, error) {
+	switch {
+	case f.delimiter != "" && f.pattern != "":
+		return nil, fmt.Errorf("-rack-delimiter and -rack-pattern are mutually exclusive")
+	case f.pattern != "":
+		return topology.RegexpHierarchy(f.pattern)
+	case f.delimiter != "":
+		var levels stringList
+		levels.Set(f.levels)
+		return topology.SplitHierarchy(f.delimiter, levels...)
+	}
+	return nil, nil
+}
+
+// stringList is a repeatable flag that also accepts comma-separated values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// brokerList is a repeatable flag of broker IDs that also accepts
+// comma-separated values.
+type brokerList []int32
+
+func (l *brokerList) String() string {
+	ids := make([]string, len(*l))
+	for i, id := range *l {
+		ids[i] = strconv.Itoa(int(id))
+	}
+	return strings.Join(ids, ",")
+}
+
+func (l *brokerList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid broker ID %q", v)
+		}
+		*l = append(*l, int32(id))
+	}
+	return nil
+}
+
+// configMap is a repeatable key=value flag for topic configs. Values may
+// contain commas, as in cleanup.policy=compact,delete.
+type configMap map[string]string
+
+func (m configMap) String() string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (m configMap) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if k = strings.TrimSpace(k); !ok || k == "" {
+		return fmt.Errorf("invalid config %q (want key=value)", value)
+	}
+	m[k] = v
+	return nil
+}
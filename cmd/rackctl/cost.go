@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"kafka-rack-awareness/cost"
+)
+
+func runCost(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("cost", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	workloadFile := fs.String("workload", "", "YAML file of producer and consumer racks, throughput and prices (required)")
+	scenario := fs.String("scenario", cost.Scenarios[0].Name, "scenario whose traffic to break down by rack pair")
+	format := fs.String("format", formatTable, "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *workloadFile == "" {
+		return fmt.Errorf("-workload is required")
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if err := checkScenario(*scenario); err != nil {
+		return err
+	}
+	w, err := cost.ReadFile(*workloadFile)
+	if err != nil {
+		return err
+	}
+
+	cluster, err := cf.snapshot(ctx, w.Topics()...)
+	if err != nil {
+		return err
+	}
+	comparisons, err := cost.Compare(cluster, w)
+	if err != nil {
+		return err
+	}
+	if *format == formatJSON {
+		return writeJSON(stdout, costJSON(comparisons))
+	}
+
+	for _, cmp := range comparisons {
+		if cmp.Scenario == *scenario {
+			return writeCostTable(stdout, cmp, comparisons)
+		}
+	}
+	return nil
+}
+
+func checkScenario(name string) error {
+	names := make([]string, len(cost.Scenarios))
+	for i, s := range cost.Scenarios {
+		if s.Name == name {
+			return nil
+		}
+		names[i] = s.Name
+	}
+	return fmt.Errorf("unknown scenario %q, want one of %v", name, names)
+}
+
+// scenarioJSON adds the totals of the table to a scenario's JSON.
+type scenarioJSON struct {
+	Scenario string                    `json:"scenario"`
+	Total    cost.Totals               `json:"total"`
+	ByKind   map[cost.Kind]cost.Totals `json:"by_kind"`
+	*cost.Report
+}
+
+func costJSON(comparisons []cost.Comparison) any {
+	out := make([]scenarioJSON, 0, len(comparisons))
+	for _, cmp := range comparisons {
+		out = append(out, scenarioJSON{
+			Scenario: cmp.Scenario,
+			Total:    cmp.Report.Total(),
+			ByKind:   cmp.Report.ByKind(),
+			Report:   cmp.Report,
+		})
+	}
+	return struct {
+		Scenarios []scenarioJSON `json:"scenarios"`
+	}{out}
+}
+
+func writeCostTable(w io.Writer, detail cost.Comparison, comparisons []cost.Comparison) error {
+	fmt.Fprintf(w, "Traffic by rack pair (%s)\n\n", detail.Scenario)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tFROM\tTO\tMB/S\tGB/MONTH\tPER GB\tCOST/MONTH")
+	for _, f := range detail.Report.Flows {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%.2f\t%.0f\t%.4f\t%.2f\n",
+			f.Kind, dash(f.From), dash(f.To), f.BytesPerSecond/1e6, f.GBPerMonth, f.PerGB, f.CostPerMonth)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nCross-rack traffic per scenario (MB/s)\n\n")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCENARIO\tPRODUCE\tREPLICATION\tCONSUME\tGB/MONTH\tCOST/MONTH\tCHANGE")
+	baseline := comparisons[0].Report.Total().CostPerMonth
+	for _, cmp := range comparisons {
+		total, byKind := cmp.Report.Total(), cmp.Report.ByKind()
+		change := "-"
+		if cmp.Scenario != comparisons[0].Scenario && baseline > 0 {
+			change = fmt.Sprintf("%+.0f%%", 100*(total.CostPerMonth-baseline)/baseline)
+		}
+		fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%.2f\t%.0f\t%.2f\t%s\n", cmp.Scenario,
+			byKind[cost.Produce].CrossRackBytesPerSecond/1e6,
+			byKind[cost.Replication].CrossRackBytesPerSecond/1e6,
+			byKind[cost.Consume].CrossRackBytesPerSecond/1e6,
+			total.CrossRackGBPerMonth, total.CostPerMonth, change)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// Every pinning scenario pins the same way; report it once.
+	for _, cmp := range comparisons {
+		if cmp.Report.Pinned == nil {
+			continue
+		}
+		topics := make([]string, 0, len(cmp.Report.Pinned))
+		for topic := range cmp.Report.Pinned {
+			topics = append(topics, topic)
+		}
+		sort.Strings(topics)
+		fmt.Fprintln(w)
+		for _, topic := range topics {
+			fmt.Fprintf(w, "Pinning leaders of %s to %s\n", topic, cmp.Report.Pinned[topic])
+		}
+		if len(topics) == 0 {
+			fmt.Fprintln(w, "No topic can be pinned: no producer rack has brokers")
+		}
+		break
+	}
+	return nil
+}
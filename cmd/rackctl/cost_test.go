@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/kafkatest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestCost(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	defer cl.Close()
+	require.NoError(t, kafkatest.CreateTopic(context.Background(), cl, "orders", nil, oneLeaderPerRack...))
+
+	workload := filepath.Join(t.TempDir(), "workload.yaml")
+	require.NoError(t, os.WriteFile(workload, []byte(`
+producers:
+  - {topic: orders, rack: rack-a, mbPerSecond: 3}
+consumers:
+  - {topic: orders, rack: rack-b, mbPerSecond: 3}
+prices:
+  perGB: 0.01
+`), 0o644))
+
+	bootstrap := strings.Join(c.Addrs(), ",")
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"cost", "-bootstrap", bootstrap, "-workload", workload}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	out := stdout.String()
+	assert.Contains(t, out, "Traffic by rack pair (baseline)")
+	assert.Regexp(t, `replication\s+rack-a\s+rack-b\s+1.00\s+2628\s+0.0100\s+26.28`, out)
+	assert.Regexp(t, `baseline\s+2.00\s+6.00\s+2.00\s+26280\s+262.80\s+-`, out)
+	assert.Regexp(t, `pinned-leaders\+follower-fetching\s+0.00\s+6.00\s+0.00\s+15768\s+157.68\s+-40%`, out)
+	assert.Contains(t, out, "Pinning leaders of orders to rack-a")
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"cost", "-bootstrap", bootstrap, "-workload", workload, "-scenario", "follower-fetching"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Regexp(t, `consume\s+rack-b\s+rack-b\s+3.00`, stdout.String())
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"cost", "-bootstrap", bootstrap, "-workload", workload, "-format", "json"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	var report struct {
+		Scenarios []struct {
+			Scenario string `json:"scenario"`
+			Total    struct {
+				CostPerMonth float64 `json:"cost_per_month"`
+			} `json:"total"`
+			Flows []json.RawMessage `json:"flows"`
+		} `json:"scenarios"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	require.Len(t, report.Scenarios, 4)
+	assert.Equal(t, "baseline", report.Scenarios[0].Scenario)
+	assert.InDelta(t, 262.8, report.Scenarios[0].Total.CostPerMonth, 1e-6)
+	assert.Len(t, report.Scenarios[0].Flows, 12)
+}
+
+func TestCostNeedsWorkload(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(context.Background(), []string{"cost"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "-workload is required")
+
+	stderr.Reset()
+	assert.Equal(t, 2, run(context.Background(), []string{"cost", "-workload", "w.yaml", "-scenario", "cheapest"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown scenario "cheapest"`)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"kafka-rack-awareness/reassign"
+	"kafka-rack-awareness/source"
+	"kafka-rack-awareness/topology"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+func runDecommissionRack(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("decommission-rack", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	dryRun := fs.Bool("dry-run", false, "print the moves without executing them")
+	output := fs.String("output", "", "also write the plan as kafka-reassign-partitions JSON to this file")
+	rollbackFile := fs.String("rollback-output", "", "write the rollback plan to this file instead of stdout")
+	poll := fs.Duration("poll", 5*time.Second, "how often to poll reassignment progress and replication")
+	settle := fs.Duration("verify-timeout", 5*time.Minute, "how long to wait for under-replicated partitions to catch up")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: rackctl decommission-rack [flags] <rack>")
+	}
+	rack := fs.Arg(0)
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admin := kadm.NewClient(client)
+	src := source.NewFranz(client)
+
+	snapCtx, cancel := context.WithTimeout(ctx, cf.timeout)
+	cluster, err := src.Snapshot(snapCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	plan, err := reassign.DecommissionRack(cluster, rack)
+	if err != nil {
+		return err
+	}
+	if err := writeMoveTable(stdout, cluster, plan); err != nil {
+		return err
+	}
+	if *output != "" {
+		if err := plan.WriteFile(*output); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Wrote %d partition(s) to %s\n", len(plan.Partitions), *output)
+	}
+	if *dryRun {
+		return nil
+	}
+
+	if len(plan.Partitions) > 0 {
+		// Leaving a rack lowers the spread of every partition that used it;
+		// the planner already keeps the widest spread that remains.
+		exec := reassign.NewExecutor(admin, src,
+			reassign.WithForce(),
+			reassign.WithPollInterval(*poll),
+			reassign.WithProgress(func(p reassign.Progress) { writeProgress(stdout, p) }),
+		)
+		startCtx, cancel := context.WithTimeout(ctx, cf.timeout)
+		rollback, err := exec.Start(startCtx, plan)
+		cancel()
+		if rollback != nil {
+			if werr := writeRollback(stdout, rollback, *rollbackFile); werr != nil && err == nil {
+				err = werr
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if err := exec.Wait(ctx, plan); err != nil {
+			return err
+		}
+	}
+
+	status, err := waitDecommissioned(ctx, src, rack, *poll, *settle)
+	if err != nil {
+		return err
+	}
+	writeDecommissionStatus(stdout, status)
+	if !status.Safe() {
+		return fmt.Errorf("rack %s is not safe to shut down: %w", rack, errFindings)
+	}
+	return nil
+}
+
+// waitDecommissioned polls until the rack is safe to shut down or timeout
+// passes, and returns the last status. A snapshot cut short by the timeout
+// still returns the status before it.
+func waitDecommissioned(ctx context.Context, src topology.MetadataSource, rack string, poll, timeout time.Duration) (reassign.DecommissionStatus, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	var (
+		status  reassign.DecommissionStatus
+		checked bool
+	)
+	for {
+		cluster, err := src.Snapshot(timeoutCtx)
+		if err != nil {
+			if checked && ctx.Err() == nil && timeoutCtx.Err() != nil {
+				return status, nil
+			}
+			return reassign.DecommissionStatus{}, err
+		}
+		status, checked = reassign.CheckDecommission(cluster, rack), true
+		if status.Safe() {
+			return status, nil
+		}
+		select {
+		case <-timeoutCtx.Done():
+			return status, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func writeMoveTable(w io.Writer, cluster *topology.Cluster, plan *reassign.Plan) error {
+	if len(plan.Partitions) == 0 {
+		_, err := fmt.Fprintln(w, "No partitions to move.")
+		return err
+	}
+	current := reassign.Current(cluster, plan.Topics()...)
+	from := make(map[string]map[int32][]int32)
+	for _, p := range current.Partitions {
+		if from[p.Topic] == nil {
+			from[p.Topic] = make(map[int32][]int32)
+		}
+		from[p.Topic][p.Partition] = p.Replicas
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tPARTITION\tFROM\tTO\tRACKS")
+	for _, p := range plan.Partitions {
+		racks := make([]string, 0, len(p.Replicas))
+		for _, id := range p.Replicas {
+			racks = append(racks, dash(cluster.RackOf(id)))
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%v\t%v\n", p.Topic, p.Partition, from[p.Topic][p.Partition], p.Replicas, racks)
+	}
+	return tw.Flush()
+}
+
+func writeDecommissionStatus(w io.Writer, s reassign.DecommissionStatus) {
+	if s.Safe() {
+		fmt.Fprintf(w, "Rack %s (brokers %v) holds no replicas and no partition is under-replicated; it is safe to shut down.\n", s.Rack, s.Brokers)
+		return
+	}
+	for _, p := range s.Remaining {
+		fmt.Fprintf(w, "%s-%d still has replicas %v in rack %s\n", p.Topic, p.ID, p.Replicas, s.Rack)
+	}
+	for _, p := range s.UnderReplicated {
+		fmt.Fprintf(w, "%s-%d is under-replicated: ISR %v of replicas %v\n", p.Topic, p.ID, p.ISR, p.Replicas)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/kafkatest"
+	"kafka-rack-awareness/reassign"
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestWriteMoveTable(t *testing.T) {
+	cluster := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+	)
+	cluster.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 3, Replicas: []int32{3, 1}},
+	}})
+	plan := &reassign.Plan{Version: reassign.Version, Partitions: []reassign.PartitionReassignment{
+		{Topic: "orders", Partition: 0, Replicas: []int32{2, 1}},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeMoveTable(&buf, cluster, plan))
+	assert.Regexp(t, `orders\s+0\s+\[3 1\]\s+\[2 1\]\s+\[rack-b rack-a\]`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeMoveTable(&buf, cluster, &reassign.Plan{Version: reassign.Version}))
+	assert.Equal(t, "No partitions to move.\n", buf.String())
+}
+
+func TestWriteDecommissionStatus(t *testing.T) {
+	var buf bytes.Buffer
+	writeDecommissionStatus(&buf, reassign.DecommissionStatus{Rack: "rack-c", Brokers: []int32{3, 6}})
+	assert.Contains(t, buf.String(), "safe to shut down")
+
+	buf.Reset()
+	writeDecommissionStatus(&buf, reassign.DecommissionStatus{
+		Rack:            "rack-c",
+		UnderReplicated: []topology.Partition{{Topic: "orders", ID: 2, Replicas: []int32{1, 2}, ISR: []int32{1}}},
+	})
+	assert.Equal(t, "orders-2 is under-replicated: ISR [1] of replicas [1 2]\n", buf.String())
+}
+
+func TestDecommissionRackNeedsRack(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(context.Background(), []string{"decommission-rack"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "usage: rackctl decommission-rack")
+}
+
+func TestDecommissionRackWaitsForUnderReplicated(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c", "rack-d")
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	defer cl.Close()
+	ctx := context.Background()
+	require.NoError(t, kafkatest.CreateTopic(ctx, cl, "orders", nil, []int32{3, 0}, []int32{0, 1}))
+	require.NoError(t, kafkatest.CreateTopic(ctx, cl, "payments", nil, []int32{1, 2}))
+	c.SetISR("payments", 0, 1)
+
+	bootstrap := strings.Join(c.Addrs(), ",")
+	var stdout, stderr bytes.Buffer
+	code := run(ctx, []string{"decommission-rack", "-bootstrap", bootstrap, "-poll", "10ms", "-verify-timeout", "100ms", "rack-d"}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	assert.Contains(t, stdout.String(), "payments-0 is under-replicated: ISR [1] of replicas [1 2]")
+	assert.NotContains(t, stdout.String(), "still has replicas")
+	assert.Contains(t, stderr.String(), "rack rack-d is not safe to shut down")
+
+	// Once the follower catches up, the rack is safe to shut down.
+	c.SetISR("payments", 0, 1, 2)
+	stdout.Reset()
+	stderr.Reset()
+	code = run(ctx, []string{"decommission-rack", "-bootstrap", bootstrap, "-poll", "10ms", "-verify-timeout", "100ms", "rack-d"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "No partitions to move.")
+	assert.Contains(t, stdout.String(), "it is safe to shut down")
+}
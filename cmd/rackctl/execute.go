@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"kafka-rack-awareness/reassign"
+	"kafka-rack-awareness/source"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+func runExecute(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("execute", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	planFile := fs.String("plan", "", "kafka-reassign-partitions JSON plan to execute (required)")
+	rollbackFile := fs.String("rollback-output", "", "write the rollback plan to this file instead of stdout")
+	poll := fs.Duration("poll", 5*time.Second, "how often to poll reassignment progress")
+	noWait := fs.Bool("no-wait", false, "submit the plan and exit without waiting for it to finish")
+	force := fs.Bool("force", false, "execute even if the plan reduces the rack spread of a partition")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *planFile == "" {
+		return fmt.Errorf("-plan is required")
+	}
+	plan, err := reassign.ReadFile(*planFile)
+	if err != nil {
+		return err
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admin := kadm.NewClient(client)
+
+	opts := []reassign.ExecutorOption{
+		reassign.WithPollInterval(*poll),
+		reassign.WithProgress(func(p reassign.Progress) { writeProgress(stdout, p) }),
+	}
+	if *force {
+		opts = append(opts, reassign.WithForce())
+	}
+	exec := reassign.NewExecutor(admin, source.NewFranz(client), opts...)
+
+	startCtx, cancel := context.WithTimeout(ctx, cf.timeout)
+	rollback, err := exec.Start(startCtx, plan)
+	cancel()
+	if rollback != nil {
+		if werr := writeRollback(stdout, rollback, *rollbackFile); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Started reassignment of %d partition(s).\n", len(plan.Partitions))
+	if *noWait {
+		return nil
+	}
+
+	if err := exec.Wait(ctx, plan); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("stopped waiting; the reassignment continues on the cluster (use rackctl cancel to abort it): %w", err)
+		}
+		return err
+	}
+	fmt.Fprintln(stdout, "Reassignment complete.")
+	return nil
+}
+
+func runCancel(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("cancel", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	planFile := fs.String("plan", "", "plan whose in-flight reassignments to cancel (required)")
+	rollbackFile := fs.String("rollback", "", "after cancelling, restore the assignment in this rollback plan")
+	poll := fs.Duration("poll", 5*time.Second, "how often to poll rollback progress")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *planFile == "" {
+		return fmt.Errorf("-plan is required")
+	}
+	plan, err := reassign.ReadFile(*planFile)
+	if err != nil {
+		return err
+	}
+	var rollback *reassign.Plan
+	if *rollbackFile != "" {
+		if rollback, err = reassign.ReadFile(*rollbackFile); err != nil {
+			return err
+		}
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admin := kadm.NewClient(client)
+
+	exec := reassign.NewExecutor(admin, source.NewFranz(client),
+		reassign.WithPollInterval(*poll),
+		reassign.WithProgress(func(p reassign.Progress) { writeProgress(stdout, p) }),
+	)
+
+	// The timeout bounds the cancel and the submit; moving the data back
+	// takes as long as it takes, as in runExecute.
+	alterCtx, cancel := context.WithTimeout(ctx, cf.timeout)
+	defer cancel()
+	if rollback == nil {
+		if err := exec.Cancel(alterCtx, plan); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "Cancelled in-flight reassignments.")
+		return nil
+	}
+	err = exec.Rollback(alterCtx, plan, rollback)
+	cancel()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Started restoring %d partition(s).\n", len(rollback.Partitions))
+	if err := exec.Wait(ctx, rollback); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("stopped waiting; the rollback continues on the cluster: %w", err)
+		}
+		return err
+	}
+	fmt.Fprintln(stdout, "Original assignment restored.")
+	return nil
+}
+
+func writeRollback(stdout io.Writer, rollback *reassign.Plan, path string) error {
+	if path == "" {
+		fmt.Fprintln(stdout, "Current partition replica assignment (save this to roll back):")
+		if err := rollback.Write(stdout); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout)
+		return nil
+	}
+	if err := rollback.WriteFile(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote rollback plan for %d partition(s) to %s\n", len(rollback.Partitions), path)
+	return nil
+}
+
+func writeProgress(w io.Writer, p reassign.Progress) error {
+	fmt.Fprintf(w, "%d/%d partition(s) reassigned\n", p.Done, len(p.Partitions))
+	if p.Complete() {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  TOPIC\tPARTITION\tSTATE\tTARGET\tREPLICAS\tADDING\tREMOVING")
+	for _, pp := range p.Partitions {
+		if pp.Done {
+			continue
+		}
+		state := "moving"
+		if pp.Stopped {
+			state = "stopped"
+		}
+		fmt.Fprintf(tw, "  %s\t%d\t%s\t%v\t%v\t%v\t%v\n", pp.Topic, pp.Partition, state, pp.Target, pp.Replicas, pp.Adding, pp.Removing)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"kafka-rack-awareness/exporter"
+	"kafka-rack-awareness/source"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func runExporter(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("exporter", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	listen := fs.String("listen", ":9308", "address to serve /metrics on")
+	interval := fs.Duration("interval", 30*time.Second, "how often to take a cluster snapshot")
+	minRacks := fs.Int("min-racks", 0, "racks every partition must span (default min(RF, racks))")
+	var topics stringList
+	fs.Var(&topics, "topic", "topic to report (repeatable; default all topics)")
+	internal := fs.Bool("include-internal", false, "also report internal topics such as __consumer_offsets")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("-interval must be positive, got %s", *interval)
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	opts := []exporter.Option{
+		exporter.WithInterval(*interval),
+		exporter.WithTimeout(cf.timeout),
+		exporter.WithMinRacks(*minRacks),
+		exporter.WithTopics(topics...),
+	}
+	if *internal {
+		opts = append(opts, exporter.WithInternalTopics())
+	}
+	exp := exporter.New(source.NewFranz(client), opts...)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(exp)
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	fmt.Fprintf(stdout, "Serving rack metrics on http://%s/metrics, refreshed every %s\n", ln.Addr(), *interval)
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exp.Run(ctx, func(err error) {
+			fmt.Fprintf(stdout, "%s %v\n", time.Now().Format(time.RFC3339), err)
+		})
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	err = srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	stop()
+	<-done
+	return err
+}
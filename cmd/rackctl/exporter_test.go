@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-rack-awareness/kafkatest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestExporter(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	defer cl.Close()
+	_, err = kadm.NewClient(cl).CreateTopic(context.Background(), 3, 3, nil, "orders")
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stdout, stderr bytes.Buffer
+	code := make(chan int, 1)
+	go func() {
+		code <- run(ctx, []string{"exporter", "-bootstrap", strings.Join(c.Addrs(), ","), "-listen", addr, "-interval", "50ms"}, &stdout, &stderr)
+	}()
+
+	var body string
+	require.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + addr + "/metrics")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		body = string(b)
+		return err == nil && strings.Contains(body, "kafka_rack_up 1")
+	}, 10*time.Second, 20*time.Millisecond)
+
+	assert.Contains(t, body, `kafka_rack_brokers{rack="rack-a"} 1`)
+	assert.Contains(t, body, `kafka_rack_partitions{topic="orders"} 3`)
+	assert.Contains(t, body, `kafka_rack_partitions_under_spread{topic="orders"} 0`)
+	assert.Contains(t, body, `kafka_rack_replicas{rack="rack-b"} 3`)
+	assert.Contains(t, body, `kafka_rack_isr_racks_bucket{le="3"} 3`)
+	assert.Contains(t, body, "kafka_rack_brokers_without_rack 0")
+
+	cancel()
+	assert.Equal(t, 0, <-code, stderr.String())
+	assert.Contains(t, stdout.String(), "Serving rack metrics on http://"+addr+"/metrics")
+}
+
+func TestExporterFlagErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"exporter", "-interval", "0s"}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), "-interval must be positive")
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"kafka-rack-awareness/leaders"
+	"kafka-rack-awareness/source"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+func runLeaders(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("leaders", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	var topics stringList
+	fs.Var(&topics, "topic", "topic to check (repeatable; default all topics)")
+	internal := fs.Bool("include-internal", false, "also check internal topics such as __consumer_offsets")
+	threshold := fs.Float64("threshold", 10, "only elect partitions whose preferred broker has lost more than this percentage of its partitions, like leader.imbalance.per.broker.percentage")
+	elect := fs.Bool("elect", false, "run preferred-replica elections for drifted partitions")
+	dryRun := fs.Bool("dry-run", false, "list the elections -elect would run without running them")
+	batchSize := fs.Int("batch-size", 10, "partitions to elect per request")
+	batchInterval := fs.Duration("batch-interval", time.Second, "pause between election requests")
+	format := fs.String("format", formatTable, "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	if *batchSize <= 0 {
+		return fmt.Errorf("-batch-size must be positive, got %d", *batchSize)
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admin := kadm.NewClient(client)
+
+	snapCtx, cancel := context.WithTimeout(ctx, cf.timeout)
+	cluster, err := source.NewFranz(client).Snapshot(snapCtx, topics...)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if !*internal {
+		cluster = withoutInternal(cluster)
+	}
+	report := leaders.Analyze(cluster)
+	candidates := report.Candidates(*threshold / 100)
+
+	if !*elect && !*dryRun {
+		if *format == formatJSON {
+			err = writeJSON(stdout, leadersJSON(report, candidates, nil))
+		} else {
+			err = writeLeadersTable(stdout, report, candidates, *threshold)
+		}
+		if err != nil {
+			return err
+		}
+		if len(candidates) > 0 {
+			return fmt.Errorf("%d partition(s) can move back to their preferred leader (rerun with -elect): %w", len(candidates), errFindings)
+		}
+		return nil
+	}
+
+	opts := []leaders.ElectorOption{leaders.WithBatchSize(*batchSize), leaders.WithBatchInterval(*batchInterval)}
+	if *dryRun {
+		opts = append(opts, leaders.WithDryRun())
+	}
+	if *format == formatTable {
+		if err := writeLeadersTable(stdout, report, candidates, *threshold); err != nil {
+			return err
+		}
+		if len(candidates) > 0 {
+			fmt.Fprintln(stdout)
+		}
+		opts = append(opts, leaders.WithBatchProgress(func(rs []leaders.Result) { writeElectionBatch(stdout, rs, *dryRun) }))
+	}
+	results, err := leaders.NewElector(admin, opts...).Elect(ctx, candidates)
+	if *format == formatJSON {
+		if werr := writeJSON(stdout, leadersJSON(report, candidates, results)); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d election(s) failed: %w", failed, len(results), errFindings)
+	}
+	if *dryRun && len(candidates) > 0 {
+		return fmt.Errorf("%d partition(s) can move back to their preferred leader: %w", len(candidates), errFindings)
+	}
+	if *format == formatTable && !*dryRun {
+		fmt.Fprintf(stdout, "Elected preferred leaders for %d partition(s)\n", len(results))
+	}
+	return nil
+}
+
+func writeLeadersTable(w io.Writer, r *leaders.Report, candidates []leaders.Drift, threshold float64) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RACK\tLEADERS\tPREFERRED\tLED ELSEWHERE\tIMBALANCE")
+	for _, l := range r.Racks {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.0f%%\n", l.Rack, l.Leaders, l.Preferred, l.Lost, 100*l.Imbalance())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BROKER\tRACK\tLEADERS\tPREFERRED\tLED ELSEWHERE\tIMBALANCE")
+	for _, l := range r.Brokers {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%.0f%%\n", l.Broker, dash(l.Rack), l.Leaders, l.Preferred, l.Lost, 100*l.Imbalance())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nLeader skew (busiest / mean, 1.00 is even): %.2f across racks, %.2f across brokers\n", r.RackSkew(), r.BrokerSkew())
+
+	if len(r.Drifted) == 0 {
+		_, err := fmt.Fprintln(w, "Every partition is led by its preferred replica.")
+		return err
+	}
+	fmt.Fprintf(w, "\n%d partition(s) led by a replica other than the preferred one:\n", len(r.Drifted))
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PARTITION\tLEADER\tPREFERRED\tELECTABLE")
+	for _, d := range r.Drifted {
+		electable := "yes"
+		if !d.Electable {
+			electable = "no (preferred replica out of sync)"
+		}
+		fmt.Fprintf(tw, "%s\t%d (%s)\t%d (%s)\t%s\n", d, d.Leader, dash(d.LeaderRack), d.Preferred, dash(d.PreferredRack), electable)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d to elect at a %.0f%% imbalance threshold\n", len(candidates), threshold)
+	return err
+}
+
+func writeElectionBatch(w io.Writer, rs []leaders.Result, dryRun bool) {
+	for _, r := range rs {
+		switch {
+		case dryRun:
+			fmt.Fprintf(w, "would elect %s: %d -> %d\n", r.Drift, r.Leader, r.Preferred)
+		case r.Err != nil:
+			fmt.Fprintf(w, "failed %s: %v\n", r.Drift, r.Err)
+		default:
+			fmt.Fprintf(w, "elected %s: %d -> %d\n", r.Drift, r.Leader, r.Preferred)
+		}
+	}
+}
+
+func leadersJSON(r *leaders.Report, candidates []leaders.Drift, results []leaders.Result) any {
+	type election struct {
+		leaders.Drift
+		Elected bool   `json:"elected"`
+		Error   string `json:"error,omitempty"`
+	}
+	out := struct {
+		*leaders.Report
+		RackSkew   float64         `json:"rack_skew"`
+		BrokerSkew float64         `json:"broker_skew"`
+		Candidates []leaders.Drift `json:"candidates"`
+		Elections  []election      `json:"elections,omitempty"`
+	}{Report: r, RackSkew: r.RackSkew(), BrokerSkew: r.BrokerSkew(), Candidates: []leaders.Drift{}}
+	if out.Drifted == nil {
+		out.Drifted = []leaders.Drift{}
+	}
+	out.Candidates = append(out.Candidates, candidates...)
+	for _, res := range results {
+		e := election{Drift: res.Drift, Elected: res.Elected}
+		if res.Err != nil {
+			e.Error = res.Err.Error()
+		}
+		out.Elections = append(out.Elections, e)
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/kafkatest"
+	"kafka-rack-awareness/source"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestLeaders(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	defer cl.Close()
+	require.NoError(t, kafkatest.CreateTopic(context.Background(), cl, "orders", nil, oneLeaderPerRack...))
+
+	// Fail partition 0 over to its second replica.
+	snap, err := source.NewFranz(cl).Snapshot(context.Background(), "orders")
+	require.NoError(t, err)
+	orders, _ := snap.Topic("orders")
+	p0 := orders.Partitions[0]
+	c.Fake().MoveTopicPartition("orders", 0, p0.Replicas[1])
+
+	bootstrap := strings.Join(c.Addrs(), ",")
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"leaders", "-bootstrap", bootstrap}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	assert.Regexp(t, `RACK\s+LEADERS\s+PREFERRED\s+LED ELSEWHERE\s+IMBALANCE`, stdout.String())
+	assert.Regexp(t, `orders-0\s+\d \(rack-.\)\s+\d \(rack-.\)\s+yes`, stdout.String())
+	assert.Contains(t, stdout.String(), "1 to elect at a 10% imbalance threshold")
+	assert.Contains(t, stderr.String(), "rerun with -elect")
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"leaders", "-bootstrap", bootstrap, "-dry-run"}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	assert.Contains(t, stdout.String(), "would elect orders-0")
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"leaders", "-bootstrap", bootstrap, "-elect", "-batch-interval", "1ms", "-format", "json"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	var report struct {
+		RackSkew  float64 `json:"rack_skew"`
+		Elections []struct {
+			Topic     string `json:"topic"`
+			Partition int32  `json:"partition"`
+			Elected   bool   `json:"elected"`
+		} `json:"elections"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	require.Len(t, report.Elections, 1)
+	assert.True(t, report.Elections[0].Elected)
+	assert.Greater(t, report.RackSkew, 1.0)
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"leaders", "-bootstrap", bootstrap}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Every partition is led by its preferred replica.")
+	assert.Contains(t, stdout.String(), "1.00 across racks")
+}
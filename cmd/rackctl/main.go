@@ -0,0 +1,102 @@
+// Command rackctl audits and manages rack placement on a live Kafka cluster.
+//
+// Usage:
+//
+//	rackctl <command> [flags]
+//
+// Run "rackctl help" for the list of commands. Commands exit with status 1
+// when they find problems and 2 when they cannot run.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+)
+
+// errFindings is returned by commands that ran successfully but found
+// problems the caller should act on, such as audit violations.
+var errFindings = errors.New("findings reported")
+
+// command is a rackctl subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string, stdout io.Writer) error
+}
+
+var commands = []command{
+	{"audit", "check replica, leader and ISR rack spread", runAudit},
+	{"assign", "generate a rack-aware replica assignment for a new topic", runAssign},
+	{"validate-topic", "check a proposed topic against rack and min.insync.replicas constraints", runValidateTopic},
+	{"wait", "wait until brokers and topics are ready", runWait},
+	{"plan", "diff a YAML topic spec against the cluster", runPlan},
+	{"apply", "create, grow, reconfigure and reassign topics to match a YAML spec", runApply},
+	{"execute", "apply a reassignment plan and track its progress", runExecute},
+	{"cancel", "cancel a running reassignment, optionally rolling it back", runCancel},
+	{"leaders", "report leader skew per rack and elect preferred leaders for drifted partitions", runLeaders},
+	{"pin-leaders", "make a rack the preferred leader of topics and elect leaders there", runPinLeaders},
+	{"exporter", "serve rack health metrics for Prometheus", runExporter},
+	{"what-if", "simulate a rack or broker failure and report unavailable partitions", runWhatIf},
+	{"fault-tolerance", "find the fewest rack or broker failures that break each topic", runFaultTolerance},
+	{"rebalance", "move replicas onto under-loaded brokers, such as newly added ones", runRebalance},
+	{"decommission-rack", "move every replica off a rack and verify it is safe to shut down", runDecommissionRack},
+	{"cost", "estimate cross-rack traffic cost with and without follower fetching or pinned leaders", runCost},
+	{"snapshot", "save brokers, racks, topics, partitions and topic configs to a JSON file", runSnapshot},
+	{"diff", "compare two snapshot files: leader moves, ISR shrinks, rack and broker changes", runDiff},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stderr)
+		if len(args) == 0 {
+			return 2
+		}
+		return 0
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(ctx, args[1:], stdout)
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, errFindings):
+			fmt.Fprintf(stderr, "rackctl %s: %v\n", cmd.name, err)
+			return 1
+		default:
+			fmt.Fprintf(stderr, "rackctl %s: %v\n", cmd.name, err)
+			return 2
+		}
+	}
+
+	fmt.Fprintf(stderr, "rackctl: unknown command %q\n\n", args[0])
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: rackctl <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "rackctl <command> -h" for command flags.`)
+}
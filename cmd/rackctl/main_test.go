@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// oneLeaderPerRack lays out three partitions over brokers 0, 1 and 2 of a
+// rack-a, rack-b, rack-c cluster, each led from a different rack.
+var oneLeaderPerRack = [][]int32{{0, 1, 2}, {1, 2, 0}, {2, 0, 1}}
+
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	assert.Equal(t, 2, run(context.Background(), nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "audit")
+
+	stderr.Reset()
+	assert.Equal(t, 0, run(context.Background(), []string{"help"}, &stdout, &stderr))
+
+	stderr.Reset()
+	assert.Equal(t, 2, run(context.Background(), []string{"bogus"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown command "bogus"`)
+}
+
+func TestRunFlagErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	assert.Equal(t, 2, run(context.Background(), []string{"audit", "-format", "yaml"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown format "yaml"`)
+
+	stderr.Reset()
+	assert.Equal(t, 2, run(context.Background(), []string{"audit", "-fail-on", "fatal"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown severity "fatal"`)
+}
+
+func TestStringList(t *testing.T) {
+	var l stringList
+	assert.NoError(t, l.Set("a, b"))
+	assert.NoError(t, l.Set("c"))
+	assert.Equal(t, stringList{"a", "b", "c"}, l)
+	assert.Equal(t, "a,b,c", l.String())
+}
+
+func TestBrokerList(t *testing.T) {
+	var l brokerList
+	assert.NoError(t, l.Set("1, 4"))
+	assert.NoError(t, l.Set("2"))
+	assert.Equal(t, brokerList{1, 4, 2}, l)
+	assert.Equal(t, "1,4,2", l.String())
+	assert.EqualError(t, l.Set("x"), `invalid broker ID "x"`)
+}
+
+func TestConfigMap(t *testing.T) {
+	m := make(configMap)
+	assert.NoError(t, m.Set("min.insync.replicas=2"))
+	assert.NoError(t, m.Set("cleanup.policy=compact,delete"))
+	assert.Equal(t, configMap{"min.insync.replicas": "2", "cleanup.policy": "compact,delete"}, m)
+	assert.Equal(t, "cleanup.policy=compact,delete,min.insync.replicas=2", m.String())
+	assert.EqualError(t, m.Set("retention.ms"), `invalid config "retention.ms" (want key=value)`)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"kafka-rack-awareness/leaders"
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/reassign"
+	"kafka-rack-awareness/source"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+func runPinLeaders(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("pin-leaders", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	var topics stringList
+	fs.Var(&topics, "topic", "topic whose leaders to pin (repeatable, required)")
+	dryRun := fs.Bool("dry-run", false, "print the moves without executing them")
+	output := fs.String("output", "", "also write the plan as kafka-reassign-partitions JSON to this file")
+	rollbackFile := fs.String("rollback-output", "", "write the rollback plan to this file instead of stdout")
+	poll := fs.Duration("poll", 5*time.Second, "how often to poll reassignment progress")
+	batchSize := fs.Int("batch-size", 10, "partitions to elect per request")
+	batchInterval := fs.Duration("batch-interval", time.Second, "pause between election requests")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: rackctl pin-leaders -topic <topic> [flags] <rack>")
+	}
+	rack := fs.Arg(0)
+	if len(topics) == 0 {
+		return fmt.Errorf("-topic is required")
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admin := kadm.NewClient(client)
+	src := source.NewFranz(client)
+
+	snapCtx, cancel := context.WithTimeout(ctx, cf.timeout)
+	cluster, err := src.Snapshot(snapCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	p, err := planner.New(cluster.Brokers, planner.WithLoad(planner.LoadOf(cluster)))
+	if err != nil {
+		return err
+	}
+	var moves []planner.Move
+	for _, topic := range topics {
+		m, err := p.PinLeaders(cluster, topic, rack)
+		if err != nil {
+			return err
+		}
+		moves = append(moves, m...)
+	}
+	plan := reassign.FromMoves(moves...)
+
+	if err := writeMoveTable(stdout, cluster, plan); err != nil {
+		return err
+	}
+	if *output != "" {
+		if err := plan.WriteFile(*output); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Wrote %d partition(s) to %s\n", len(plan.Partitions), *output)
+	}
+	if *dryRun {
+		return nil
+	}
+
+	if len(plan.Partitions) > 0 {
+		exec := reassign.NewExecutor(admin, src,
+			reassign.WithPollInterval(*poll),
+			reassign.WithProgress(func(p reassign.Progress) { writeProgress(stdout, p) }),
+		)
+		startCtx, cancel := context.WithTimeout(ctx, cf.timeout)
+		rollback, err := exec.Start(startCtx, plan)
+		cancel()
+		if rollback != nil {
+			if werr := writeRollback(stdout, rollback, *rollbackFile); werr != nil && err == nil {
+				err = werr
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if err := exec.Wait(ctx, plan); err != nil {
+			return err
+		}
+	}
+
+	// Reordering replicas only changes the preferred leader; an election
+	// moves leadership there.
+	snapCtx, cancel = context.WithTimeout(ctx, cf.timeout)
+	cluster, err = src.Snapshot(snapCtx, topics...)
+	cancel()
+	if err != nil {
+		return err
+	}
+	var drifted, stuck []leaders.Drift
+	for _, d := range leaders.Analyze(cluster).Drifted {
+		switch {
+		case d.PreferredRack != rack:
+		case d.Electable:
+			drifted = append(drifted, d)
+		default:
+			stuck = append(stuck, d)
+		}
+	}
+	elector := leaders.NewElector(admin,
+		leaders.WithBatchSize(*batchSize),
+		leaders.WithBatchInterval(*batchInterval),
+		leaders.WithBatchProgress(func(rs []leaders.Result) { writeElectionBatch(stdout, rs, false) }),
+	)
+	results, err := elector.Elect(ctx, drifted)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return fmt.Errorf("could not elect %s: %v: %w", r.Drift, r.Err, errFindings)
+		}
+	}
+
+	if len(stuck) > 0 {
+		return fmt.Errorf("%d partition(s) keep their leader until the preferred replica in %s is back in sync: %v: %w", len(stuck), rack, stuck, errFindings)
+	}
+	fmt.Fprintf(stdout, "Leaders of %s pinned to %s\n", topics.String(), rack)
+	return nil
+}
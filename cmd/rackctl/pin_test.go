@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/kafkatest"
+	"kafka-rack-awareness/source"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestPinLeaders(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	defer cl.Close()
+	require.NoError(t, kafkatest.CreateTopic(context.Background(), cl, "orders", nil, oneLeaderPerRack...))
+
+	bootstrap := strings.Join(c.Addrs(), ",")
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"pin-leaders", "-bootstrap", bootstrap, "-topic", "orders", "-dry-run", "rack-b"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "orders")
+	before, err := source.NewFranz(cl).Snapshot(context.Background(), "orders")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"rack-a": 1, "rack-b": 1, "rack-c": 1}, before.LeadersByRack(before.Topics[0].Partitions), "a dry run changes nothing")
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"pin-leaders", "-bootstrap", bootstrap, "-topic", "orders", "-poll", "10ms", "-batch-interval", "1ms", "rack-b"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Leaders of orders pinned to rack-b")
+
+	after, err := source.NewFranz(cl).Snapshot(context.Background(), "orders")
+	require.NoError(t, err)
+	for _, p := range after.Topics[0].Partitions {
+		assert.Equal(t, "rack-b", after.RackOf(p.Leader), "partition %d", p.ID)
+		assert.Equal(t, 3, after.RackSpread(p), "partition %d keeps its spread", p.ID)
+	}
+
+	stderr.Reset()
+	code = run(context.Background(), []string{"pin-leaders", "-bootstrap", bootstrap, "rack-b"}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), "-topic is required")
+}
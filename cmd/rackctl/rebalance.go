@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/reassign"
+	"kafka-rack-awareness/source"
+	"kafka-rack-awareness/topology"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+const (
+	byAuto     = "auto"
+	byReplicas = "replicas"
+	byBytes    = "bytes"
+)
+
+func runRebalance(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("rebalance", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	var hf hierarchyFlags
+	hf.register(fs)
+	by := fs.String("by", byAuto, "balance replicas, bytes (from DescribeLogDirs), or auto: bytes when log dirs can be described")
+	tolerance := fs.Float64("tolerance", 0.1, "fraction below the mean load at which a broker counts as under-loaded")
+	output := fs.String("output", "", "write the moves as kafka-reassign-partitions JSON to this file (- for stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch *by {
+	case byAuto, byReplicas, byBytes:
+	default:
+		return fmt.Errorf("unknown -by %q (want auto, replicas or bytes)", *by)
+	}
+	hierarchy, err := hf.hierarchy()
+	if err != nil {
+		return err
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admin := kadm.NewClient(client)
+
+	ctx, cancel := context.WithTimeout(ctx, cf.timeout)
+	defer cancel()
+	cluster, err := source.NewFranz(client).Snapshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	var sizes topology.ReplicaSizes
+	if *by != byReplicas {
+		sizes, err = source.LogDirSizes(ctx, admin)
+		if err != nil {
+			if *by == byBytes {
+				return err
+			}
+			fmt.Fprintf(stdout, "Balancing by replica count: %v\n", err)
+			sizes = nil
+		}
+	}
+
+	popts := []planner.Option{planner.WithLoad(planner.LoadOf(cluster))}
+	if hierarchy != nil {
+		popts = append(popts, planner.WithHierarchy(hierarchy))
+	}
+	p, err := planner.New(cluster.Brokers, popts...)
+	if err != nil {
+		return err
+	}
+	opts := []planner.RebalanceOption{planner.WithTolerance(*tolerance)}
+	if sizes != nil {
+		opts = append(opts, planner.WithSizes(sizes))
+	}
+	moves := p.Rebalance(cluster, opts...)
+	plan := reassign.FromMoves(moves...)
+
+	if *output == "-" {
+		return plan.Write(stdout)
+	}
+	if err := writeUsageTable(stdout, planner.Usage(cluster, sizes), planner.Usage(plan.Project(cluster), sizes), sizes != nil); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout)
+	if err := writeMoveTable(stdout, cluster, plan); err != nil {
+		return err
+	}
+	if *output != "" {
+		if err := plan.WriteFile(*output); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Wrote %d partition(s) to %s\n", len(plan.Partitions), *output)
+	}
+	return nil
+}
+
+func writeUsageTable(w io.Writer, before, after []planner.BrokerUsage, bytes bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if bytes {
+		fmt.Fprintln(tw, "BROKER\tRACK\tREPLICAS\tAFTER\tBYTES\tAFTER")
+	} else {
+		fmt.Fprintln(tw, "BROKER\tRACK\tREPLICAS\tAFTER")
+	}
+	for i, b := range before {
+		a := after[i]
+		if bytes {
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%d\n", b.Broker, dash(b.Rack), b.Replicas, a.Replicas, b.Bytes, a.Bytes)
+		} else {
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%d\n", b.Broker, dash(b.Rack), b.Replicas, a.Replicas)
+		}
+	}
+	return tw.Flush()
+}
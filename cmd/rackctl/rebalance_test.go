@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"kafka-rack-awareness/planner"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteUsageTable(t *testing.T) {
+	before := []planner.BrokerUsage{{Broker: 1, Rack: "rack-a", Replicas: 6, Bytes: 600}, {Broker: 7}}
+	after := []planner.BrokerUsage{{Broker: 1, Rack: "rack-a", Replicas: 4, Bytes: 400}, {Broker: 7, Replicas: 2, Bytes: 200}}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeUsageTable(&buf, before, after, false))
+	assert.Regexp(t, `(?m)^7\s+-\s+0\s+2$`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, writeUsageTable(&buf, before, after, true))
+	assert.Regexp(t, `(?m)^1\s+rack-a\s+6\s+4\s+600\s+400$`, buf.String())
+}
+
+func TestRebalanceRejectsUnknownMode(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(context.Background(), []string{"rebalance", "-by", "cpu"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown -by "cpu"`)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"kafka-rack-awareness/snapshot"
+	"kafka-rack-awareness/source"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+func runSnapshot(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	var topics stringList
+	fs.Var(&topics, "topic", "topic to save (repeatable; default all topics, internal ones included)")
+	output := fs.String("output", "", "write the snapshot to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admin := kadm.NewClient(client)
+
+	snapCtx, cancel := context.WithTimeout(ctx, cf.timeout)
+	defer cancel()
+	cluster, err := source.NewFranz(client).Snapshot(snapCtx, topics...)
+	if err != nil {
+		return err
+	}
+	if len(cluster.Topics) > 0 {
+		if err := source.TopicConfigs(snapCtx, admin, cluster); err != nil {
+			return err
+		}
+	}
+
+	s := &snapshot.Snapshot{TakenAt: time.Now().UTC(), Cluster: cluster}
+	if *output == "" {
+		return snapshot.Write(stdout, s)
+	}
+	if err := snapshot.WriteFile(*output, s); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Wrote %d broker(s) and %d topic(s) to %s\n", len(cluster.Brokers), len(cluster.Topics), *output)
+	return nil
+}
+
+func runDiff(_ context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	format := fs.String("format", formatTable, "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: rackctl diff [flags] <old.json> <new.json>")
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	before, err := snapshot.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := snapshot.ReadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	changes := snapshot.Diff(before.Cluster, after.Cluster)
+	if *format == formatJSON {
+		err = writeJSON(stdout, struct {
+			Before time.Time `json:"before"`
+			After  time.Time `json:"after"`
+			*snapshot.Changes
+		}{before.TakenAt, after.TakenAt, changes})
+	} else {
+		err = writeDiff(stdout, before, after, changes)
+	}
+	if err != nil {
+		return err
+	}
+	if changes.Len() > 0 {
+		return fmt.Errorf("%d change(s) between snapshots: %w", changes.Len(), errFindings)
+	}
+	return nil
+}
+
+func writeDiff(w io.Writer, before, after *snapshot.Snapshot, ch *snapshot.Changes) error {
+	fmt.Fprintf(w, "Comparing snapshot taken %s with %s\n\n",
+		before.TakenAt.Format(time.RFC3339), after.TakenAt.Format(time.RFC3339))
+	if ch.Len() == 0 {
+		fmt.Fprintln(w, "No changes.")
+		return nil
+	}
+
+	brokerChanges := len(ch.BrokersAdded) + len(ch.BrokersRemoved) + len(ch.RackChanges)
+	if brokerChanges > 0 {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "BROKER\tCHANGE\tBEFORE\tAFTER")
+		for _, b := range ch.BrokersAdded {
+			fmt.Fprintf(tw, "%d\tadded\t-\t%s\n", b.ID, dash(b.Rack))
+		}
+		for _, b := range ch.BrokersRemoved {
+			fmt.Fprintf(tw, "%d\tremoved\t%s\t-\n", b.ID, dash(b.Rack))
+		}
+		for _, r := range ch.RackChanges {
+			fmt.Fprintf(tw, "%d\track changed\t%s\t%s\n", r.Broker, dash(r.From), dash(r.To))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	if ch.Len() > brokerChanges {
+		if err := writeTopicDiff(w, ch); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "%d broker(s) added, %d removed, %d changed rack; %d leader move(s), %d ISR shrink(s), %d reassignment(s)\n",
+		len(ch.BrokersAdded), len(ch.BrokersRemoved), len(ch.RackChanges),
+		len(ch.LeaderMoves), len(ch.ISRShrinks()), len(ch.ReplicaChanges))
+	return nil
+}
+
+func writeTopicDiff(w io.Writer, ch *snapshot.Changes) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tPARTITION\tCHANGE\tBEFORE\tAFTER")
+	for _, t := range ch.TopicsAdded {
+		fmt.Fprintf(tw, "%s\t-\ttopic added\t-\t-\n", t)
+	}
+	for _, t := range ch.TopicsRemoved {
+		fmt.Fprintf(tw, "%s\t-\ttopic removed\t-\t-\n", t)
+	}
+	for _, p := range ch.PartitionCounts {
+		fmt.Fprintf(tw, "%s\t-\tpartitions\t%d\t%d\n", p.Topic, p.From, p.To)
+	}
+	for _, c := range ch.ConfigChanges {
+		fmt.Fprintf(tw, "%s\t-\tconfig %s\t%s\t%s\n", c.Topic, c.Key, dash(c.From), dash(c.To))
+	}
+	for _, m := range ch.LeaderMoves {
+		fmt.Fprintf(tw, "%s\t%d\tleader moved\t%s\t%s\n", m.Topic, m.Partition, leaderIn(m.From, m.FromRack), leaderIn(m.To, m.ToRack))
+	}
+	for _, c := range ch.ISRChanges {
+		change := "ISR expanded"
+		if c.Shrunk() {
+			change = "ISR shrank"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%v %s\t%v %s\n", c.Topic, c.Partition, change,
+			c.From, strings.Join(c.FromRacks, ","), c.To, strings.Join(c.ToRacks, ","))
+	}
+	for _, r := range ch.ReplicaChanges {
+		fmt.Fprintf(tw, "%s\t%d\treplicas changed\t%v\t%v\n", r.Topic, r.Partition, r.From, r.To)
+	}
+	return tw.Flush()
+}
+
+// leaderIn describes a leader and its rack, or an offline partition.
+func leaderIn(id int32, rack string) string {
+	if id < 0 {
+		return "offline"
+	}
+	return fmt.Sprintf("%d (%s)", id, dash(rack))
+}
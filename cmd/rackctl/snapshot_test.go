@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/kafkatest"
+	"kafka-rack-awareness/snapshot"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestSnapshotAndDiff(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	defer cl.Close()
+	strict := "2"
+	require.NoError(t, kafkatest.CreateTopic(context.Background(), cl, "orders", map[string]*string{"min.insync.replicas": &strict}, oneLeaderPerRack...))
+
+	bootstrap := strings.Join(c.Addrs(), ",")
+	dir := t.TempDir()
+	before, after := filepath.Join(dir, "before.json"), filepath.Join(dir, "after.json")
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"snapshot", "-bootstrap", bootstrap, "-output", before}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Wrote 3 broker(s) and 1 topic(s) to "+before)
+
+	saved, err := snapshot.ReadFile(before)
+	require.NoError(t, err)
+	assert.Equal(t, map[int32]string{0: "rack-a", 1: "rack-b", 2: "rack-c"}, saved.Cluster.BrokerRacks())
+	orders, ok := saved.Cluster.Topic("orders")
+	require.True(t, ok)
+	assert.Len(t, orders.Partitions, 3)
+	assert.Equal(t, "2", orders.Configs["min.insync.replicas"])
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"diff", before, before}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "No changes.")
+
+	// Pinning leaders to rack-b moves two of them.
+	code = run(context.Background(), []string{"pin-leaders", "-bootstrap", bootstrap, "-topic", "orders", "-poll", "10ms", "-batch-interval", "1ms", "rack-b"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	stdout.Reset()
+	code = run(context.Background(), []string{"snapshot", "-bootstrap", bootstrap, "-topic", "orders"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	require.NoError(t, os.WriteFile(after, stdout.Bytes(), 0o644))
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run(context.Background(), []string{"diff", before, after}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	assert.Regexp(t, `orders\s+0\s+leader moved\s+0 \(rack-a\)\s+1 \(rack-b\)`, stdout.String())
+	assert.Contains(t, stdout.String(), "0 broker(s) added, 0 removed, 0 changed rack; 2 leader move(s), 0 ISR shrink(s), 2 reassignment(s)")
+	assert.Contains(t, stderr.String(), "4 change(s) between snapshots")
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"diff", "-format", "json", before, after}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	var changes struct {
+		LeaderMoves []snapshot.LeaderMove `json:"leader_moves"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &changes))
+	assert.Len(t, changes.LeaderMoves, 2)
+	for _, m := range changes.LeaderMoves {
+		assert.Equal(t, "rack-b", m.ToRack)
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(context.Background(), []string{"diff", "old.json"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "usage: rackctl diff")
+
+	stderr.Reset()
+	missing := filepath.Join(t.TempDir(), "missing.json")
+	assert.Equal(t, 2, run(context.Background(), []string{"diff", missing, missing}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "no such file")
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"kafka-rack-awareness/policy"
+	"kafka-rack-awareness/readiness"
+	"kafka-rack-awareness/reassign"
+	"kafka-rack-awareness/source"
+	"kafka-rack-awareness/spec"
+	"kafka-rack-awareness/topology"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func runPlan(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	var hf hierarchyFlags
+	hf.register(fs)
+	specFile := fs.String("spec", "", "YAML topic spec file (required)")
+	format := fs.String("format", formatTable, "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	hierarchy, err := hf.hierarchy()
+	if err != nil {
+		return err
+	}
+
+	_, plan, err := cf.specPlan(ctx, *specFile, hierarchy)
+	if err != nil {
+		return err
+	}
+	if *format == formatJSON {
+		err = writeJSON(stdout, planJSON(plan))
+	} else {
+		err = writePlan(stdout, plan)
+	}
+	if err != nil {
+		return err
+	}
+	if plan.Len() > 0 {
+		return fmt.Errorf("%d change(s) pending: %w", plan.Len(), errFindings)
+	}
+	return nil
+}
+
+func runApply(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	var pf policyFlags
+	pf.register(fs)
+	var hf hierarchyFlags
+	hf.register(fs)
+	specFile := fs.String("spec", "", "YAML topic spec file (required)")
+	rollbackFile := fs.String("rollback-output", "", "write the rollback plan for reassignments to this file instead of stdout")
+	poll := fs.Duration("poll", 5*time.Second, "how often to poll reassignment progress")
+	force := fs.Bool("force", false, "reassign even if a partition ends up in fewer racks, e.g. when placement.racks shrinks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	hierarchy, err := hf.hierarchy()
+	if err != nil {
+		return err
+	}
+
+	cluster, plan, err := cf.specPlan(ctx, *specFile, hierarchy)
+	if err != nil {
+		return err
+	}
+	if err := writePlan(stdout, plan); err != nil {
+		return err
+	}
+	if plan.Len() == 0 {
+		return nil
+	}
+	for _, c := range plan.Creations {
+		proposed := policy.Topic{Name: c.Topic.Name, Partitions: c.Topic.Partitions, ReplicationFactor: c.Topic.ReplicationFactor, Configs: c.Topic.Configs}
+		if err := pf.validate(cluster, proposed, c.Assignment); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(stdout)
+
+	for _, c := range plan.Creations {
+		if err := cf.createTopic(ctx, stdout, cluster, c.Assignment, c.Topic.Configs); err != nil {
+			return err
+		}
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	admin := kadm.NewClient(client)
+
+	if len(plan.Configs) > 0 {
+		if err := alterConfigs(ctx, admin, cf.timeout, plan.Configs); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Changed %d config(s)\n", len(plan.Configs))
+	}
+
+	if len(plan.Moves) > 0 {
+		opts := []reassign.ExecutorOption{
+			reassign.WithPollInterval(*poll),
+			reassign.WithProgress(func(p reassign.Progress) { writeProgress(stdout, p) }),
+		}
+		if *force {
+			opts = append(opts, reassign.WithForce())
+		}
+		exec := reassign.NewExecutor(admin, source.NewFranz(client), opts...)
+		moves := reassign.FromMoves(plan.Moves...)
+
+		startCtx, cancel := context.WithTimeout(ctx, cf.timeout)
+		rollback, err := exec.Start(startCtx, moves)
+		cancel()
+		if rollback != nil {
+			if werr := writeRollback(stdout, rollback, *rollbackFile); werr != nil && err == nil {
+				err = werr
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if err := exec.Wait(ctx, moves); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return fmt.Errorf("stopped waiting; the reassignment continues on the cluster (use rackctl cancel to abort it): %w", err)
+			}
+			return err
+		}
+		fmt.Fprintf(stdout, "Reassigned %d partition(s)\n", len(plan.Moves))
+	}
+
+	if len(plan.Additions) > 0 {
+		if err := cf.addPartitions(ctx, admin, plan.Additions); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Added partitions to %d topic(s)\n", len(plan.Additions))
+	}
+	fmt.Fprintln(stdout, "Apply complete.")
+	return nil
+}
+
+// specPlan reads a spec and diffs it against a fresh snapshot that carries
+// the configs the spec sets, planning over the rack hierarchy h if it is
+// not nil.
+func (f *clusterFlags) specPlan(ctx context.Context, path string, h *topology.Hierarchy) (*topology.Cluster, *spec.Plan, error) {
+	if path == "" {
+		return nil, nil, fmt.Errorf("-spec is required")
+	}
+	file, err := spec.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := f.client()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer client.Close()
+	admin := kadm.NewClient(client)
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	cluster, err := source.NewFranz(client).Snapshot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Only describe the configs of topics the spec manages.
+	if keys := file.ConfigKeys(); len(keys) > 0 {
+		managed := topology.NewCluster(cluster.Brokers...)
+		for _, name := range file.Names() {
+			if t, ok := cluster.Topic(name); ok {
+				managed.AddTopic(*t)
+			}
+		}
+		if len(managed.Topics) > 0 {
+			if err := source.TopicConfigs(ctx, admin, managed, keys...); err != nil {
+				return nil, nil, err
+			}
+			for _, t := range managed.Topics {
+				cluster.AddTopic(t)
+			}
+		}
+	}
+
+	var opts []spec.Option
+	if h != nil {
+		opts = append(opts, spec.WithHierarchy(h))
+	}
+	plan, err := spec.Diff(cluster, file, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cluster, plan, nil
+}
+
+func alterConfigs(ctx context.Context, admin *kadm.Client, timeout time.Duration, changes []spec.ConfigChange) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	byTopic := make(map[string][]kadm.AlterConfig)
+	for _, c := range changes {
+		value := c.To
+		byTopic[c.Topic] = append(byTopic[c.Topic], kadm.AlterConfig{Op: kadm.SetConfig, Name: c.Key, Value: &value})
+	}
+	topics := make([]string, 0, len(byTopic))
+	for topic := range byTopic {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	for _, topic := range topics {
+		resps, err := admin.AlterTopicConfigs(ctx, byTopic[topic], topic)
+		if err != nil {
+			return fmt.Errorf("altering configs of %q: %w", topic, err)
+		}
+		for _, r := range resps {
+			if r.Err != nil {
+				return fmt.Errorf("altering configs of %q: %w", r.Name, r.Err)
+			}
+		}
+	}
+	return nil
+}
+
+// addPartitions creates the planned partitions with explicit assignments
+// and waits until they are ready, with the rack spread of the fewest racks
+// any partition of the topic spans.
+func (f *clusterFlags) addPartitions(ctx context.Context, admin *kadm.Client, additions []spec.Addition) error {
+	client, err := f.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	req := kmsg.NewPtrCreatePartitionsRequest()
+	req.TimeoutMillis = int32(f.timeout.Milliseconds())
+	topics := make([]string, 0, len(additions))
+	for _, a := range additions {
+		rt := kmsg.NewCreatePartitionsRequestTopic()
+		rt.Topic, rt.Count = a.Topic, int32(a.To)
+		for _, replicas := range a.Replicas {
+			ra := kmsg.NewCreatePartitionsRequestTopicAssignment()
+			ra.Replicas = replicas
+			rt.Assignment = append(rt.Assignment, ra)
+		}
+		req.Topics = append(req.Topics, rt)
+		topics = append(topics, a.Topic)
+	}
+
+	// Read the partitions the topics already have, as any reassignments
+	// left them, to know the spread to wait for.
+	src := source.NewFranz(client)
+	before, err := src.Snapshot(ctx, topics...)
+	if err != nil {
+		return err
+	}
+
+	resp, err := req.RequestWith(ctx, client)
+	if err != nil {
+		return fmt.Errorf("creating partitions: %w", err)
+	}
+	for _, t := range resp.Topics {
+		if err := kerr.ErrorForCode(t.ErrorCode); err != nil {
+			if t.ErrorMessage != nil {
+				err = fmt.Errorf("%w (%s)", err, *t.ErrorMessage)
+			}
+			return fmt.Errorf("creating partitions of %q: %w", t.Topic, err)
+		}
+	}
+
+	for _, a := range additions {
+		replicas := append([][]int32(nil), a.Replicas...)
+		if t, ok := before.Topic(a.Topic); ok {
+			for _, p := range t.Partitions {
+				replicas = append(replicas, p.Replicas)
+			}
+		}
+		spread := minRackSpread(before.BrokerRacks(), replicas)
+		if err := readiness.WaitForTopicReady(ctx, src, []string{a.Topic}, readiness.WithRackSpread(spread)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePlan(w io.Writer, plan *spec.Plan) error {
+	if plan.Len() == 0 {
+		_, err := fmt.Fprintln(w, "No changes; the cluster matches the spec.")
+		return err
+	}
+	for _, c := range plan.Creations {
+		fmt.Fprintf(w, "+ create %s: %d partition(s), RF %d", c.Topic.Name, c.Topic.Partitions, c.Topic.ReplicationFactor)
+		if len(c.Topic.Configs) > 0 {
+			fmt.Fprintf(w, ", %s", configMap(c.Topic.Configs))
+		}
+		fmt.Fprintln(w)
+	}
+	for _, c := range plan.Configs {
+		fmt.Fprintf(w, "~ config %s %s: %s -> %s\n", c.Topic, c.Key, dash(c.From), c.To)
+	}
+	for _, m := range plan.Moves {
+		fmt.Fprintf(w, "~ reassign %s-%d: %v -> %v\n", m.Topic, m.Partition, m.From, m.To)
+	}
+	for _, a := range plan.Additions {
+		fmt.Fprintf(w, "+ partitions %s: %d -> %d\n", a.Topic, a.From, a.To)
+	}
+	_, err := fmt.Fprintf(w, "\nPlan: %d to create, %d config change(s), %d reassignment(s), %d topic(s) to grow\n",
+		len(plan.Creations), len(plan.Configs), len(plan.Moves), len(plan.Additions))
+	return err
+}
+
+func planJSON(plan *spec.Plan) any {
+	type creation struct {
+		Topic    string            `json:"topic"`
+		Replicas [][]int32         `json:"replicas"`
+		Configs  map[string]string `json:"configs,omitempty"`
+	}
+	type move struct {
+		Topic     string  `json:"topic"`
+		Partition int32   `json:"partition"`
+		From      []int32 `json:"from"`
+		To        []int32 `json:"to"`
+	}
+	type config struct {
+		Topic string `json:"topic"`
+		Key   string `json:"key"`
+		From  string `json:"from"`
+		To    string `json:"to"`
+	}
+	type addition struct {
+		Topic    string    `json:"topic"`
+		From     int       `json:"from"`
+		To       int       `json:"to"`
+		Replicas [][]int32 `json:"replicas"`
+	}
+	out := struct {
+		Creations []creation `json:"creations"`
+		Configs   []config   `json:"configs"`
+		Moves     []move     `json:"reassignments"`
+		Additions []addition `json:"additions"`
+	}{[]creation{}, []config{}, []move{}, []addition{}}
+	for _, c := range plan.Creations {
+		out.Creations = append(out.Creations, creation{c.Topic.Name, c.Assignment.Replicas, c.Topic.Configs})
+	}
+	for _, c := range plan.Configs {
+		out.Configs = append(out.Configs, config{c.Topic, c.Key, c.From, c.To})
+	}
+	for _, m := range plan.Moves {
+		out.Moves = append(out.Moves, move{m.Topic, m.Partition, m.From, m.To})
+	}
+	for _, a := range plan.Additions {
+		out.Additions = append(out.Additions, addition{a.Topic, a.From, a.To, a.Replicas})
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/kafkatest"
+	"kafka-rack-awareness/source"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+const topicSpec = `topics:
+  - name: orders
+    partitions: 4
+    replicationFactor: 3
+    configs:
+      min.insync.replicas: "2"
+  - name: payments
+    partitions: 2
+    replicationFactor: 3
+`
+
+func TestPlanAndApply(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c", "rack-a")
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	defer cl.Close()
+	admin := kadm.NewClient(cl)
+	_, err = admin.CreateTopic(context.Background(), 2, 2, nil, "orders")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "topics.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(topicSpec), 0o644))
+	bootstrap := strings.Join(c.Addrs(), ",")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"plan", "-bootstrap", bootstrap, "-spec", path}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	out := stdout.String()
+	assert.Contains(t, out, "+ create payments: 2 partition(s), RF 3\n")
+	assert.Contains(t, out, "~ config orders min.insync.replicas: 1 -> 2\n")
+	assert.Contains(t, out, "~ reassign orders-0: ")
+	assert.Contains(t, out, "+ partitions orders: 2 -> 4\n")
+	assert.Contains(t, out, "Plan: 1 to create, 1 config change(s), 2 reassignment(s), 1 topic(s) to grow")
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run(context.Background(), []string{"apply", "-bootstrap", bootstrap, "-spec", path, "-poll", "10ms"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Apply complete.")
+
+	snap, err := source.NewFranz(cl).Snapshot(context.Background(), "orders", "payments")
+	require.NoError(t, err)
+	for _, topic := range snap.Topics {
+		for _, p := range topic.Partitions {
+			assert.Len(t, p.Replicas, 3, "%s-%d", topic.Name, p.ID)
+			assert.Equal(t, 3, snap.RackSpread(p), "%s-%d", topic.Name, p.ID)
+		}
+	}
+	orders, _ := snap.Topic("orders")
+	assert.Len(t, orders.Partitions, 4)
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"plan", "-bootstrap", bootstrap, "-spec", path}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "No changes; the cluster matches the spec.\n", stdout.String())
+}
+
+func TestApplyValidatesCreations(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	path := filepath.Join(t.TempDir(), "topics.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("topics:\n  - {name: orders, partitions: 1, replicationFactor: 3, configs: {min.insync.replicas: \"3\"}}\n"), 0o644))
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"apply", "-bootstrap", strings.Join(c.Addrs(), ","), "-spec", path}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), `topic "orders" rejected: min.insync.replicas 3 equals replication factor 3`)
+	assert.NotContains(t, stdout.String(), "Created topic")
+}
+
+func TestApplyValidatesPlacement(t *testing.T) {
+	// Limited to two racks, RF 3 puts two replicas in one of them, which
+	// min.insync.replicas=2 cannot lose, even though three racks exist.
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c", "rack-a")
+	path := filepath.Join(t.TempDir(), "topics.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("topics:\n  - {name: orders, partitions: 2, replicationFactor: 3, "+
+		"configs: {min.insync.replicas: \"2\"}, placement: {racks: [rack-a, rack-b]}}\n"), 0o644))
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"apply", "-bootstrap", strings.Join(c.Addrs(), ","), "-spec", path}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	assert.Contains(t, stderr.String(), "losing rack-a")
+	assert.NotContains(t, stdout.String(), "Created topic")
+}
+
+func TestApplyWaitsForPlacedSpread(t *testing.T) {
+	// Limited to two racks, the topic can never span the three racks a
+	// default readiness wait would ask for.
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c", "rack-a")
+	path := filepath.Join(t.TempDir(), "topics.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("topics:\n  - {name: orders, partitions: 2, replicationFactor: 3, placement: {racks: [rack-a, rack-b]}}\n"), 0o644))
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"apply", "-bootstrap", strings.Join(c.Addrs(), ","), "-spec", path, "-timeout", "5s"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Created topic orders")
+}
+
+func TestPlanNeedsSpec(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(context.Background(), []string{"plan", "-bootstrap", "127.0.0.1:1"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "-spec is required")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"kafka-rack-awareness/failure"
+)
+
+func runFaultTolerance(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("fault-tolerance", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	var sf scopeFlags
+	sf.register(fs)
+	by := fs.String("by", string(failure.ByRack), "failure domain: rack or broker")
+	maxFailures := fs.Int("max-failures", 2, "largest number of concurrent rack or broker failures to try")
+	require := fs.Int("require", 0, "exit 1 if any topic tolerates fewer failures than this for acks=all writes")
+	format := fs.String("format", formatTable, "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	domain, err := failure.ParseDomain(*by)
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	cluster, opts, err := sf.snapshot(ctx, &cf)
+	if err != nil {
+		return err
+	}
+	// Proving a topic survives -require failures means trying that many.
+	tols, err := failure.Tolerances(cluster, domain, max(*maxFailures, *require), opts...)
+	if err != nil {
+		return err
+	}
+	if *format == formatJSON {
+		err = writeJSON(stdout, toleranceReport(domain, tols))
+	} else {
+		err = writeToleranceTable(stdout, domain, tols)
+	}
+	if err != nil {
+		return err
+	}
+
+	var weak []string
+	for _, t := range tols {
+		if n, _ := t.Writes(); n < *require {
+			weak = append(weak, t.Topic)
+		}
+	}
+	if len(weak) > 0 {
+		return fmt.Errorf("%d topic(s) tolerate fewer than %d %s failure(s): %v: %w",
+			len(weak), *require, domain, weak, errFindings)
+	}
+	return nil
+}
+
+// toleranceJSON adds the table's tolerance columns to a topic's JSON. The
+// exact fields are false when the value is only a lower bound.
+type toleranceJSON struct {
+	failure.Tolerance
+	WriteTolerance  int  `json:"write_tolerance"`
+	WriteExact      bool `json:"write_tolerance_exact"`
+	OnlineTolerance int  `json:"online_tolerance"`
+	OnlineExact     bool `json:"online_tolerance_exact"`
+}
+
+func toleranceReport(domain failure.Domain, tols []failure.Tolerance) any {
+	topics := make([]toleranceJSON, 0, len(tols))
+	for _, t := range tols {
+		j := toleranceJSON{Tolerance: t}
+		j.WriteTolerance, j.WriteExact = t.Writes()
+		j.OnlineTolerance, j.OnlineExact = t.Availability()
+		topics = append(topics, j)
+	}
+	return struct {
+		Domain failure.Domain  `json:"domain"`
+		Topics []toleranceJSON `json:"topics"`
+	}{domain, topics}
+}
+
+func writeToleranceTable(w io.Writer, domain failure.Domain, tols []failure.Tolerance) error {
+	if len(tols) == 0 {
+		fmt.Fprintln(w, "No topics to check.")
+		return nil
+	}
+	fmt.Fprintf(w, "Trying every combination of up to %d failed %s(s)\n\n", tols[0].Bound, domain)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tWRITES SURVIVE\tFIRST UNWRITABLE\tSTAYS ONLINE\tFIRST OFFLINE")
+	weakest, weakestExact := tols[0].Writes()
+	for _, t := range tols {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", t.Topic,
+			tolerates(t.Writes()), failureSet(t.Unwritable),
+			tolerates(t.Availability()), failureSet(t.Offline))
+		if n, exact := t.Writes(); n < weakest || (n == weakest && exact) {
+			weakest, weakestExact = n, exact
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nacks=all writes survive %s %s failure(s) on every topic\n", tolerates(weakest, weakestExact), domain)
+	return nil
+}
+
+// tolerates formats a tolerance, marking lower bounds with ">=".
+func tolerates(n int, exact bool) string {
+	if exact {
+		return fmt.Sprint(n)
+	}
+	return fmt.Sprintf(">=%d", n)
+}
+
+func failureSet(s *failure.FailureSet) string {
+	if s == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s (partitions %v)", s, s.Partitions)
+}
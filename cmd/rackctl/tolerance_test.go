@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/kafkatest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestFaultTolerance(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	defer cl.Close()
+	two := "2"
+	_, err = kadm.NewClient(cl).CreateTopic(context.Background(), 3, 3, map[string]*string{"min.insync.replicas": &two}, "orders")
+	require.NoError(t, err)
+
+	bootstrap := strings.Join(c.Addrs(), ",")
+	var stdout, stderr bytes.Buffer
+
+	// One rack can go; two block acks=all writes, and no pair of racks
+	// takes a partition offline.
+	code := run(context.Background(), []string{"fault-tolerance", "-bootstrap", bootstrap}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Trying every combination of up to 2 failed rack(s)")
+	assert.Regexp(t, `orders\s+1\s+rack-a,rack-b \(partitions \[0 1 2\]\)\s+>=2\s+-`, stdout.String())
+	assert.Contains(t, stdout.String(), "acks=all writes survive 1 rack failure(s) on every topic")
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"fault-tolerance", "-bootstrap", bootstrap, "-by", "broker", "-max-failures", "3", "-format", "json"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	var report struct {
+		Domain string `json:"domain"`
+		Topics []struct {
+			Topic   string `json:"topic"`
+			Offline struct {
+				Brokers []int32 `json:"brokers"`
+			} `json:"offline"`
+			OnlineTolerance int  `json:"online_tolerance"`
+			OnlineExact     bool `json:"online_tolerance_exact"`
+		} `json:"topics"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, "broker", report.Domain)
+	require.Len(t, report.Topics, 1)
+	assert.Equal(t, []int32{0, 1, 2}, report.Topics[0].Offline.Brokers)
+	assert.Equal(t, 2, report.Topics[0].OnlineTolerance)
+	assert.True(t, report.Topics[0].OnlineExact)
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"fault-tolerance", "-bootstrap", bootstrap, "-require", "2"}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	assert.Contains(t, stderr.String(), "1 topic(s) tolerate fewer than 2 rack failure(s): [orders]")
+}
+
+func TestFaultToleranceFlags(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(context.Background(), []string{"fault-tolerance", "-by", "zone"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), `unknown failure domain "zone"`)
+}
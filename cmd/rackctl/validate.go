@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/policy"
+	"kafka-rack-awareness/topology"
+)
+
+// policyFlags configure the policy proposed topics are checked against.
+type policyFlags struct {
+	p policy.Policy
+}
+
+func (f *policyFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&f.p.MinReplicationFactor, "min-replication-factor", 0, "reject topics with a lower replication factor")
+	fs.IntVar(&f.p.MinInSync, "min-insync", 0, "reject topics with a lower min.insync.replicas")
+	fs.IntVar(&f.p.MaxPartitions, "max-partitions", 0, "reject topics with more partitions (0 for no limit)")
+	fs.BoolVar(&f.p.AllowRFAboveRacks, "allow-rf-above-racks", false, "accept replication factors larger than the rack count")
+	fs.BoolVar(&f.p.AllowNoSpareISR, "allow-no-spare-isr", false, "accept min.insync.replicas equal to the replication factor")
+	fs.BoolVar(&f.p.AllowRackLoss, "allow-rack-loss", false, "accept topics that stop taking acks=all writes when one rack fails")
+}
+
+func runValidateTopic(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("validate-topic", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	var pf policyFlags
+	pf.register(fs)
+	var hf hierarchyFlags
+	hf.register(fs)
+	topic := fs.String("topic", "", "name of the proposed topic (required)")
+	partitions := fs.Int("partitions", 1, "number of partitions")
+	rf := fs.Int("replication-factor", 3, "replication factor")
+	configs := make(configMap)
+	fs.Var(configs, "config", "topic config as key=value, such as min.insync.replicas=2 (repeatable)")
+	format := fs.String("format", formatTable, "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *topic == "" {
+		return fmt.Errorf("-topic is required")
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+	hierarchy, err := hf.hierarchy()
+	if err != nil {
+		return err
+	}
+	pf.p.Hierarchy = hierarchy
+
+	cluster, err := cf.snapshot(ctx)
+	if err != nil {
+		return err
+	}
+	proposed := policy.Topic{Name: *topic, Partitions: *partitions, ReplicationFactor: *rf, Configs: configs}
+	reasons := pf.p.Check(cluster, proposed)
+
+	if *format == formatJSON {
+		err = writeJSON(stdout, struct {
+			Topic   policy.Topic    `json:"topic"`
+			Valid   bool            `json:"valid"`
+			Reasons []policy.Reason `json:"reasons"`
+		}{proposed, len(reasons) == 0, append([]policy.Reason{}, reasons...)})
+	} else {
+		err = writeValidationTable(stdout, cluster, proposed, reasons)
+	}
+	if err != nil {
+		return err
+	}
+	if len(reasons) > 0 {
+		return fmt.Errorf("%d reason(s) to reject topic %s: %w", len(reasons), *topic, errFindings)
+	}
+	return nil
+}
+
+func writeValidationTable(w io.Writer, c *topology.Cluster, t policy.Topic, reasons []policy.Reason) error {
+	if len(reasons) == 0 {
+		fmt.Fprintf(w, "Topic %s can be created: %d partition(s), RF %d on %d broker(s) in %d rack(s)\n",
+			t.Name, t.Partitions, t.ReplicationFactor, len(c.Brokers), len(c.RackNames()))
+		return nil
+	}
+	fmt.Fprintf(w, "Topic %s would be rejected:\n\n", t.Name)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tREASON")
+	for _, r := range reasons {
+		fmt.Fprintf(tw, "%s\t%s\n", r.Rule, r.Message)
+	}
+	return tw.Flush()
+}
+
+// validate checks a topic about to be created with assignment a against
+// the policy. A rejection is reported as findings so rackctl exits 1.
+func (f *policyFlags) validate(c *topology.Cluster, t policy.Topic, a planner.Assignment) error {
+	err := f.p.ValidateAssignment(c, t, a)
+	var rejection *policy.Rejection
+	if errors.As(err, &rejection) {
+		return fmt.Errorf("%w: %w", err, errFindings)
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/kafkatest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestValidateTopic(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	bootstrap := strings.Join(c.Addrs(), ",")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"validate-topic", "-bootstrap", bootstrap, "-topic", "orders",
+		"-partitions", "6", "-config", "min.insync.replicas=2"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "Topic orders can be created: 6 partition(s), RF 3 on 3 broker(s) in 3 rack(s)\n", stdout.String())
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"validate-topic", "-bootstrap", bootstrap, "-topic", "orders",
+		"-replication-factor", "4", "-config", "min.insync.replicas=4"}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout.String(), "Topic orders would be rejected:")
+	assert.Regexp(t, `rf-exceeds-brokers\s+replication factor 4 exceeds 3 broker\(s\)`, stdout.String())
+	assert.Contains(t, stdout.String(), "no-spare-isr")
+	assert.Contains(t, stderr.String(), "2 reason(s) to reject topic orders")
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"validate-topic", "-bootstrap", bootstrap, "-topic", "orders",
+		"-min-replication-factor", "3", "-replication-factor", "2", "-format", "json"}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	var report struct {
+		Valid   bool `json:"valid"`
+		Reasons []struct {
+			Rule string `json:"rule"`
+		} `json:"reasons"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.False(t, report.Valid)
+	require.Len(t, report.Reasons, 1)
+	assert.Equal(t, "rf-below-minimum", report.Reasons[0].Rule)
+}
+
+func TestAssignCreateValidates(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	bootstrap := strings.Join(c.Addrs(), ",")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"assign", "-bootstrap", bootstrap, "-topic", "orders", "-create",
+		"-config", "min.insync.replicas=3"}, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), `topic "orders" rejected: min.insync.replicas 3 equals replication factor 3`)
+
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	defer cl.Close()
+	admin := kadm.NewClient(cl)
+	topics, err := admin.ListTopics(context.Background())
+	require.NoError(t, err)
+	assert.False(t, topics.Has("orders"), "rejected topic must not be created")
+
+	stderr.Reset()
+	code = run(context.Background(), []string{"assign", "-bootstrap", bootstrap, "-topic", "orders", "-create",
+		"-config", "min.insync.replicas=2"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	configs, err := admin.DescribeTopicConfigs(context.Background(), "orders")
+	require.NoError(t, err)
+	config, err := configs.On("orders", nil)
+	require.NoError(t, err)
+	var minISR string
+	for _, cfg := range config.Configs {
+		if cfg.Key == "min.insync.replicas" {
+			minISR = cfg.MaybeValue()
+		}
+	}
+	assert.Equal(t, "2", minISR)
+}
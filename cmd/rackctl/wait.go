@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"kafka-rack-awareness/readiness"
+	"kafka-rack-awareness/source"
+)
+
+func runWait(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("wait", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	brokers := fs.Int("brokers", 1, "wait until at least this many brokers are registered")
+	racks := fs.Int("racks", 0, "wait until the brokers span at least this many racks")
+	var topics stringList
+	fs.Var(&topics, "topic", "also wait for this topic to be ready (repeatable)")
+	spread := fs.Int("rack-spread", 0, "racks every partition of -topic must span (default min(RF, racks))")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := cf.client()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	src := source.NewFranz(client)
+
+	ctx, cancel := context.WithTimeout(ctx, cf.timeout)
+	defer cancel()
+
+	if err := readiness.WaitForClusterReady(ctx, src, readiness.WithBrokers(*brokers), readiness.WithRacks(*racks)); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "Cluster is ready")
+	if len(topics) == 0 {
+		return nil
+	}
+	if err := readiness.WaitForTopicReady(ctx, src, topics, readiness.WithRackSpread(*spread)); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Topic(s) %s are ready\n", topics.String())
+	return nil
+}
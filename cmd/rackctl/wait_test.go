@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/kafkatest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestWaitAndCreate(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	bootstrap := strings.Join(c.Addrs(), ",")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"wait", "-bootstrap", bootstrap, "-brokers", "3", "-racks", "3"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "Cluster is ready\n", stdout.String())
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"assign", "-bootstrap", bootstrap, "-topic", "orders", "-partitions", "3", "-create"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Created topic orders; all 3 partition(s) are led and fully in sync")
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"wait", "-bootstrap", bootstrap, "-topic", "orders", "-rack-spread", "3"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Topic(s) orders are ready")
+}
+
+func TestWaitTimesOut(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b")
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{"wait", "-bootstrap", strings.Join(c.Addrs(), ","), "-racks", "3", "-timeout", "200ms"}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), "brokers span 2 of 3 rack(s)")
+}
+
+func TestWaitReportsShrunkISR(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	defer cl.Close()
+	require.NoError(t, kafkatest.CreateTopic(context.Background(), cl, "orders", nil, oneLeaderPerRack...))
+	c.SetISR("orders", 1, 1)
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"wait", "-bootstrap", strings.Join(c.Addrs(), ","), "-topic", "orders", "-timeout", "200ms"}, &stdout, &stderr)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr.String(), "orders-1 ISR [1] is missing replicas of [1 2 0]")
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"kafka-rack-awareness/failure"
+	"kafka-rack-awareness/source"
+	"kafka-rack-awareness/topology"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+func runWhatIf(ctx context.Context, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("what-if", flag.ContinueOnError)
+	var cf clusterFlags
+	cf.register(fs)
+	var sf scopeFlags
+	sf.register(fs)
+	var racks stringList
+	var brokers brokerList
+	fs.Var(&racks, "fail-rack", "rack to take down (repeatable)")
+	fs.Var(&brokers, "fail-broker", "broker ID to take down (repeatable)")
+	format := fs.String("format", formatTable, "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(racks) == 0 && len(brokers) == 0 {
+		return fmt.Errorf("-fail-rack or -fail-broker is required")
+	}
+	if err := checkFormat(*format); err != nil {
+		return err
+	}
+
+	cluster, opts, err := sf.snapshot(ctx, &cf)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, failure.FailRacks(racks...), failure.FailBrokers(brokers...))
+	result, err := failure.Simulate(cluster, opts...)
+	if err != nil {
+		return err
+	}
+	if *format == formatJSON {
+		err = writeJSON(stdout, whatIfJSON(result))
+	} else {
+		err = writeWhatIfTable(stdout, result)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !result.Survives() {
+		return fmt.Errorf("%d partition(s) offline, %d below min.insync.replicas: %w",
+			len(result.Offline()), len(result.BelowMinInSync()), errFindings)
+	}
+	return nil
+}
+
+// scopeFlags pick the topics a failure analysis covers and the
+// min.insync.replicas it assumes.
+type scopeFlags struct {
+	topics    stringList
+	internal  bool
+	minInSync int
+}
+
+func (f *scopeFlags) register(fs *flag.FlagSet) {
+	fs.Var(&f.topics, "topic", "topic to check (repeatable; default all topics)")
+	fs.BoolVar(&f.internal, "include-internal", false, "also check internal topics such as __consumer_offsets")
+	fs.IntVar(&f.minInSync, "min-insync", 0, "min.insync.replicas to assume for every topic instead of reading topic configs")
+}
+
+// snapshot takes a snapshot of the selected topics with their
+// min.insync.replicas configs, or returns the option that overrides them.
+func (f *scopeFlags) snapshot(ctx context.Context, cf *clusterFlags) (*topology.Cluster, []failure.Option, error) {
+	client, err := cf.client()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer client.Close()
+	admin := kadm.NewClient(client)
+
+	ctx, cancel := context.WithTimeout(ctx, cf.timeout)
+	defer cancel()
+	cluster, err := source.NewFranz(client).Snapshot(ctx, f.topics...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(f.topics) == 0 && !f.internal {
+		cluster = withoutInternal(cluster)
+	}
+
+	if f.minInSync > 0 {
+		return cluster, []failure.Option{failure.WithMinInSync(f.minInSync)}, nil
+	}
+	if err := source.TopicConfigs(ctx, admin, cluster, failure.MinInSyncConfig); err != nil {
+		return nil, nil, err
+	}
+	return cluster, nil, nil
+}
+
+// withoutInternal returns c without its internal topics.
+func withoutInternal(c *topology.Cluster) *topology.Cluster {
+	out := topology.NewCluster(c.Brokers...)
+	out.ID = c.ID
+	for _, t := range c.Topics {
+		if !t.Internal {
+			out.AddTopic(t)
+		}
+	}
+	return out
+}
+
+// impactJSON adds the status column of the table to a partition's JSON.
+type impactJSON struct {
+	failure.Impact
+	Status string `json:"status"`
+}
+
+func whatIfJSON(r *failure.Result) any {
+	partitions := make([]impactJSON, 0, len(r.Partitions))
+	for _, i := range r.Partitions {
+		partitions = append(partitions, impactJSON{Impact: i, Status: i.Status()})
+	}
+	return struct {
+		FailedRacks   []string     `json:"failed_racks"`
+		FailedBrokers []int32      `json:"failed_brokers"`
+		Survives      bool         `json:"survives"`
+		Partitions    []impactJSON `json:"partitions"`
+	}{r.FailedRacks, r.FailedBrokers, r.Survives(), partitions}
+}
+
+func writeWhatIfTable(w io.Writer, r *failure.Result) error {
+	if len(r.FailedRacks) > 0 {
+		fmt.Fprintf(w, "Failing rack(s) %v takes down broker(s) %v\n\n", r.FailedRacks, r.FailedBrokers)
+	} else {
+		fmt.Fprintf(w, "Failing broker(s) %v\n\n", r.FailedBrokers)
+	}
+	if len(r.Partitions) == 0 {
+		fmt.Fprintln(w, "No partitions have replicas there.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOPIC\tPARTITION\tREPLICAS\tLEADER\tNEW LEADER\tISR AFTER\tSTATUS")
+	for _, i := range r.Partitions {
+		fmt.Fprintf(tw, "%s\t%d\t%v\t%s\t%s\t%v\t%s\n",
+			i.Topic, i.Partition, i.Replicas, optionalID(i.Leader), optionalID(i.NewLeader), i.ISR, i.Status())
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n%d partition(s) affected: %d change leader, %d go offline, %d fall below min.insync.replicas\n",
+		len(r.Partitions), len(r.LeaderMoves()), len(r.Offline()), len(r.BelowMinInSync()))
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/kafkatest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func TestWhatIf(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	defer cl.Close()
+	strict := "3"
+	require.NoError(t, kafkatest.CreateTopic(context.Background(), cl, "orders", map[string]*string{"min.insync.replicas": &strict}, oneLeaderPerRack...))
+
+	bootstrap := strings.Join(c.Addrs(), ",")
+	var stdout, stderr bytes.Buffer
+
+	// min.insync.replicas=3 is read from the topic, so losing any rack
+	// blocks acks=all producers.
+	code := run(context.Background(), []string{"what-if", "-bootstrap", bootstrap, "-fail-rack", "rack-a"}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Failing rack(s) [rack-a] takes down broker(s) [0]")
+	assert.Contains(t, stdout.String(), "below min ISR (2/3)")
+	assert.Contains(t, stdout.String(), "3 partition(s) affected: 1 change leader, 0 go offline, 3 fall below min.insync.replicas")
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run(context.Background(), []string{"what-if", "-bootstrap", bootstrap, "-fail-rack", "rack-a", "-min-insync", "2", "-format", "json"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	var report struct {
+		Survives   bool `json:"survives"`
+		Partitions []struct {
+			Status string `json:"status"`
+		} `json:"partitions"`
+	}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+	assert.True(t, report.Survives)
+	assert.Len(t, report.Partitions, 3)
+
+	stderr.Reset()
+	code = run(context.Background(), []string{"what-if", "-bootstrap", bootstrap, "-fail-rack", "rack-a,rack-b,rack-c"}, &stdout, &stderr)
+	assert.Equal(t, 1, code, stderr.String())
+	assert.Contains(t, stderr.String(), "3 partition(s) offline")
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run(context.Background(), []string{"what-if", "-bootstrap", bootstrap, "-fail-broker", "1", "-min-insync", "2"}, &stdout, &stderr)
+	assert.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "Failing broker(s) [1]")
+}
+
+func TestWhatIfNeedsFailure(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(context.Background(), []string{"what-if"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "-fail-rack or -fail-broker is required")
+}
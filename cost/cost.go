@@ -0,0 +1,353 @@
+// Package cost estimates what a workload's cross-rack traffic costs on a
+// cluster, and what it would cost with follower fetching or pinned leaders.
+//
+// Cloud providers charge for traffic between availability zones, which is
+// what racks usually map to. Given a snapshot, the racks producers and
+// consumers run in and a price per gigabyte, Estimate splits the traffic by
+// rack pair into three kinds:
+//
+//   - produce: from each producer to the leader of the partition it writes;
+//   - replication: from each leader to every other replica;
+//   - consume: to each consumer group from the replica it reads, the leader
+//     unless follower fetching is on and an in-sync replica lives in the
+//     consumer's rack (see docs/APPLICATION_AWARENESS.md).
+//
+// Throughput is spread evenly over a topic's online partitions, as keyless
+// and well-keyed producers do. Protocol overhead and compression are not
+// modelled; give throughput as it is sent on the wire.
+package cost
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/topology"
+)
+
+// HoursPerMonth is the month cloud providers bill by.
+const HoursPerMonth = 730
+
+// bytesPerGB and bytesPerMB are decimal units, as on cloud bills.
+const (
+	bytesPerGB = 1e9
+	bytesPerMB = 1e6
+)
+
+// Kind is the reason traffic flows between two racks.
+type Kind string
+
+const (
+	Produce     Kind = "produce"
+	Replication Kind = "replication"
+	Consume     Kind = "consume"
+)
+
+// Kinds lists every kind in the order traffic flows.
+var Kinds = []Kind{Produce, Replication, Consume}
+
+// config holds the what-if settings of an estimate.
+type config struct {
+	followerFetching bool
+	pinLeaders       bool
+}
+
+// Option configures an estimate.
+type Option func(*config)
+
+// WithFollowerFetching has consumers read from an in-sync replica in their
+// rack when there is one, as brokers with
+// replica.selector.class=org.apache.kafka.common.replica.RackAwareReplicaSelector
+// let them.
+func WithFollowerFetching() Option {
+	return func(c *config) { c.followerFetching = true }
+}
+
+// WithPinnedLeaders moves the leaders of every produced topic into the rack
+// its producers send the most to, as rackctl pin-leaders would. Topics
+// whose producers' rack has no brokers keep their leaders.
+func WithPinnedLeaders() Option {
+	return func(c *config) { c.pinLeaders = true }
+}
+
+// Scenario is a named set of what-if options.
+type Scenario struct {
+	Name    string
+	Options []Option
+}
+
+// Scenarios are the layouts Compare estimates: the cluster as it is, and
+// with follower fetching, pinned leaders or both.
+var Scenarios = []Scenario{
+	{Name: "baseline"},
+	{Name: "follower-fetching", Options: []Option{WithFollowerFetching()}},
+	{Name: "pinned-leaders", Options: []Option{WithPinnedLeaders()}},
+	{Name: "pinned-leaders+follower-fetching", Options: []Option{WithPinnedLeaders(), WithFollowerFetching()}},
+}
+
+// Flow is the traffic of one kind from one rack to another. A broker
+// without a rack counts as rack "".
+type Flow struct {
+	Kind           Kind    `json:"kind"`
+	From           string  `json:"from"`
+	To             string  `json:"to"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	// GBPerMonth is the flow's volume over a billing month.
+	GBPerMonth float64 `json:"gb_per_month"`
+	// PerGB is the price the flow is charged at, and CostPerMonth what it
+	// costs over a billing month.
+	PerGB        float64 `json:"per_gb"`
+	CostPerMonth float64 `json:"cost_per_month"`
+}
+
+// CrossRack reports whether the flow leaves a rack, or cannot be shown to
+// stay in one.
+func (f Flow) CrossRack() bool {
+	return f.From != f.To || f.From == ""
+}
+
+// Totals sums flows.
+type Totals struct {
+	BytesPerSecond          float64 `json:"bytes_per_second"`
+	CrossRackBytesPerSecond float64 `json:"cross_rack_bytes_per_second"`
+	CrossRackGBPerMonth     float64 `json:"cross_rack_gb_per_month"`
+	CostPerMonth            float64 `json:"cost_per_month"`
+}
+
+func (t *Totals) add(f Flow) {
+	t.BytesPerSecond += f.BytesPerSecond
+	if f.CrossRack() {
+		t.CrossRackBytesPerSecond += f.BytesPerSecond
+		t.CrossRackGBPerMonth += f.GBPerMonth
+	}
+	t.CostPerMonth += f.CostPerMonth
+}
+
+// Report is the estimated traffic of a workload on one layout.
+type Report struct {
+	// Flows is sorted by kind, in the order of Kinds, then by racks.
+	Flows []Flow `json:"flows"`
+	// Pinned maps each topic whose leaders were pinned to its rack.
+	Pinned map[string]string `json:"pinned,omitempty"`
+	// FollowerFetching reports whether consumers read from local replicas.
+	FollowerFetching bool `json:"follower_fetching"`
+}
+
+// Total sums every flow.
+func (r *Report) Total() Totals {
+	var t Totals
+	for _, f := range r.Flows {
+		t.add(f)
+	}
+	return t
+}
+
+// ByKind sums the flows of each kind.
+func (r *Report) ByKind() map[Kind]Totals {
+	out := make(map[Kind]Totals, len(Kinds))
+	for _, f := range r.Flows {
+		t := out[f.Kind]
+		t.add(f)
+		out[f.Kind] = t
+	}
+	return out
+}
+
+// flowKey identifies a flow while traffic is summed.
+type flowKey struct {
+	kind     Kind
+	from, to string
+}
+
+// Estimate returns the traffic of w on c. Every topic of the workload must
+// be in the snapshot with at least one online partition, or its traffic
+// would have nowhere to go.
+func Estimate(c *topology.Cluster, w *Workload, opts ...Option) (*Report, error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	for _, topic := range w.Topics() {
+		t, ok := c.Topic(topic)
+		if !ok {
+			return nil, fmt.Errorf("unknown topic %q", topic)
+		}
+		if len(onlinePartitions(t)) == 0 {
+			return nil, fmt.Errorf("topic %q has no online partitions", topic)
+		}
+	}
+
+	report := &Report{FollowerFetching: cfg.followerFetching}
+	if cfg.pinLeaders {
+		var err error
+		c, report.Pinned, err = pin(c, w)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	racks := c.BrokerRacks()
+	sums := make(map[flowKey]float64)
+	for _, p := range w.Producers {
+		t, _ := c.Topic(p.Topic)
+		online := onlinePartitions(t)
+		share := p.MBPerSecond * bytesPerMB / float64(len(online))
+		for _, part := range online {
+			leader := racks[part.Leader]
+			sums[flowKey{Produce, p.Rack, leader}] += share
+			for _, id := range part.Replicas {
+				if id != part.Leader {
+					sums[flowKey{Replication, leader, racks[id]}] += share
+				}
+			}
+		}
+	}
+	for _, cons := range w.Consumers {
+		t, _ := c.Topic(cons.Topic)
+		online := onlinePartitions(t)
+		share := cons.MBPerSecond * bytesPerMB / float64(len(online))
+		for _, part := range online {
+			from := racks[part.Leader]
+			if cfg.followerFetching && from != cons.Rack && inSyncIn(part, racks, cons.Rack) {
+				from = cons.Rack
+			}
+			sums[flowKey{Consume, from, cons.Rack}] += share
+		}
+	}
+
+	for k, bytes := range sums {
+		gb := bytes * HoursPerMonth * 3600 / bytesPerGB
+		perGB := w.Prices.Of(k.from, k.to)
+		report.Flows = append(report.Flows, Flow{
+			Kind:           k.kind,
+			From:           k.from,
+			To:             k.to,
+			BytesPerSecond: bytes,
+			GBPerMonth:     gb,
+			PerGB:          perGB,
+			CostPerMonth:   gb * perGB,
+		})
+	}
+	order := make(map[Kind]int, len(Kinds))
+	for i, k := range Kinds {
+		order[k] = i
+	}
+	sort.Slice(report.Flows, func(i, j int) bool {
+		a, b := report.Flows[i], report.Flows[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+	return report, nil
+}
+
+// Comparison is the estimate of one scenario.
+type Comparison struct {
+	Scenario string  `json:"scenario"`
+	Report   *Report `json:"report"`
+}
+
+// Compare estimates w on c for each of Scenarios.
+func Compare(c *topology.Cluster, w *Workload) ([]Comparison, error) {
+	out := make([]Comparison, 0, len(Scenarios))
+	for _, s := range Scenarios {
+		r, err := Estimate(c, w, s.Options...)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Comparison{Scenario: s.Name, Report: r})
+	}
+	return out, nil
+}
+
+// onlinePartitions returns the partitions of t that have a leader. Clients
+// cannot reach the others, so their share of the traffic goes to these.
+func onlinePartitions(t *topology.Topic) []topology.Partition {
+	var out []topology.Partition
+	for _, p := range t.Partitions {
+		if p.Leader >= 0 {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// inSyncIn reports whether an in-sync replica of part lives in rack.
+func inSyncIn(part topology.Partition, racks map[int32]string, rack string) bool {
+	for _, id := range part.ISR {
+		if racks[id] == rack {
+			return true
+		}
+	}
+	return false
+}
+
+// pin returns a copy of c with the leaders of every produced topic moved
+// into the rack that receives the most of its produce traffic, and the rack
+// chosen for each topic it could pin.
+func pin(c *topology.Cluster, w *Workload) (*topology.Cluster, map[string]string, error) {
+	p, err := planner.New(c.Brokers, planner.WithLoad(planner.LoadOf(c)))
+	if err != nil {
+		return nil, nil, err
+	}
+	out := topology.NewCluster(c.Brokers...)
+	out.ID = c.ID
+	for _, t := range c.Topics {
+		t.Partitions = append([]topology.Partition(nil), t.Partitions...)
+		out.AddTopic(t)
+	}
+
+	racks := c.RackNames()
+	pinned := make(map[string]string)
+	for _, topic := range w.Topics() {
+		rack := producerRack(w, topic)
+		if rack == "" || !slices.Contains(racks, rack) {
+			// Nothing produces, or the producers' rack has no brokers;
+			// there is nowhere to pin to.
+			continue
+		}
+		moves, err := p.PinLeaders(out, topic, rack)
+		if err != nil {
+			return nil, nil, err
+		}
+		t, _ := out.Topic(topic)
+		for _, m := range moves {
+			for i := range t.Partitions {
+				part := &t.Partitions[i]
+				if part.ID != m.Partition || part.Leader < 0 {
+					continue
+				}
+				// Once the reassignment completes, every replica is in
+				// sync and the preferred leader has been elected.
+				part.Replicas = m.To
+				part.ISR = m.To
+				part.Leader = m.To[0]
+			}
+		}
+		pinned[topic] = rack
+	}
+	return out, pinned, nil
+}
+
+// producerRack returns the rack that produces the most to topic, or "" if
+// nothing does. Ties go to the first rack by name.
+func producerRack(w *Workload, topic string) string {
+	perRack := make(map[string]float64)
+	for _, p := range w.Producers {
+		if p.Topic == topic {
+			perRack[p.Rack] += p.MBPerSecond
+		}
+	}
+	best := ""
+	for rack, mbps := range perRack {
+		if best == "" || mbps > perRack[best] || (mbps == perRack[best] && rack < best) {
+			best = rack
+		}
+	}
+	return best
+}
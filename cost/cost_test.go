@@ -0,0 +1,143 @@
+package cost
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ordersCluster has one broker per rack and a topic led from every rack.
+func ordersCluster() *topology.Cluster {
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+	)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}},
+		{ID: 1, Leader: 2, Replicas: []int32{2, 3, 1}, ISR: []int32{2, 3, 1}},
+		{ID: 2, Leader: 3, Replicas: []int32{3, 1, 2}, ISR: []int32{3, 1, 2}},
+	}})
+	return c
+}
+
+func ordersLoad() *Workload {
+	return &Workload{
+		Producers: []Client{{Topic: "orders", Rack: "rack-a", MBPerSecond: 3}},
+		Consumers: []Client{{Topic: "orders", Rack: "rack-b", MBPerSecond: 3}},
+		Prices:    Prices{PerGB: 0.01},
+	}
+}
+
+func flow(kind Kind, from, to string, bytes, perGB float64) Flow {
+	gb := bytes * HoursPerMonth * 3600 / 1e9
+	return Flow{Kind: kind, From: from, To: to, BytesPerSecond: bytes, GBPerMonth: gb, PerGB: perGB, CostPerMonth: gb * perGB}
+}
+
+func TestEstimate(t *testing.T) {
+	r, err := Estimate(ordersCluster(), ordersLoad())
+	require.NoError(t, err)
+	assert.Equal(t, []Flow{
+		flow(Produce, "rack-a", "rack-a", 1e6, 0),
+		flow(Produce, "rack-a", "rack-b", 1e6, 0.01),
+		flow(Produce, "rack-a", "rack-c", 1e6, 0.01),
+		flow(Replication, "rack-a", "rack-b", 1e6, 0.01),
+		flow(Replication, "rack-a", "rack-c", 1e6, 0.01),
+		flow(Replication, "rack-b", "rack-a", 1e6, 0.01),
+		flow(Replication, "rack-b", "rack-c", 1e6, 0.01),
+		flow(Replication, "rack-c", "rack-a", 1e6, 0.01),
+		flow(Replication, "rack-c", "rack-b", 1e6, 0.01),
+		flow(Consume, "rack-a", "rack-b", 1e6, 0.01),
+		flow(Consume, "rack-b", "rack-b", 1e6, 0),
+		flow(Consume, "rack-c", "rack-b", 1e6, 0.01),
+	}, r.Flows)
+	assert.Nil(t, r.Pinned)
+
+	total := r.Total()
+	assert.InDelta(t, 12e6, total.BytesPerSecond, 1e-6)
+	assert.InDelta(t, 10e6, total.CrossRackBytesPerSecond, 1e-6)
+	// 10MB/s for 730 hours is 26280GB.
+	assert.InDelta(t, 26280, total.CrossRackGBPerMonth, 1e-6)
+	assert.InDelta(t, 262.8, total.CostPerMonth, 1e-6)
+	assert.InDelta(t, 6e6, r.ByKind()[Replication].CrossRackBytesPerSecond, 1e-6)
+}
+
+func TestCompare(t *testing.T) {
+	got, err := Compare(ordersCluster(), ordersLoad())
+	require.NoError(t, err)
+	cross := make(map[string]float64)
+	for _, cmp := range got {
+		cross[cmp.Scenario] = cmp.Report.Total().CrossRackBytesPerSecond
+	}
+	assert.InDeltaMapValues(t, map[string]float64{
+		// Consumers in rack-b read partitions 0 and 2 from their rack-b
+		// follower.
+		"baseline":          10e6,
+		"follower-fetching": 8e6,
+		// Leaders in the producers' rack save two produce streams but
+		// every consumed byte now comes from rack-a.
+		"pinned-leaders":                   9e6,
+		"pinned-leaders+follower-fetching": 6e6,
+	}, cross, 1e-6)
+
+	pinned := got[2].Report
+	assert.Equal(t, map[string]string{"orders": "rack-a"}, pinned.Pinned)
+	assert.InDelta(t, 3e6, pinned.ByKind()[Produce].BytesPerSecond, 1e-6)
+	assert.Zero(t, pinned.ByKind()[Produce].CrossRackBytesPerSecond)
+}
+
+func TestEstimateFollowerFetchingNeedsLocalInSyncReplica(t *testing.T) {
+	c := ordersCluster()
+	c.Topics[0].Partitions[0].ISR = []int32{1, 3}
+	r, err := Estimate(c, ordersLoad(), WithFollowerFetching())
+	require.NoError(t, err)
+	consume := r.ByKind()[Consume]
+	assert.InDelta(t, 1e6, consume.CrossRackBytesPerSecond, 1e-6, "partition 0 is read from its leader while broker 2 catches up")
+}
+
+func TestEstimateSkipsOfflinePartitions(t *testing.T) {
+	c := ordersCluster()
+	c.Topics[0].Partitions[2].Leader = -1
+	r, err := Estimate(c, ordersLoad())
+	require.NoError(t, err)
+	total := r.ByKind()[Produce]
+	assert.InDelta(t, 3e6, total.BytesPerSecond, 1e-6)
+	assert.InDelta(t, 1.5e6, total.CrossRackBytesPerSecond, 1e-6, "half goes to the leader in rack-b")
+}
+
+func TestEstimateTopicOffline(t *testing.T) {
+	c := ordersCluster()
+	for i := range c.Topics[0].Partitions {
+		c.Topics[0].Partitions[i].Leader = -1
+	}
+	_, err := Estimate(c, ordersLoad())
+	assert.EqualError(t, err, `topic "orders" has no online partitions`)
+}
+
+func TestEstimatePinningNeedsBrokersInProducerRack(t *testing.T) {
+	w := ordersLoad()
+	w.Producers[0].Rack = "rack-d"
+	baseline, err := Estimate(ordersCluster(), w)
+	require.NoError(t, err)
+	pinned, err := Estimate(ordersCluster(), w, WithPinnedLeaders())
+	require.NoError(t, err)
+	assert.Empty(t, pinned.Pinned)
+	assert.Equal(t, baseline.Flows, pinned.Flows)
+}
+
+func TestEstimateLeavesSnapshotAlone(t *testing.T) {
+	c := ordersCluster()
+	_, err := Estimate(c, ordersLoad(), WithPinnedLeaders())
+	require.NoError(t, err)
+	assert.Equal(t, ordersCluster(), c)
+}
+
+func TestEstimateUnknownTopic(t *testing.T) {
+	w := ordersLoad()
+	w.Consumers[0].Topic = "payments"
+	_, err := Estimate(ordersCluster(), w)
+	assert.EqualError(t, err, `unknown topic "payments"`)
+}
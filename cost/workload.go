@@ -0,0 +1,151 @@
+package cost
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Workload is who produces and consumes what, from which rack, and what
+// moving a gigabyte between racks costs.
+type Workload struct {
+	Producers []Client `yaml:"producers" json:"producers"`
+	Consumers []Client `yaml:"consumers" json:"consumers"`
+	Prices    Prices   `yaml:"prices" json:"prices"`
+}
+
+// Client is a group of producers or consumers of one topic in one rack. A
+// consumer entry is one consumer group: every group reads the whole topic.
+type Client struct {
+	Name  string `yaml:"name,omitempty" json:"name,omitempty"`
+	Topic string `yaml:"topic" json:"topic"`
+	Rack  string `yaml:"rack" json:"rack"`
+	// MBPerSecond is the throughput in megabytes (10^6 bytes) per second,
+	// as the payload is sent on the wire.
+	MBPerSecond float64 `yaml:"mbPerSecond" json:"mb_per_second"`
+}
+
+// Prices is the price of cross-rack traffic.
+type Prices struct {
+	// PerGB is the price of a gigabyte (10^9 bytes) sent from one rack to
+	// another. Traffic within a rack is free.
+	PerGB float64 `yaml:"perGB" json:"per_gb"`
+	// Pairs overrides PerGB between specific racks, such as zones in
+	// different regions. A pair applies in both directions unless the
+	// reverse direction is listed too.
+	Pairs []PairPrice `yaml:"pairs,omitempty" json:"pairs,omitempty"`
+}
+
+// PairPrice is the price of a gigabyte sent from one rack to another.
+type PairPrice struct {
+	From  string  `yaml:"from" json:"from"`
+	To    string  `yaml:"to" json:"to"`
+	PerGB float64 `yaml:"perGB" json:"per_gb"`
+}
+
+// Of returns the price per gigabyte sent from rack from to rack to. Traffic
+// to or from a broker without a rack is priced as cross-rack, since it
+// cannot be shown to stay in one.
+func (p Prices) Of(from, to string) float64 {
+	if from == to && from != "" {
+		return 0
+	}
+	reverse, found := 0.0, false
+	for _, pp := range p.Pairs {
+		switch {
+		case pp.From == from && pp.To == to:
+			return pp.PerGB
+		case pp.From == to && pp.To == from && !found:
+			reverse, found = pp.PerGB, true
+		}
+	}
+	if found {
+		return reverse
+	}
+	return p.PerGB
+}
+
+// Read parses and validates a workload. Unknown fields are rejected.
+func Read(r io.Reader) (*Workload, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var w Workload
+	if err := dec.Decode(&w); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing workload: %w", err)
+	}
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// ReadFile parses and validates a workload file.
+func ReadFile(path string) (*Workload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return w, nil
+}
+
+// Validate checks the workload for mistakes that do not need a cluster to
+// detect.
+func (w *Workload) Validate() error {
+	if len(w.Producers) == 0 && len(w.Consumers) == 0 {
+		return errors.New("workload has no producers or consumers")
+	}
+	check := func(kind string, clients []Client) error {
+		for i, c := range clients {
+			name := fmt.Sprintf("%s %d", kind, i+1)
+			if c.Name != "" {
+				name = fmt.Sprintf("%s %q", kind, c.Name)
+			}
+			switch {
+			case c.Topic == "":
+				return fmt.Errorf("%s has no topic", name)
+			case c.Rack == "":
+				return fmt.Errorf("%s has no rack", name)
+			case c.MBPerSecond <= 0:
+				return fmt.Errorf("%s: mbPerSecond must be positive, got %g", name, c.MBPerSecond)
+			}
+		}
+		return nil
+	}
+	if err := check("producer", w.Producers); err != nil {
+		return err
+	}
+	if err := check("consumer", w.Consumers); err != nil {
+		return err
+	}
+	if w.Prices.PerGB < 0 {
+		return fmt.Errorf("prices: perGB must not be negative, got %g", w.Prices.PerGB)
+	}
+	for _, pp := range w.Prices.Pairs {
+		if pp.From == "" || pp.To == "" || pp.PerGB < 0 {
+			return fmt.Errorf("prices: pair %q -> %q needs both racks and a non-negative perGB", pp.From, pp.To)
+		}
+	}
+	return nil
+}
+
+// Topics returns the topics the workload reads or writes, in first-seen
+// order.
+func (w *Workload) Topics() []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, c := range append(append([]Client(nil), w.Producers...), w.Consumers...) {
+		if !seen[c.Topic] {
+			seen[c.Topic] = true
+			out = append(out, c.Topic)
+		}
+	}
+	return out
+}
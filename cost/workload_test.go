@@ -0,0 +1,72 @@
+package cost
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ordersWorkload = `
+producers:
+  - {name: checkout, topic: orders, rack: rack-a, mbPerSecond: 3}
+consumers:
+  - {name: billing, topic: orders, rack: rack-b, mbPerSecond: 3}
+  - {topic: payments, rack: rack-c, mbPerSecond: 1}
+prices:
+  perGB: 0.01
+  pairs:
+    - {from: rack-a, to: rack-c, perGB: 0.02}
+`
+
+func TestRead(t *testing.T) {
+	w, err := Read(strings.NewReader(ordersWorkload))
+	require.NoError(t, err)
+	assert.Equal(t, []Client{{Name: "checkout", Topic: "orders", Rack: "rack-a", MBPerSecond: 3}}, w.Producers)
+	assert.Len(t, w.Consumers, 2)
+	assert.Equal(t, []string{"orders", "payments"}, w.Topics())
+
+	path := filepath.Join(t.TempDir(), "workload.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(ordersWorkload), 0o644))
+	fromFile, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, w, fromFile)
+}
+
+func TestReadRejects(t *testing.T) {
+	tests := map[string]struct {
+		workload string
+		want     string
+	}{
+		"empty":         {"", "workload has no producers or consumers"},
+		"unknown field": {"producers:\n  - {topic: a, rack: r, mbps: 1}\n", "field mbps not found"},
+		"no topic":      {"producers:\n  - {rack: r, mbPerSecond: 1}\n", "producer 1 has no topic"},
+		"no rack":       {"consumers:\n  - {name: billing, topic: a, mbPerSecond: 1}\n", `consumer "billing" has no rack`},
+		"throughput":    {"consumers:\n  - {topic: a, rack: r}\n", "consumer 1: mbPerSecond must be positive, got 0"},
+		"price":         {"producers:\n  - {topic: a, rack: r, mbPerSecond: 1}\nprices: {perGB: -1}\n", "perGB must not be negative"},
+		"pair":          {"producers:\n  - {topic: a, rack: r, mbPerSecond: 1}\nprices: {pairs: [{from: r, perGB: 1}]}\n", "needs both racks"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.workload))
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestPricesOf(t *testing.T) {
+	p := Prices{PerGB: 0.01, Pairs: []PairPrice{
+		{From: "rack-a", To: "rack-c", PerGB: 0.02},
+		{From: "rack-b", To: "rack-c", PerGB: 0.03},
+		{From: "rack-c", To: "rack-b", PerGB: 0.04},
+	}}
+	assert.Equal(t, 0.0, p.Of("rack-a", "rack-a"))
+	assert.Equal(t, 0.01, p.Of("rack-a", "rack-b"))
+	assert.Equal(t, 0.02, p.Of("rack-a", "rack-c"))
+	assert.Equal(t, 0.02, p.Of("rack-c", "rack-a"), "a pair applies both ways")
+	assert.Equal(t, 0.04, p.Of("rack-c", "rack-b"), "unless the reverse is listed")
+	assert.Equal(t, 0.01, p.Of("", ""), "brokers without a rack are not known to share one")
+}
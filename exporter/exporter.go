@@ -0,0 +1,271 @@
+// Package exporter exposes the rack health of a Kafka cluster as Prometheus
+// metrics.
+//
+// Broker JMX metrics such as UnderReplicatedPartitions say that something is
+// wrong but not where: they know nothing about racks. An Exporter takes a
+// snapshot of the cluster at a fixed interval and reports, per rack and per
+// topic, the numbers the monitoring section of docs/KAFKA_RACK_AWARENESS.md
+// recommends alerting on: partitions spanning too few racks, leaders and
+// replicas per rack, how many racks each ISR covers, brokers without a rack
+// and under-replicated partitions by the rack of the lagging replica.
+//
+// Metrics are computed from the latest successful snapshot when Prometheus
+// scrapes, so a scrape never waits on the cluster. A failed snapshot keeps
+// the previous numbers and sets kafka_rack_up to 0.
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "kafka_rack"
+
+var (
+	upDesc = prometheus.NewDesc(namespace+"_up",
+		"1 if the last cluster snapshot succeeded, 0 otherwise.", nil, nil)
+	snapshotTimeDesc = prometheus.NewDesc(namespace+"_snapshot_timestamp_seconds",
+		"Unix time of the last successful cluster snapshot.", nil, nil)
+	snapshotErrorsDesc = prometheus.NewDesc(namespace+"_snapshot_errors_total",
+		"Cluster snapshots that failed.", nil, nil)
+	brokersDesc = prometheus.NewDesc(namespace+"_brokers",
+		"Brokers per rack.", []string{"rack"}, nil)
+	brokersWithoutRackDesc = prometheus.NewDesc(namespace+"_brokers_without_rack",
+		"Brokers with no broker.rack configured.", nil, nil)
+	leadersDesc = prometheus.NewDesc(namespace+"_leaders",
+		"Partition leaders per rack.", []string{"rack"}, nil)
+	replicasDesc = prometheus.NewDesc(namespace+"_replicas",
+		"Partition replicas per rack.", []string{"rack"}, nil)
+	partitionsDesc = prometheus.NewDesc(namespace+"_partitions",
+		"Partitions per topic.", []string{"topic"}, nil)
+	underSpreadDesc = prometheus.NewDesc(namespace+"_partitions_under_spread",
+		"Partitions whose replicas span fewer racks than required.", []string{"topic"}, nil)
+	offlineDesc = prometheus.NewDesc(namespace+"_offline_partitions",
+		"Partitions without a leader.", []string{"topic"}, nil)
+	underReplicatedDesc = prometheus.NewDesc(namespace+"_under_replicated_partitions",
+		"Partitions with an out-of-sync replica in the rack.", []string{"rack"}, nil)
+	isrRacksDesc = prometheus.NewDesc(namespace+"_isr_racks",
+		"Distinct racks covered by each partition's ISR.", nil, nil)
+)
+
+// config holds the settings of an Exporter.
+type config struct {
+	interval time.Duration
+	timeout  time.Duration
+	minRacks int
+	topics   []string
+	internal bool
+}
+
+// Option configures an Exporter.
+type Option func(*config)
+
+// WithInterval sets how often Run takes a snapshot. The default is 30s.
+func WithInterval(d time.Duration) Option {
+	return func(c *config) { c.interval = d }
+}
+
+// WithTimeout bounds each snapshot. The default is 10s.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) { c.timeout = d }
+}
+
+// WithMinRacks sets how many racks a partition must span to not count as
+// under spread. By default a partition must span min(replication factor,
+// racks in the cluster), the spread a rack-aware assignment achieves.
+func WithMinRacks(n int) Option {
+	return func(c *config) { c.minRacks = n }
+}
+
+// WithTopics limits the topic metrics to the given topics. By default every
+// topic is reported.
+func WithTopics(topics ...string) Option {
+	return func(c *config) { c.topics = append(c.topics, topics...) }
+}
+
+// WithInternalTopics also reports internal topics such as
+// __consumer_offsets, which are skipped by default.
+func WithInternalTopics() Option {
+	return func(c *config) { c.internal = true }
+}
+
+// Exporter is a prometheus.Collector reporting the rack health of the
+// latest snapshot taken from a MetadataSource.
+type Exporter struct {
+	src topology.MetadataSource
+	cfg config
+
+	mu       sync.Mutex
+	cluster  *topology.Cluster
+	taken    time.Time
+	up       bool
+	failures int
+}
+
+// New returns an Exporter that has not taken a snapshot yet; call Refresh
+// or Run before registering it.
+func New(src topology.MetadataSource, opts ...Option) *Exporter {
+	cfg := config{interval: 30 * time.Second, timeout: 10 * time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &Exporter{src: src, cfg: cfg}
+}
+
+// Refresh takes a snapshot. On failure the previous snapshot is kept and
+// the error is returned and counted.
+func (e *Exporter) Refresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.timeout)
+	defer cancel()
+	c, err := e.src.Snapshot(ctx, e.cfg.topics...)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		e.up = false
+		e.failures++
+		return fmt.Errorf("taking snapshot: %w", err)
+	}
+	e.cluster, e.taken, e.up = c, time.Now(), true
+	return nil
+}
+
+// Run refreshes immediately and then at every interval until ctx is done.
+// Failed snapshots are passed to onError, which may be nil; they do not
+// stop Run.
+func (e *Exporter) Run(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(e.cfg.interval)
+	defer ticker.Stop()
+	for {
+		if err := e.Refresh(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		upDesc, snapshotTimeDesc, snapshotErrorsDesc,
+		brokersDesc, brokersWithoutRackDesc, leadersDesc, replicasDesc,
+		partitionsDesc, underSpreadDesc, offlineDesc, underReplicatedDesc, isrRacksDesc,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.Lock()
+	c, taken, up, failures := e.cluster, e.taken, e.up, e.failures
+	e.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, boolValue(up))
+	ch <- prometheus.MustNewConstMetric(snapshotErrorsDesc, prometheus.CounterValue, float64(failures))
+	if c == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(snapshotTimeDesc, prometheus.GaugeValue, float64(taken.UnixNano())/1e9)
+	e.collect(ch, c)
+}
+
+// collect reports the metrics derived from one snapshot. Every rack and
+// topic is reported even when its count is zero, so alerts resolve when a
+// problem goes away.
+func (e *Exporter) collect(ch chan<- prometheus.Metric, c *topology.Cluster) {
+	racks := c.Racks()
+	for _, r := range racks {
+		ch <- prometheus.MustNewConstMetric(brokersDesc, prometheus.GaugeValue, float64(len(r.Brokers)), r.Name)
+	}
+	ch <- prometheus.MustNewConstMetric(brokersWithoutRackDesc, prometheus.GaugeValue, float64(len(c.BrokersWithoutRack())))
+
+	brokerRacks := c.BrokerRacks()
+	var all []topology.Partition
+	underReplicated := make(map[string]int)
+	isrRacks := make(map[float64]uint64)
+	for _, t := range c.Topics {
+		if t.Internal && !e.cfg.internal {
+			continue
+		}
+		var underSpread, offline int
+		for _, p := range t.Partitions {
+			if c.RackSpread(p) < e.required(p, len(racks)) {
+				underSpread++
+			}
+			if p.Leader < 0 {
+				offline++
+			}
+			for _, rack := range topology.RacksForBrokers(brokerRacks, outOfSync(p)) {
+				underReplicated[rack]++
+			}
+			isrRacks[float64(len(c.RacksForISR(p)))]++
+		}
+		all = append(all, t.Partitions...)
+		ch <- prometheus.MustNewConstMetric(partitionsDesc, prometheus.GaugeValue, float64(len(t.Partitions)), t.Name)
+		ch <- prometheus.MustNewConstMetric(underSpreadDesc, prometheus.GaugeValue, float64(underSpread), t.Name)
+		ch <- prometheus.MustNewConstMetric(offlineDesc, prometheus.GaugeValue, float64(offline), t.Name)
+	}
+
+	leaders, replicas := c.LeadersByRack(all), c.ReplicasByRack(all)
+	for _, r := range racks {
+		ch <- prometheus.MustNewConstMetric(leadersDesc, prometheus.GaugeValue, float64(leaders[r.Name]), r.Name)
+		ch <- prometheus.MustNewConstMetric(replicasDesc, prometheus.GaugeValue, float64(replicas[r.Name]), r.Name)
+		ch <- prometheus.MustNewConstMetric(underReplicatedDesc, prometheus.GaugeValue, float64(underReplicated[r.Name]), r.Name)
+	}
+	ch <- isrHistogram(isrRacks, len(all), len(racks))
+}
+
+// required returns how many racks p must span.
+func (e *Exporter) required(p topology.Partition, racks int) int {
+	if e.cfg.minRacks > 0 {
+		return e.cfg.minRacks
+	}
+	return min(len(p.Replicas), racks)
+}
+
+// isrHistogram builds a histogram with one bucket per possible rack count.
+func isrHistogram(counts map[float64]uint64, partitions, racks int) prometheus.Metric {
+	buckets := make(map[float64]uint64, racks+1)
+	var sum float64
+	for n, count := range counts {
+		sum += n * float64(count)
+	}
+	for le := 0; le <= racks; le++ {
+		for n, count := range counts {
+			if n <= float64(le) {
+				buckets[float64(le)] += count
+			}
+		}
+	}
+	return prometheus.MustNewConstHistogram(isrRacksDesc, uint64(partitions), sum, buckets)
+}
+
+// outOfSync returns the replicas of p that are not in its ISR.
+func outOfSync(p topology.Partition) []int32 {
+	var ids []int32
+	for _, id := range p.Replicas {
+		if !slices.Contains(p.ISR, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
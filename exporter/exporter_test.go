@@ -0,0 +1,183 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource returns cluster, or err if it is set.
+type fakeSource struct {
+	cluster *topology.Cluster
+	err     error
+	topics  []string
+}
+
+func (s *fakeSource) Snapshot(_ context.Context, topics ...string) (*topology.Cluster, error) {
+	s.topics = topics
+	return s.cluster, s.err
+}
+
+// degraded has one broker per rack plus a broker without one, and an
+// "orders" topic with a healthy, an under-replicated and an offline
+// partition.
+func degraded() *topology.Cluster {
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+		topology.Broker{ID: 4},
+	)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}},
+		// Followers in rack-c and rack-a have fallen behind.
+		{ID: 1, Leader: 2, Replicas: []int32{2, 3, 1}, ISR: []int32{2}},
+		// One rack plus a rackless broker, and no leader.
+		{ID: 2, Leader: -1, Replicas: []int32{3, 4}, ISR: []int32{}},
+	}})
+	c.AddTopic(topology.Topic{Name: "__consumer_offsets", Internal: true, Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1}, ISR: []int32{1}},
+	}})
+	return c
+}
+
+func refreshed(t *testing.T, src topology.MetadataSource, opts ...Option) *Exporter {
+	t.Helper()
+	e := New(src, opts...)
+	require.NoError(t, e.Refresh(context.Background()))
+	return e
+}
+
+func TestExporterRackMetrics(t *testing.T) {
+	e := refreshed(t, &fakeSource{cluster: degraded()})
+
+	const want = `
+# HELP kafka_rack_brokers Brokers per rack.
+# TYPE kafka_rack_brokers gauge
+kafka_rack_brokers{rack="rack-a"} 1
+kafka_rack_brokers{rack="rack-b"} 1
+kafka_rack_brokers{rack="rack-c"} 1
+# HELP kafka_rack_brokers_without_rack Brokers with no broker.rack configured.
+# TYPE kafka_rack_brokers_without_rack gauge
+kafka_rack_brokers_without_rack 1
+# HELP kafka_rack_leaders Partition leaders per rack.
+# TYPE kafka_rack_leaders gauge
+kafka_rack_leaders{rack="rack-a"} 1
+kafka_rack_leaders{rack="rack-b"} 1
+kafka_rack_leaders{rack="rack-c"} 0
+# HELP kafka_rack_replicas Partition replicas per rack.
+# TYPE kafka_rack_replicas gauge
+kafka_rack_replicas{rack="rack-a"} 2
+kafka_rack_replicas{rack="rack-b"} 2
+kafka_rack_replicas{rack="rack-c"} 3
+# HELP kafka_rack_under_replicated_partitions Partitions with an out-of-sync replica in the rack.
+# TYPE kafka_rack_under_replicated_partitions gauge
+kafka_rack_under_replicated_partitions{rack="rack-a"} 1
+kafka_rack_under_replicated_partitions{rack="rack-b"} 0
+kafka_rack_under_replicated_partitions{rack="rack-c"} 2
+# HELP kafka_rack_isr_racks Distinct racks covered by each partition's ISR.
+# TYPE kafka_rack_isr_racks histogram
+kafka_rack_isr_racks_bucket{le="0"} 1
+kafka_rack_isr_racks_bucket{le="1"} 2
+kafka_rack_isr_racks_bucket{le="2"} 2
+kafka_rack_isr_racks_bucket{le="3"} 3
+kafka_rack_isr_racks_bucket{le="+Inf"} 3
+kafka_rack_isr_racks_sum 4
+kafka_rack_isr_racks_count 3
+`
+	assert.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(want),
+		"kafka_rack_brokers", "kafka_rack_brokers_without_rack", "kafka_rack_leaders",
+		"kafka_rack_replicas", "kafka_rack_under_replicated_partitions", "kafka_rack_isr_racks"))
+}
+
+func TestExporterTopicMetrics(t *testing.T) {
+	e := refreshed(t, &fakeSource{cluster: degraded()})
+
+	const want = `
+# HELP kafka_rack_partitions Partitions per topic.
+# TYPE kafka_rack_partitions gauge
+kafka_rack_partitions{topic="orders"} 3
+# HELP kafka_rack_partitions_under_spread Partitions whose replicas span fewer racks than required.
+# TYPE kafka_rack_partitions_under_spread gauge
+kafka_rack_partitions_under_spread{topic="orders"} 1
+# HELP kafka_rack_offline_partitions Partitions without a leader.
+# TYPE kafka_rack_offline_partitions gauge
+kafka_rack_offline_partitions{topic="orders"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(want),
+		"kafka_rack_partitions", "kafka_rack_partitions_under_spread", "kafka_rack_offline_partitions"))
+}
+
+func TestExporterOptions(t *testing.T) {
+	src := &fakeSource{cluster: degraded()}
+	e := refreshed(t, src, WithInternalTopics(), WithMinRacks(3), WithTopics("orders", "__consumer_offsets"))
+	assert.Equal(t, []string{"orders", "__consumer_offsets"}, src.topics)
+
+	const want = `
+# HELP kafka_rack_partitions_under_spread Partitions whose replicas span fewer racks than required.
+# TYPE kafka_rack_partitions_under_spread gauge
+kafka_rack_partitions_under_spread{topic="__consumer_offsets"} 1
+kafka_rack_partitions_under_spread{topic="orders"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(want), "kafka_rack_partitions_under_spread"))
+}
+
+func TestExporterKeepsLastSnapshot(t *testing.T) {
+	src := &fakeSource{}
+	e := New(src)
+	assert.Equal(t, 2, testutil.CollectAndCount(e), "only up and the error counter before the first snapshot")
+
+	src.cluster = degraded()
+	require.NoError(t, e.Refresh(context.Background()))
+	assert.NoError(t, testutil.CollectAndCompare(e, strings.NewReader("# HELP kafka_rack_up 1 if the last cluster snapshot succeeded, 0 otherwise.\n# TYPE kafka_rack_up gauge\nkafka_rack_up 1\n"), "kafka_rack_up"))
+
+	src.err = errors.New("connection refused")
+	err := e.Refresh(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+
+	const want = `
+# HELP kafka_rack_up 1 if the last cluster snapshot succeeded, 0 otherwise.
+# TYPE kafka_rack_up gauge
+kafka_rack_up 0
+# HELP kafka_rack_snapshot_errors_total Cluster snapshots that failed.
+# TYPE kafka_rack_snapshot_errors_total counter
+kafka_rack_snapshot_errors_total 1
+# HELP kafka_rack_brokers_without_rack Brokers with no broker.rack configured.
+# TYPE kafka_rack_brokers_without_rack gauge
+kafka_rack_brokers_without_rack 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(e, strings.NewReader(want),
+		"kafka_rack_up", "kafka_rack_snapshot_errors_total", "kafka_rack_brokers_without_rack"))
+}
+
+func TestExporterRun(t *testing.T) {
+	src := &fakeSource{err: errors.New("no brokers")}
+	e := New(src, WithInterval(time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 100)
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx, func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		})
+		close(done)
+	}()
+
+	assert.ErrorContains(t, <-errs, "no brokers")
+	assert.ErrorContains(t, <-errs, "no brokers", "Run keeps polling after a failure")
+	cancel()
+	<-done
+}
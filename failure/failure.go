@@ -0,0 +1,255 @@
+// Package failure simulates rack and broker outages against a cluster snapshot.
+//
+// Scenario 2 in docs/KAFKA_RACK_AWARENESS.md describes what happens when a
+// rack goes down during peak traffic. Simulate computes it for a real
+// layout, and Tolerances searches for the smallest outage that breaks each
+// topic. Both follow Kafka's behaviour with unclean leader election disabled:
+//
+//   - every replica on a failed broker drops out of the ISR;
+//   - a partition whose leader failed elects the first replica, in replica
+//     order, that is still in the ISR;
+//   - a partition with no surviving in-sync replica goes offline;
+//   - a partition left with fewer in-sync replicas than its
+//     min.insync.replicas rejects acks=all produce requests.
+package failure
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+
+	"kafka-rack-awareness/topology"
+)
+
+// MinInSyncConfig is the topic config that sets how many in-sync replicas
+// acks=all produce requests need.
+const MinInSyncConfig = "min.insync.replicas"
+
+// config holds what fails and the defaults used for topics without configs.
+type config struct {
+	racks     []string
+	brokers   []int32
+	minInSync int
+}
+
+// Option configures a simulation.
+type Option func(*config)
+
+// FailRacks takes every broker in the given racks down.
+func FailRacks(racks ...string) Option {
+	return func(c *config) { c.racks = append(c.racks, racks...) }
+}
+
+// FailBrokers takes the given brokers down.
+func FailBrokers(ids ...int32) Option {
+	return func(c *config) { c.brokers = append(c.brokers, ids...) }
+}
+
+// WithMinInSync sets min.insync.replicas for topics whose snapshot has no
+// min.insync.replicas config. The default is 1, Kafka's default.
+func WithMinInSync(n int) Option {
+	return func(c *config) { c.minInSync = n }
+}
+
+// Impact is what a failure does to one partition with a replica on a failed
+// broker.
+type Impact struct {
+	Topic     string  `json:"topic"`
+	Partition int32   `json:"partition"`
+	Replicas  []int32 `json:"replicas"`
+	// Leader is the leader before the failure and NewLeader the leader
+	// after it, or -1 if the partition goes offline.
+	Leader    int32 `json:"leader"`
+	NewLeader int32 `json:"new_leader"`
+	// ISR is the set of replicas still in sync after the failure.
+	ISR       []int32 `json:"isr"`
+	MinInSync int     `json:"min_insync_replicas"`
+}
+
+// LostLeader reports whether the partition's leader failed.
+func (i Impact) LostLeader() bool {
+	return i.NewLeader != i.Leader
+}
+
+// Offline reports whether no in-sync replica survived, so the partition
+// can neither be read nor written.
+func (i Impact) Offline() bool {
+	return i.NewLeader < 0
+}
+
+// BelowMinInSync reports whether the partition is online but acks=all
+// producers would fail with NOT_ENOUGH_REPLICAS.
+func (i Impact) BelowMinInSync() bool {
+	return !i.Offline() && len(i.ISR) < i.MinInSync
+}
+
+// Status describes the worst thing that happens to the partition.
+func (i Impact) Status() string {
+	switch {
+	case i.Offline():
+		return "offline"
+	case i.BelowMinInSync():
+		return fmt.Sprintf("below min ISR (%d/%d)", len(i.ISR), i.MinInSync)
+	case i.LostLeader():
+		return "leader moves"
+	default:
+		return "under-replicated"
+	}
+}
+
+// Result is the outcome of a simulated failure.
+type Result struct {
+	FailedRacks   []string `json:"failed_racks"`
+	FailedBrokers []int32  `json:"failed_brokers"`
+	// Partitions lists every partition with a replica on a failed broker,
+	// sorted by topic and partition.
+	Partitions []Impact `json:"partitions"`
+	// After is the snapshot as it would look after the failure: failed
+	// brokers are gone and leaders and ISRs are updated.
+	After *topology.Cluster `json:"-"`
+}
+
+// LeaderMoves returns the partitions whose leadership moves to another
+// broker.
+func (r *Result) LeaderMoves() []Impact {
+	return r.filter(func(i Impact) bool { return i.LostLeader() && !i.Offline() })
+}
+
+// Offline returns the partitions that go offline.
+func (r *Result) Offline() []Impact {
+	return r.filter(Impact.Offline)
+}
+
+// BelowMinInSync returns the partitions that stay online but reject acks=all
+// producers.
+func (r *Result) BelowMinInSync() []Impact {
+	return r.filter(Impact.BelowMinInSync)
+}
+
+// Survives reports whether every partition stays writable with acks=all.
+func (r *Result) Survives() bool {
+	return len(r.Offline()) == 0 && len(r.BelowMinInSync()) == 0
+}
+
+func (r *Result) filter(keep func(Impact) bool) []Impact {
+	var out []Impact
+	for _, i := range r.Partitions {
+		if keep(i) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// Simulate applies a failure to a snapshot. The snapshot is not modified.
+func Simulate(c *topology.Cluster, opts ...Option) (*Result, error) {
+	cfg := config{minInSync: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if len(cfg.racks) == 0 && len(cfg.brokers) == 0 {
+		return nil, fmt.Errorf("no racks or brokers to fail")
+	}
+
+	known := make(map[string]bool)
+	for _, name := range c.RackNames() {
+		known[name] = true
+	}
+	failedRack := make(map[string]bool)
+	for _, rack := range cfg.racks {
+		if !known[rack] {
+			return nil, fmt.Errorf("no brokers in rack %q", rack)
+		}
+		failedRack[rack] = true
+	}
+	failedBroker := make(map[int32]bool)
+	for _, id := range cfg.brokers {
+		if _, ok := c.Broker(id); !ok {
+			return nil, fmt.Errorf("no broker %d", id)
+		}
+		failedBroker[id] = true
+	}
+
+	r := &Result{After: &topology.Cluster{ID: c.ID}, FailedRacks: []string{}}
+	for rack := range failedRack {
+		r.FailedRacks = append(r.FailedRacks, rack)
+	}
+	sort.Strings(r.FailedRacks)
+
+	failed := make(map[int32]bool)
+	for _, b := range c.Brokers {
+		if failedBroker[b.ID] || (b.HasRack() && failedRack[b.Rack]) {
+			failed[b.ID] = true
+			r.FailedBrokers = append(r.FailedBrokers, b.ID)
+		} else {
+			r.After.Brokers = append(r.After.Brokers, b)
+		}
+	}
+
+	for _, t := range c.Topics {
+		after := t
+		after.Partitions = make([]topology.Partition, 0, len(t.Partitions))
+		minInSync := minInSyncOf(t, cfg.minInSync)
+		for _, p := range t.Partitions {
+			np := fail(p, failed)
+			after.Partitions = append(after.Partitions, np)
+			if affected(p, failed) {
+				r.Partitions = append(r.Partitions, Impact{
+					Topic:     t.Name,
+					Partition: p.ID,
+					Replicas:  append([]int32(nil), p.Replicas...),
+					Leader:    p.Leader,
+					NewLeader: np.Leader,
+					ISR:       np.ISR,
+					MinInSync: minInSync,
+				})
+			}
+		}
+		r.After.Topics = append(r.After.Topics, after)
+	}
+	return r, nil
+}
+
+// fail returns p after the failed brokers drop out.
+func fail(p topology.Partition, failed map[int32]bool) topology.Partition {
+	np := p
+	np.Replicas = append([]int32(nil), p.Replicas...)
+	np.ISR = nil
+	for _, id := range p.ISR {
+		if !failed[id] {
+			np.ISR = append(np.ISR, id)
+		}
+	}
+	if p.Leader >= 0 && !failed[p.Leader] {
+		return np
+	}
+	np.Leader = -1
+	for _, id := range p.Replicas {
+		if slices.Contains(np.ISR, id) {
+			np.Leader = id
+			break
+		}
+	}
+	return np
+}
+
+func affected(p topology.Partition, failed map[int32]bool) bool {
+	for _, id := range p.Replicas {
+		if failed[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// minInSyncOf returns the topic's min.insync.replicas, or def if the
+// snapshot does not carry a valid one.
+func minInSyncOf(t topology.Topic, def int) int {
+	if v, ok := t.Configs[MinInSyncConfig]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
@@ -0,0 +1,137 @@
+package failure
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// twoRackCluster has racks a and b with two brokers each and rack c with
+// one, plus an "orders" topic covering the interesting cases.
+func twoRackCluster() *topology.Cluster {
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+		topology.Broker{ID: 4, Rack: "rack-a"},
+		topology.Broker{ID: 5, Rack: "rack-b"},
+	)
+	c.AddTopic(topology.Topic{
+		Name:    "orders",
+		Configs: map[string]string{MinInSyncConfig: "2"},
+		Partitions: []topology.Partition{
+			// Spread over three racks: the leader moves, acks=all survives.
+			{ID: 0, Leader: 2, Replicas: []int32{2, 3, 1}, ISR: []int32{2, 3, 1}},
+			// Leader elsewhere, one follower lost.
+			{ID: 1, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}},
+			// Two replicas in rack-b: online but below min ISR.
+			{ID: 2, Leader: 2, Replicas: []int32{2, 5, 3}, ISR: []int32{2, 5, 3}},
+			// Everything in rack-b: offline.
+			{ID: 3, Leader: 5, Replicas: []int32{5, 2}, ISR: []int32{5, 2}},
+			// Untouched.
+			{ID: 4, Leader: 1, Replicas: []int32{1, 3, 4}, ISR: []int32{1, 3, 4}},
+			// The first surviving replica is out of sync, so the next one
+			// leads alone, below min ISR.
+			{ID: 5, Leader: 2, Replicas: []int32{2, 1, 3}, ISR: []int32{2, 3}},
+		},
+	})
+	c.AddTopic(topology.Topic{Name: "events", Partitions: []topology.Partition{
+		{ID: 0, Leader: 2, Replicas: []int32{2, 1}, ISR: []int32{2, 1}},
+	}})
+	return c
+}
+
+func TestSimulateRackFailure(t *testing.T) {
+	c := twoRackCluster()
+	r, err := Simulate(c, FailRacks("rack-b"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"rack-b"}, r.FailedRacks)
+	assert.Equal(t, []int32{2, 5}, r.FailedBrokers)
+
+	byID := make(map[string]Impact)
+	for _, i := range r.Partitions {
+		byID[fmt.Sprintf("%s-%d", i.Topic, i.Partition)] = i
+	}
+	require.Len(t, r.Partitions, 6)
+	assert.NotContains(t, byID, "orders-4")
+
+	assert.Equal(t, int32(3), byID["orders-0"].NewLeader)
+	assert.Equal(t, []int32{3, 1}, byID["orders-0"].ISR)
+	assert.False(t, byID["orders-1"].LostLeader())
+	assert.True(t, byID["orders-2"].BelowMinInSync())
+	assert.True(t, byID["orders-3"].Offline())
+	assert.Equal(t, int32(3), byID["orders-5"].NewLeader)
+
+	// Without a config the Kafka default of 1 applies.
+	assert.Equal(t, 1, byID["events-0"].MinInSync)
+	assert.True(t, byID["events-0"].LostLeader())
+	assert.False(t, byID["events-0"].BelowMinInSync())
+
+	var moved []string
+	for _, i := range r.LeaderMoves() {
+		moved = append(moved, i.Topic)
+	}
+	sort.Strings(moved)
+	assert.Equal(t, []string{"events", "orders", "orders", "orders"}, moved)
+	assert.Len(t, r.Offline(), 1)
+	assert.Len(t, r.BelowMinInSync(), 2)
+	assert.False(t, r.Survives())
+
+	// The snapshot is untouched and After reflects the failure.
+	orders, _ := c.Topic("orders")
+	assert.Equal(t, int32(2), orders.Partitions[0].Leader)
+	assert.Len(t, r.After.Brokers, 3)
+	after, _ := r.After.Topic("orders")
+	assert.Equal(t, int32(-1), after.Partitions[3].Leader)
+	assert.Empty(t, after.Partitions[3].ISR)
+}
+
+func TestSimulateSurvivableFailure(t *testing.T) {
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+	)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}},
+		{ID: 1, Leader: 2, Replicas: []int32{2, 3, 1}, ISR: []int32{2, 3, 1}},
+	}})
+
+	r, err := Simulate(c, FailRacks("rack-a"), WithMinInSync(2))
+	require.NoError(t, err)
+	assert.True(t, r.Survives())
+	require.Len(t, r.LeaderMoves(), 1)
+	assert.Equal(t, int32(2), r.LeaderMoves()[0].NewLeader)
+
+	r, err = Simulate(c, FailRacks("rack-a", "rack-b"), WithMinInSync(2))
+	require.NoError(t, err)
+	assert.Len(t, r.BelowMinInSync(), 2)
+}
+
+func TestSimulateBrokerFailure(t *testing.T) {
+	r, err := Simulate(twoRackCluster(), FailBrokers(5), FailRacks("rack-c"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rack-c"}, r.FailedRacks)
+	assert.Equal(t, []int32{3, 5}, r.FailedBrokers)
+
+	// orders-2, orders-3 and orders-5 are each left with broker 2 alone.
+	assert.Len(t, r.BelowMinInSync(), 3)
+	assert.Empty(t, r.Offline())
+}
+
+func TestSimulateErrors(t *testing.T) {
+	_, err := Simulate(twoRackCluster())
+	assert.EqualError(t, err, "no racks or brokers to fail")
+
+	_, err = Simulate(twoRackCluster(), FailRacks("rack-z"))
+	assert.EqualError(t, err, `no brokers in rack "rack-z"`)
+
+	_, err = Simulate(twoRackCluster(), FailBrokers(9))
+	assert.EqualError(t, err, "no broker 9")
+}
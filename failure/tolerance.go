@@ -0,0 +1,227 @@
+package failure
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"kafka-rack-awareness/topology"
+)
+
+// Domain is the unit that fails as a whole when Tolerances enumerates
+// failures.
+type Domain string
+
+const (
+	// ByRack fails whole racks. Brokers without a rack each count as a
+	// failure domain of their own.
+	ByRack Domain = "rack"
+	// ByBroker fails individual brokers, whatever their rack.
+	ByBroker Domain = "broker"
+)
+
+// ParseDomain parses "rack" or "broker".
+func ParseDomain(s string) (Domain, error) {
+	switch d := Domain(s); d {
+	case ByRack, ByBroker:
+		return d, nil
+	}
+	return "", fmt.Errorf("unknown failure domain %q (want rack or broker)", s)
+}
+
+// FailureSet is one combination of failed racks or brokers and the
+// partitions of a topic it breaks.
+type FailureSet struct {
+	Racks []string `json:"racks,omitempty"`
+	// Brokers are the failed brokers. When failing by rack they are the
+	// brokers without a rack.
+	Brokers    []int32 `json:"brokers,omitempty"`
+	Partitions []int32 `json:"partitions"`
+}
+
+// Size returns how many failure domains are down.
+func (s *FailureSet) Size() int {
+	return len(s.Racks) + len(s.Brokers)
+}
+
+// String lists the failed domains, such as "rack-a,rack-b" or
+// "broker 1,broker 4".
+func (s *FailureSet) String() string {
+	names := append([]string(nil), s.Racks...)
+	for _, id := range s.Brokers {
+		names = append(names, "broker "+strconv.Itoa(int(id)))
+	}
+	return strings.Join(names, ",")
+}
+
+// Tolerance is how many concurrent failures a topic survives.
+type Tolerance struct {
+	Topic string `json:"topic"`
+	// Unwritable is the smallest failure that leaves a partition offline or
+	// below min.insync.replicas, and Offline the smallest that leaves one
+	// with no in-sync replica at all. Either is nil if no failure of up to
+	// Bound domains does it.
+	Unwritable *FailureSet `json:"unwritable"`
+	Offline    *FailureSet `json:"offline"`
+	Bound      int         `json:"bound"`
+}
+
+// Writes returns how many failure domains the topic can lose while every
+// partition keeps accepting acks=all writes. exact is false if no failure
+// within the bound broke the topic, so it tolerates at least n.
+func (t Tolerance) Writes() (n int, exact bool) {
+	return t.tolerates(t.Unwritable)
+}
+
+// Availability returns how many failure domains the topic can lose while
+// every partition keeps a leader, with exact as for Writes.
+func (t Tolerance) Availability() (n int, exact bool) {
+	return t.tolerates(t.Offline)
+}
+
+func (t Tolerance) tolerates(s *FailureSet) (int, bool) {
+	if s == nil {
+		return t.Bound, false
+	}
+	return s.Size() - 1, true
+}
+
+// domain is one unit of failure: a rack, or a broker.
+type domain struct {
+	rack    string
+	broker  int32
+	brokers []int32
+}
+
+func domains(c *topology.Cluster, by Domain) ([]domain, error) {
+	var out []domain
+	switch by {
+	case ByBroker:
+		for _, b := range c.Brokers {
+			out = append(out, domain{broker: b.ID, brokers: []int32{b.ID}})
+		}
+	case ByRack:
+		for _, r := range c.Racks() {
+			out = append(out, domain{rack: r.Name, brokers: r.Brokers})
+		}
+		for _, id := range c.BrokersWithoutRack() {
+			out = append(out, domain{broker: id, brokers: []int32{id}})
+		}
+	default:
+		return nil, fmt.Errorf("unknown failure domain %q", by)
+	}
+	return out, nil
+}
+
+// Tolerances enumerates every combination of up to maxFailures failed racks
+// or brokers and reports, per topic, the smallest combination that stops a
+// partition from accepting writes and the smallest that takes one offline.
+// Among combinations of the same size the first in rack or broker order
+// wins. Topics are returned in snapshot order.
+//
+// Only WithMinInSync applies; the failures are chosen by Tolerances itself.
+func Tolerances(c *topology.Cluster, by Domain, maxFailures int, opts ...Option) ([]Tolerance, error) {
+	cfg := config{minInSync: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if maxFailures < 1 {
+		return nil, fmt.Errorf("max failures must be at least 1, got %d", maxFailures)
+	}
+	units, err := domains(c, by)
+	if err != nil {
+		return nil, err
+	}
+	if len(units) == 0 {
+		return nil, fmt.Errorf("no brokers to fail")
+	}
+	bound := min(maxFailures, len(units))
+
+	out := make([]Tolerance, len(c.Topics))
+	for i, t := range c.Topics {
+		out[i] = Tolerance{Topic: t.Name, Bound: bound}
+	}
+	open := len(out)
+
+	for k := 1; k <= bound && open > 0; k++ {
+		combinations(len(units), k, func(idx []int) bool {
+			failed := make(map[int32]bool)
+			for _, i := range idx {
+				for _, id := range units[i].brokers {
+					failed[id] = true
+				}
+			}
+			for i, t := range c.Topics {
+				tol := &out[i]
+				if tol.Offline != nil {
+					continue
+				}
+				unwritable, offline := breaks(t, failed, minInSyncOf(t, cfg.minInSync))
+				if tol.Unwritable == nil && len(unwritable) > 0 {
+					tol.Unwritable = failureSet(units, idx, unwritable)
+				}
+				if len(offline) > 0 {
+					tol.Offline = failureSet(units, idx, offline)
+					open--
+				}
+			}
+			return open > 0
+		})
+	}
+	return out, nil
+}
+
+// breaks returns the partitions of t that stop accepting acks=all writes and
+// those that go offline when the failed brokers are down.
+func breaks(t topology.Topic, failed map[int32]bool, minInSync int) (unwritable, offline []int32) {
+	for _, p := range t.Partitions {
+		if !affected(p, failed) {
+			continue
+		}
+		np := fail(p, failed)
+		if np.Leader < 0 {
+			offline = append(offline, p.ID)
+		}
+		if np.Leader < 0 || len(np.ISR) < minInSync {
+			unwritable = append(unwritable, p.ID)
+		}
+	}
+	return unwritable, offline
+}
+
+func failureSet(units []domain, idx []int, partitions []int32) *FailureSet {
+	s := &FailureSet{Partitions: partitions}
+	for _, i := range idx {
+		if units[i].rack != "" {
+			s.Racks = append(s.Racks, units[i].rack)
+		} else {
+			s.Brokers = append(s.Brokers, units[i].broker)
+		}
+	}
+	return s
+}
+
+// combinations calls fn with every k-element subset of 0..n-1 in
+// lexicographic order until fn returns false. fn must not keep idx.
+func combinations(n, k int, fn func(idx []int) bool) {
+	idx := make([]int, k)
+	for i := range idx {
+		idx[i] = i
+	}
+	for {
+		if !fn(idx) {
+			return
+		}
+		i := k - 1
+		for i >= 0 && idx[i] == n-k+i {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+		idx[i]++
+		for j := i + 1; j < k; j++ {
+			idx[j] = idx[j-1] + 1
+		}
+	}
+}
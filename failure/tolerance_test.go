@@ -0,0 +1,98 @@
+package failure
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTolerancesByRack(t *testing.T) {
+	tols, err := Tolerances(twoRackCluster(), ByRack, 3)
+	require.NoError(t, err)
+	require.Len(t, tols, 2)
+
+	// AddTopic sorts topics by name.
+	events, orders := tols[0], tols[1]
+	assert.Equal(t, "events", events.Topic)
+
+	// events-0 lives in racks a and b with min.insync.replicas=1.
+	n, exact := events.Writes()
+	assert.Equal(t, 1, n)
+	assert.True(t, exact)
+	assert.Equal(t, []string{"rack-a", "rack-b"}, events.Unwritable.Racks)
+	assert.Equal(t, events.Unwritable, events.Offline)
+
+	// Losing rack-a already puts orders-4 below min ISR; losing rack-b
+	// takes orders-3 offline.
+	n, exact = orders.Writes()
+	assert.Equal(t, 0, n)
+	assert.True(t, exact)
+	assert.Equal(t, "rack-a", orders.Unwritable.String())
+	assert.Equal(t, []int32{4}, orders.Unwritable.Partitions)
+	assert.Equal(t, "rack-b", orders.Offline.String())
+	assert.Equal(t, []int32{3}, orders.Offline.Partitions)
+}
+
+func TestTolerancesByBroker(t *testing.T) {
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+		topology.Broker{ID: 4},
+	)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2, 4}, ISR: []int32{1, 2, 4}},
+		{ID: 1, Leader: 2, Replicas: []int32{2, 3, 4}, ISR: []int32{2, 3, 4}},
+	}})
+
+	tols, err := Tolerances(c, ByBroker, 2, WithMinInSync(2))
+	require.NoError(t, err)
+	require.Len(t, tols, 1)
+	n, exact := tols[0].Writes()
+	assert.Equal(t, 1, n)
+	assert.True(t, exact)
+	assert.Equal(t, []int32{1, 2}, tols[0].Unwritable.Brokers)
+	assert.Equal(t, "broker 1,broker 2", tols[0].Unwritable.String())
+
+	// No pair of brokers takes a partition offline.
+	assert.Nil(t, tols[0].Offline)
+	n, exact = tols[0].Availability()
+	assert.Equal(t, 2, n)
+	assert.False(t, exact)
+
+	// By rack, broker 4 is a domain of its own.
+	tols, err = Tolerances(c, ByRack, 5)
+	require.NoError(t, err)
+	assert.Equal(t, 4, tols[0].Bound)
+	assert.Equal(t, []string{"rack-a", "rack-b"}, tols[0].Offline.Racks)
+	assert.Equal(t, []int32{4}, tols[0].Offline.Brokers)
+	assert.Equal(t, []int32{0}, tols[0].Offline.Partitions)
+	assert.Equal(t, "rack-a,rack-b,broker 4", tols[0].Offline.String())
+}
+
+func TestTolerancesErrors(t *testing.T) {
+	_, err := Tolerances(twoRackCluster(), ByRack, 0)
+	assert.EqualError(t, err, "max failures must be at least 1, got 0")
+
+	_, err = Tolerances(topology.NewCluster(), ByBroker, 1)
+	assert.EqualError(t, err, "no brokers to fail")
+
+	_, err = ParseDomain("zone")
+	assert.EqualError(t, err, `unknown failure domain "zone" (want rack or broker)`)
+}
+
+func TestCombinations(t *testing.T) {
+	var got [][]int
+	combinations(4, 2, func(idx []int) bool {
+		got = append(got, append([]int(nil), idx...))
+		return true
+	})
+	assert.Equal(t, [][]int{{0, 1}, {0, 2}, {0, 3}, {1, 2}, {1, 3}, {2, 3}}, got)
+
+	calls := 0
+	combinations(4, 2, func([]int) bool { calls++; return false })
+	assert.Equal(t, 1, calls)
+}
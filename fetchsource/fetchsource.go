@@ -0,0 +1,155 @@
+// Package fetchsource records which brokers, and so which racks, served a
+// franz-go consumer's fetches.
+//
+// Setting kgo.Rack only asks brokers for a nearby replica. Whether reads
+// actually come from the local rack depends on replica.selector.class on
+// the brokers and on a replica of each partition living in that rack. A
+// Tracker, installed with kgo.WithHooks, counts the bytes of every fetched
+// batch by the broker that served it, so a test or a health check can prove
+// where the bytes came from (see "Verifying Follower Fetching" in
+// docs/APPLICATION_AWARENESS.md).
+package fetchsource
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Stats is what one broker or rack served.
+type Stats struct {
+	// Bytes is the size of the fetched batches as read from the wire,
+	// before decompression.
+	Bytes   int64 `json:"bytes"`
+	Records int64 `json:"records"`
+	Batches int64 `json:"batches"`
+}
+
+func (s *Stats) add(m kgo.FetchBatchMetrics) {
+	s.Bytes += int64(m.CompressedBytes)
+	s.Records += int64(m.NumRecords)
+	s.Batches++
+}
+
+// Tracker counts fetched batches by the broker that served them. It
+// implements kgo.HookFetchBatchRead and is safe for concurrent use.
+type Tracker struct {
+	mu      sync.Mutex
+	brokers map[int32]*Stats
+	racks   map[int32]string
+}
+
+var _ kgo.HookFetchBatchRead = (*Tracker)(nil)
+
+// New returns an empty tracker. Pass it to kgo.WithHooks.
+func New() *Tracker {
+	return &Tracker{brokers: make(map[int32]*Stats), racks: make(map[int32]string)}
+}
+
+// OnFetchBatchRead implements kgo.HookFetchBatchRead.
+func (t *Tracker) OnFetchBatchRead(meta kgo.BrokerMetadata, _ string, _ int32, m kgo.FetchBatchMetrics) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.brokers[meta.NodeID]
+	if s == nil {
+		s = &Stats{}
+		t.brokers[meta.NodeID] = s
+	}
+	s.add(m)
+	if meta.Rack != nil {
+		t.racks[meta.NodeID] = *meta.Rack
+	}
+}
+
+// Reset forgets everything recorded so far, for example after a warm-up.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.brokers)
+	clear(t.racks)
+}
+
+// Brokers returns what each broker served, keyed by broker ID.
+func (t *Tracker) Brokers() map[int32]Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[int32]Stats, len(t.brokers))
+	for id, s := range t.brokers {
+		out[id] = *s
+	}
+	return out
+}
+
+// Racks returns what each rack served. Brokers without a rack are counted
+// under "".
+func (t *Tracker) Racks() map[string]Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]Stats)
+	for id, s := range t.brokers {
+		r := out[t.racks[id]]
+		r.Bytes += s.Bytes
+		r.Records += s.Records
+		r.Batches += s.Batches
+		out[t.racks[id]] = r
+	}
+	return out
+}
+
+// Total returns what all brokers served.
+func (t *Tracker) Total() Stats {
+	var total Stats
+	for _, s := range t.Racks() {
+		total.Bytes += s.Bytes
+		total.Records += s.Records
+		total.Batches += s.Batches
+	}
+	return total
+}
+
+// Share returns the fraction of fetched bytes served from rack, or 0 if
+// nothing was fetched.
+func (t *Tracker) Share(rack string) float64 {
+	total := t.Total().Bytes
+	if total == 0 {
+		return 0
+	}
+	return float64(t.Racks()[rack].Bytes) / float64(total)
+}
+
+// CheckRack returns an error unless rack served at least the given fraction
+// of the fetched bytes, between 0 and 1. Nothing fetched at all is an error
+// too. The error lists the bytes served per rack.
+func (t *Tracker) CheckRack(rack string, atLeast float64) error {
+	racks := t.Racks()
+	var total int64
+	for _, s := range racks {
+		total += s.Bytes
+	}
+	if total == 0 {
+		return fmt.Errorf("no fetched bytes recorded")
+	}
+	share := float64(racks[rack].Bytes) / float64(total)
+	if share >= atLeast {
+		return nil
+	}
+
+	names := make([]string, 0, len(racks))
+	for name := range racks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		label := name
+		if label == "" {
+			label = "no rack"
+		}
+		parts[i] = fmt.Sprintf("%s %d", label, racks[name].Bytes)
+	}
+	return fmt.Errorf("%.0f%% of fetched bytes came from %s, want at least %.0f%% (bytes by rack: %s)",
+		100*share, rack, 100*atLeast, strings.Join(parts, ", "))
+}
@@ -0,0 +1,102 @@
+package fetchsource
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"kafka-rack-awareness/kafkatest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func broker(id int32, rack string) kgo.BrokerMetadata {
+	m := kgo.BrokerMetadata{NodeID: id}
+	if rack != "" {
+		m.Rack = &rack
+	}
+	return m
+}
+
+func TestTrackerCountsByBrokerAndRack(t *testing.T) {
+	tr := New()
+	tr.OnFetchBatchRead(broker(1, "rack-a"), "orders", 0, kgo.FetchBatchMetrics{NumRecords: 10, CompressedBytes: 600, UncompressedBytes: 1000})
+	tr.OnFetchBatchRead(broker(2, "rack-a"), "orders", 1, kgo.FetchBatchMetrics{NumRecords: 5, CompressedBytes: 200})
+	tr.OnFetchBatchRead(broker(3, "rack-b"), "orders", 2, kgo.FetchBatchMetrics{NumRecords: 2, CompressedBytes: 100})
+	tr.OnFetchBatchRead(broker(4, ""), "orders", 3, kgo.FetchBatchMetrics{NumRecords: 1, CompressedBytes: 100})
+
+	assert.Equal(t, Stats{Bytes: 600, Records: 10, Batches: 1}, tr.Brokers()[1])
+	assert.Equal(t, map[string]Stats{
+		"rack-a": {Bytes: 800, Records: 15, Batches: 2},
+		"rack-b": {Bytes: 100, Records: 2, Batches: 1},
+		"":       {Bytes: 100, Records: 1, Batches: 1},
+	}, tr.Racks())
+	assert.Equal(t, Stats{Bytes: 1000, Records: 18, Batches: 4}, tr.Total())
+	assert.InDelta(t, 0.8, tr.Share("rack-a"), 1e-9)
+
+	require.NoError(t, tr.CheckRack("rack-a", 0.8))
+	err := tr.CheckRack("rack-b", 0.5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "10% of fetched bytes came from rack-b")
+	assert.Contains(t, err.Error(), "no rack 100, rack-a 800, rack-b 100")
+
+	tr.Reset()
+	assert.Empty(t, tr.Brokers())
+	assert.Zero(t, tr.Share("rack-a"))
+	assert.EqualError(t, tr.CheckRack("rack-a", 0.5), "no fetched bytes recorded")
+}
+
+// consume produces records to a topic whose leaders are spread over three
+// racks and reads them back with a rack-a consumer, returning its tracker.
+func consume(t *testing.T, followerFetching bool) *Tracker {
+	t.Helper()
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	if followerFetching {
+		c.EnableFollowerFetching()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	producer, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...), kgo.DefaultProduceTopic("orders"))
+	require.NoError(t, err)
+	defer producer.Close()
+	require.NoError(t, kafkatest.CreateTopic(ctx, producer, "orders", nil,
+		[]int32{0, 1, 2}, []int32{1, 2, 0}, []int32{2, 0, 1}, []int32{0, 1, 2}, []int32{1, 2, 0}, []int32{2, 0, 1}))
+	const records = 300
+	for i := 0; i < records; i++ {
+		producer.Produce(ctx, &kgo.Record{Key: []byte(fmt.Sprintf("key-%d", i)), Value: make([]byte, 100)}, nil)
+	}
+	require.NoError(t, producer.Flush(ctx))
+
+	tr := New()
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(c.Addrs()...),
+		kgo.ConsumeTopics("orders"),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.Rack("rack-a"),
+		kgo.WithHooks(tr),
+	)
+	require.NoError(t, err)
+	defer consumer.Close()
+	for got := 0; got < records; {
+		fs := consumer.PollFetches(ctx)
+		require.NoError(t, ctx.Err())
+		got += fs.NumRecords()
+	}
+	return tr
+}
+
+func TestRackConsumerReadsFromLocalReplicas(t *testing.T) {
+	tr := consume(t, true)
+	require.NoError(t, tr.CheckRack("rack-a", 0.9))
+	assert.Equal(t, int64(300), tr.Total().Records)
+}
+
+func TestRackConsumerReadsFromLeadersWithoutReplicaSelector(t *testing.T) {
+	tr := consume(t, false)
+	assert.Error(t, tr.CheckRack("rack-a", 0.9), "brokers serve every fetch from the leader by default")
+	assert.Len(t, tr.Racks(), 3)
+}
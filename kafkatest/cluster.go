@@ -0,0 +1,278 @@
+// Package kafkatest runs an in-memory Kafka cluster with racks, so the
+// rack-awareness checks can run under plain "go test" without Docker.
+//
+// The cluster is franz-go's kfake. kfake reports every broker in the same
+// rack, so the fixture sits on kfake's listeners and rewrites responses on
+// the way out:
+//
+//   - Metadata and DescribeCluster report the rack configured for each
+//     broker;
+//   - kfake places new partitions itself, on consecutive brokers starting at
+//     a random leader; the fixture pins the replicas kfake first reports, so
+//     a later leader move does not shift them as it would in kfake;
+//   - CreateTopics and CreatePartitions requests with an explicit assignment
+//     keep it, with each partition's leader moved to its first replica;
+//   - AlterPartitionAssignments replaces the replicas the fixture reports;
+//   - the ISR is every replica unless a test shrinks it with SetISR;
+//   - a preferred-replica ElectLeaders moves leadership to the first
+//     replica, where kfake would rotate it to the next broker;
+//   - with EnableFollowerFetching, Fetch sends consumers that report a
+//     rack to a replica in that rack, which kfake does not implement.
+package kafkatest
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+)
+
+// Cluster is an in-memory Kafka cluster whose brokers have racks.
+type Cluster struct {
+	fake    *kfake.Cluster
+	brokers []topology.Broker
+
+	followerFetching atomic.Bool
+
+	mu          sync.Mutex
+	assignments map[string][][]int32
+	isr         map[string]map[int32][]int32 // shrunk ISRs, by topic and partition
+	topics      map[[16]byte]string          // topic names by ID, for Fetch v13+
+}
+
+// NewCluster starts one broker per entry in racks; an empty rack starts a
+// broker without one. Broker IDs are assigned by kfake from 0 in the same
+// order. Extra kfake options, such as kfake.SeedTopics, are passed through.
+func NewCluster(racks []string, opts ...kfake.Opt) (*Cluster, error) {
+	if len(racks) == 0 {
+		return nil, fmt.Errorf("kafkatest: at least one broker is required")
+	}
+	c := &Cluster{
+		assignments: make(map[string][][]int32),
+		isr:         make(map[string]map[int32][]int32),
+		topics:      make(map[[16]byte]string),
+	}
+	for i, rack := range racks {
+		c.brokers = append(c.brokers, topology.Broker{ID: int32(i), Rack: rack})
+	}
+
+	var listeners int
+	opts = append([]kfake.Opt{
+		kfake.NumBrokers(len(racks)),
+		// kfake opens one listener per broker, in broker ID order.
+		kfake.ListenFn(func(network, address string) (net.Listener, error) {
+			ln, err := net.Listen(network, address)
+			if err != nil {
+				return nil, err
+			}
+			node := int32(listeners)
+			listeners++
+			return &listener{Listener: ln, c: c, node: node}, nil
+		}),
+	}, opts...)
+
+	fake, err := kfake.NewCluster(opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.fake = fake
+	return c, nil
+}
+
+// Start starts a cluster for the duration of a test and closes it when the
+// test ends.
+func Start(t testing.TB, racks ...string) *Cluster {
+	t.Helper()
+	c, err := NewCluster(racks)
+	if err != nil {
+		t.Fatalf("starting in-memory cluster: %v", err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// Addrs returns the seed addresses of every broker.
+func (c *Cluster) Addrs() []string {
+	return c.fake.ListenAddrs()
+}
+
+// Brokers returns the brokers and their racks.
+func (c *Cluster) Brokers() []topology.Broker {
+	return append([]topology.Broker(nil), c.brokers...)
+}
+
+// Racks returns the rack of every broker, keyed by broker ID. Brokers
+// without a rack are omitted.
+func (c *Cluster) Racks() map[int32]string {
+	racks := make(map[int32]string, len(c.brokers))
+	for _, b := range c.brokers {
+		if b.HasRack() {
+			racks[b.ID] = b.Rack
+		}
+	}
+	return racks
+}
+
+// Fake returns the underlying kfake cluster, for tests that need to inject
+// failures or control requests.
+func (c *Cluster) Fake() *kfake.Cluster {
+	return c.fake
+}
+
+// EnableFollowerFetching makes brokers send consumers that set a client
+// rack (kgo.Rack) to fetch from a replica in their rack, like brokers with
+// replica.selector.class set to RackAwareReplicaSelector. Brokers serve
+// every fetch from the leader otherwise, as Kafka does by default.
+func (c *Cluster) EnableFollowerFetching() {
+	c.followerFetching.Store(true)
+}
+
+// SetISR reports isr as the in-sync replicas of a partition, as if its
+// other replicas had fallen behind, until the partition is reassigned.
+// Replicas outside the ISR no longer serve follower fetches. kfake itself
+// keeps every replica in sync, so this is the only way to shrink an ISR.
+func (c *Cluster) SetISR(topic string, partition int32, isr ...int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.isr[topic] == nil {
+		c.isr[topic] = make(map[int32][]int32)
+	}
+	c.isr[topic][partition] = append([]int32{}, isr...)
+}
+
+// Close shuts the cluster down.
+func (c *Cluster) Close() {
+	c.fake.Close()
+}
+
+// rack returns the rack reported for a broker.
+func (c *Cluster) rack(id int32) *string {
+	for _, b := range c.brokers {
+		if b.ID == id && b.HasRack() {
+			rack := b.Rack
+			return &rack
+		}
+	}
+	return nil
+}
+
+// replicas returns the fixture's replica list for a partition, if it
+// assigned one.
+func (c *Cluster) replicas(topic string, partition int32) ([]int32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	parts := c.assignments[topic]
+	if partition < 0 || int(partition) >= len(parts) || parts[partition] == nil {
+		return nil, false
+	}
+	return append([]int32(nil), parts[partition]...), true
+}
+
+// partitions returns the fixture's replica lists of a topic.
+func (c *Cluster) partitions(topic string) [][]int32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]int32(nil), c.assignments[topic]...)
+}
+
+// adopt pins the replicas kfake reports for a partition the fixture does
+// not track yet and returns the partition's replicas. kfake derives its
+// replicas from the current leader, so without pinning them a leader move
+// would move the replicas too.
+func (c *Cluster) adopt(topic string, partition int32, reported []int32) []int32 {
+	c.mu.Lock()
+	parts := c.assignments[topic]
+	if int(partition) < len(parts) && parts[partition] != nil {
+		replicas := append([]int32(nil), parts[partition]...)
+		c.mu.Unlock()
+		return replicas
+	}
+	for int(partition) >= len(parts) {
+		parts = append(parts, nil)
+	}
+	parts[partition] = append([]int32(nil), reported...)
+	c.assignments[topic] = parts
+	c.mu.Unlock()
+
+	c.fake.SetFollowers(topic, partition, reported)
+	return append([]int32(nil), reported...)
+}
+
+// inSync returns the ISR reported for a partition with the given replicas.
+func (c *Cluster) inSync(topic string, partition int32, replicas []int32) []int32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	isr, ok := c.isr[topic][partition]
+	if !ok {
+		return append([]int32(nil), replicas...)
+	}
+	return slices.DeleteFunc(append([]int32(nil), replicas...), func(id int32) bool {
+		return !slices.Contains(isr, id)
+	})
+}
+
+// set records explicit replica lists, keyed by partition, with every
+// replica in sync.
+func (c *Cluster) set(topic string, replicas map[int32][]int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ids := make([]int32, 0, len(replicas))
+	for p := range replicas {
+		ids = append(ids, p)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	parts := c.assignments[topic]
+	for _, p := range ids {
+		for int(p) >= len(parts) {
+			parts = append(parts, nil)
+		}
+		parts[p] = append([]int32(nil), replicas[p]...)
+		delete(c.isr[topic], p)
+	}
+	c.assignments[topic] = parts
+}
+
+// drop forgets a deleted topic.
+func (c *Cluster) drop(topic string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.assignments, topic)
+	delete(c.isr, topic)
+}
+
+// lead moves each partition's leadership to its first replica and lets
+// every replica serve fetches.
+func (c *Cluster) lead(topic string, first int32, replicas [][]int32) {
+	for i, r := range replicas {
+		if len(r) > 0 {
+			c.fake.MoveTopicPartition(topic, first+int32(i), r[0])
+			c.fake.SetFollowers(topic, first+int32(i), r)
+		}
+	}
+}
+
+// named records the name of a topic ID from a Metadata response.
+func (c *Cluster) named(id [16]byte, topic string) {
+	if id == ([16]byte{}) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.topics[id] = topic
+}
+
+// name returns the name of a topic ID seen in a Metadata response.
+func (c *Cluster) name(id [16]byte) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.topics[id]
+}
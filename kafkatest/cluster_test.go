@@ -0,0 +1,214 @@
+package kafkatest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kafka-rack-awareness/audit"
+	"kafka-rack-awareness/readiness"
+	"kafka-rack-awareness/source"
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+func connect(t *testing.T, c *Cluster) (*kgo.Client, *kadm.Client) {
+	t.Helper()
+	cl, err := kgo.NewClient(kgo.SeedBrokers(c.Addrs()...))
+	require.NoError(t, err)
+	t.Cleanup(cl.Close)
+	return cl, kadm.NewClient(cl)
+}
+
+func snapshot(t *testing.T, cl *kgo.Client, topics ...string) *topology.Cluster {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	snap, err := source.NewFranz(cl).Snapshot(ctx, topics...)
+	require.NoError(t, err)
+	return snap
+}
+
+func TestClusterReportsRacks(t *testing.T) {
+	c := Start(t, "rack-a", "rack-b", "rack-c", "")
+	cl, _ := connect(t, c)
+
+	snap := snapshot(t, cl)
+	assert.ElementsMatch(t, c.Brokers(), brokersOf(snap))
+	assert.Equal(t, map[int32]string{0: "rack-a", 1: "rack-b", 2: "rack-c"}, c.Racks())
+}
+
+func brokersOf(c *topology.Cluster) []topology.Broker {
+	var bs []topology.Broker
+	for _, b := range c.Brokers {
+		bs = append(bs, topology.Broker{ID: b.ID, Rack: b.Rack})
+	}
+	return bs
+}
+
+func TestCreateTopicSpreadsReplicasAcrossRacks(t *testing.T) {
+	c := Start(t, "rack-a", "rack-b", "rack-c", "rack-a", "rack-b", "rack-c")
+	cl, admin := connect(t, c)
+	ctx := context.Background()
+
+	resp, err := admin.CreateTopic(ctx, 12, 3, nil, "orders")
+	require.NoError(t, err)
+	require.NoError(t, resp.Err)
+
+	snap := snapshot(t, cl, "orders")
+	topic, ok := snap.Topic("orders")
+	require.True(t, ok)
+	require.Len(t, topic.Partitions, 12)
+	// kfake places replicas on consecutive brokers, which alternate racks
+	// here, so every partition spans all three.
+	for _, p := range topic.Partitions {
+		assert.Equal(t, 3, snap.RackSpread(p), "partition %d", p.ID)
+		assert.Equal(t, p.Replicas[0], p.Leader, "partition %d", p.ID)
+		assert.Equal(t, p.Replicas, p.ISR, "partition %d", p.ID)
+	}
+	assert.False(t, audit.New().Audit(snap).HasViolations(audit.SeverityCritical))
+
+	// Leadership really moved: records produced to the reported leaders
+	// are readable.
+	require.NoError(t, cl.ProduceSync(ctx, &kgo.Record{Topic: "orders", Value: []byte("order-1")}).FirstErr())
+}
+
+func TestCreateTopicKeepsKfakePlacement(t *testing.T) {
+	c := Start(t, "rack-a", "rack-a", "rack-b")
+	cl, admin := connect(t, c)
+	ctx := context.Background()
+
+	resp, err := admin.CreateTopic(ctx, 6, 3, nil, "orders")
+	require.NoError(t, err)
+	require.NoError(t, resp.Err)
+
+	// Every replica set holds both rack-a brokers: the fixture does not
+	// improve on kfake's placement.
+	snap := snapshot(t, cl, "orders")
+	topic, _ := snap.Topic("orders")
+	require.Len(t, topic.Partitions, 6)
+	for _, p := range topic.Partitions {
+		assert.ElementsMatch(t, []int32{0, 1, 2}, p.Replicas, "partition %d", p.ID)
+		assert.Equal(t, 2, snap.RackSpread(p), "partition %d", p.ID)
+	}
+
+	// Replicas stay put when leadership moves, unlike in kfake.
+	before := topic.Partitions[0]
+	c.Fake().MoveTopicPartition("orders", 0, before.Replicas[1])
+	topic, _ = snapshot(t, cl, "orders").Topic("orders")
+	assert.Equal(t, before.Replicas, topic.Partitions[0].Replicas)
+	assert.Equal(t, before.Replicas[1], topic.Partitions[0].Leader)
+}
+
+func TestSetISR(t *testing.T) {
+	c := Start(t, "rack-a", "rack-b", "rack-c")
+	cl, admin := connect(t, c)
+	ctx := context.Background()
+
+	resp, err := admin.CreateTopic(ctx, 1, 3, nil, "orders")
+	require.NoError(t, err)
+	require.NoError(t, resp.Err)
+	snap := snapshot(t, cl, "orders")
+	require.NoError(t, readiness.CheckTopic(snap, "orders", 0))
+	topic, _ := snap.Topic("orders")
+	replicas := topic.Partitions[0].Replicas
+
+	c.SetISR("orders", 0, replicas[0])
+	snap = snapshot(t, cl, "orders")
+	topic, _ = snap.Topic("orders")
+	assert.Equal(t, replicas, topic.Partitions[0].Replicas)
+	assert.Equal(t, []int32{replicas[0]}, topic.Partitions[0].ISR)
+	assert.Len(t, snap.RacksForISR(topic.Partitions[0]), 1)
+	assert.ErrorContains(t, readiness.CheckTopic(snap, "orders", 0), "ISR")
+
+	// A reassignment brings every new replica into sync.
+	var req kadm.AlterPartitionAssignmentsReq
+	req.Assign("orders", 0, []int32{replicas[2], replicas[1], replicas[0]})
+	altered, err := admin.AlterPartitionAssignments(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, altered.Error())
+	snap = snapshot(t, cl, "orders")
+	assert.NoError(t, readiness.CheckTopic(snap, "orders", 0))
+}
+
+func TestCreateTopicKeepsExplicitAssignment(t *testing.T) {
+	c := Start(t, "rack-a", "rack-b", "rack-c")
+	cl, admin := connect(t, c)
+	ctx := context.Background()
+
+	require.NoError(t, CreateTopic(ctx, cl, "pinned", nil, []int32{1, 0}, []int32{1, 2}))
+
+	snap := snapshot(t, cl, "pinned")
+	topic, _ := snap.Topic("pinned")
+	require.Len(t, topic.Partitions, 2)
+	assert.Equal(t, []int32{1, 0}, topic.Partitions[0].Replicas)
+	assert.Equal(t, []int32{1, 2}, topic.Partitions[1].Replicas)
+	assert.Equal(t, int32(1), topic.Partitions[1].Leader)
+
+	deleted, err := admin.DeleteTopic(ctx, "pinned")
+	require.NoError(t, err)
+	require.NoError(t, deleted.Err)
+	assert.Empty(t, c.partitions("pinned"))
+}
+
+func TestCreatePartitionsAndReassign(t *testing.T) {
+	c := Start(t, "rack-a", "rack-b", "rack-c")
+	cl, admin := connect(t, c)
+	ctx := context.Background()
+
+	resp, err := admin.CreateTopic(ctx, 2, 2, nil, "payments")
+	require.NoError(t, err)
+	require.NoError(t, resp.Err)
+
+	added, err := admin.CreatePartitions(ctx, 2, "payments")
+	require.NoError(t, err)
+	require.NoError(t, added.Error())
+
+	snap := snapshot(t, cl, "payments")
+	topic, _ := snap.Topic("payments")
+	require.Len(t, topic.Partitions, 4)
+	for _, p := range topic.Partitions {
+		assert.Equal(t, 2, snap.RackSpread(p), "partition %d", p.ID)
+	}
+
+	var req kadm.AlterPartitionAssignmentsReq
+	req.Assign("payments", 0, []int32{2, 1})
+	altered, err := admin.AlterPartitionAssignments(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, altered.Error())
+
+	snap = snapshot(t, cl, "payments")
+	topic, _ = snap.Topic("payments")
+	assert.Equal(t, []int32{2, 1}, topic.Partitions[0].Replicas)
+	assert.Equal(t, int32(2), topic.Partitions[0].Leader)
+}
+
+func TestPreferredElectionMovesLeaderToFirstReplica(t *testing.T) {
+	c := Start(t, "rack-a", "rack-b", "rack-c")
+	cl, admin := connect(t, c)
+	ctx := context.Background()
+
+	resp, err := admin.CreateTopic(ctx, 1, 3, nil, "orders")
+	require.NoError(t, err)
+	require.NoError(t, resp.Err)
+	topic, _ := snapshot(t, cl, "orders").Topic("orders")
+	preferred := topic.Partitions[0].Replicas[0]
+
+	// Simulate a failover to the last replica.
+	c.Fake().MoveTopicPartition("orders", 0, topic.Partitions[0].Replicas[2])
+	topic, _ = snapshot(t, cl, "orders").Topic("orders")
+	require.NotEqual(t, preferred, topic.Partitions[0].Leader)
+
+	var s kadm.TopicsSet
+	s.Add("orders", 0)
+	results, err := admin.ElectLeaders(ctx, kadm.ElectPreferredReplica, s)
+	require.NoError(t, err)
+	require.NoError(t, results["orders"][0].Err)
+
+	topic, _ = snapshot(t, cl, "orders").Topic("orders")
+	assert.Equal(t, preferred, topic.Partitions[0].Leader)
+}
@@ -0,0 +1,155 @@
+package kafkatest
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/twmb/franz-go/pkg/kbin"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// rewritten lists the request keys whose responses the fixture rewrites.
+var rewritten = map[int16]bool{
+	3:  true, // Metadata
+	19: true, // CreateTopics
+	20: true, // DeleteTopics
+	37: true, // CreatePartitions
+	43: true, // ElectLeaders
+	45: true, // AlterPartitionAssignments
+	60: true, // DescribeCluster
+}
+
+// Fetch is only rewritten with follower fetching on; see
+// Cluster.EnableFollowerFetching.
+const fetchKey = 1
+
+// listener hands kfake connections that rewrite responses.
+type listener struct {
+	net.Listener
+	c    *Cluster
+	node int32
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	nc, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: nc, c: l.c, node: l.node, pending: make(map[int32]kmsg.Request)}, nil
+}
+
+// conn sits between a client and kfake. It decodes the requests kfake reads
+// and remembers the ones to rewrite by correlation ID, then splits what kfake
+// writes back into responses by frame length and decodes, rewrites and
+// re-encodes each one.
+type conn struct {
+	net.Conn
+	c    *Cluster
+	node int32 // the broker kfake serves on this connection
+
+	in []byte // request bytes not yet framed
+
+	wmu sync.Mutex
+	out []byte // response bytes not yet framed
+
+	mu      sync.Mutex
+	pending map[int32]kmsg.Request
+}
+
+func (cn *conn) Read(b []byte) (int, error) {
+	n, err := cn.Conn.Read(b)
+	cn.in = append(cn.in, b[:n]...)
+	for len(cn.in) >= 4 {
+		size := int(binary.BigEndian.Uint32(cn.in))
+		if len(cn.in) < 4+size {
+			break
+		}
+		cn.request(cn.in[4 : 4+size])
+		cn.in = cn.in[4+size:]
+	}
+	return n, err
+}
+
+// request records a request whose response will be rewritten.
+func (cn *conn) request(body []byte) {
+	r := kbin.Reader{Src: body}
+	key, version, corr := r.Int16(), r.Int16(), r.Int32()
+	r.NullableString() // client ID
+	if !rewritten[key] && (key != fetchKey || !cn.c.followerFetching.Load()) {
+		return
+	}
+	req := kmsg.RequestForKey(key)
+	req.SetVersion(version)
+	if req.IsFlexible() {
+		kmsg.SkipTags(&r)
+	}
+	if err := req.ReadFrom(r.Src); err != nil {
+		return
+	}
+	cn.mu.Lock()
+	cn.pending[corr] = req
+	cn.mu.Unlock()
+}
+
+func (cn *conn) Write(b []byte) (int, error) {
+	cn.wmu.Lock()
+	defer cn.wmu.Unlock()
+
+	cn.out = append(cn.out, b...)
+	for len(cn.out) >= 4 {
+		size := int(binary.BigEndian.Uint32(cn.out))
+		if len(cn.out) < 4+size {
+			break
+		}
+		if _, err := cn.Conn.Write(cn.response(cn.out[:4+size])); err != nil {
+			return 0, err
+		}
+		cn.out = cn.out[4+size:]
+	}
+	return len(b), nil
+}
+
+// response returns a framed response, rewritten if its request was
+// recorded.
+func (cn *conn) response(frame []byte) []byte {
+	if len(frame) < 8 {
+		return frame
+	}
+	corr := int32(binary.BigEndian.Uint32(frame[4:]))
+	cn.mu.Lock()
+	req, ok := cn.pending[corr]
+	delete(cn.pending, corr)
+	cn.mu.Unlock()
+	if !ok {
+		return frame
+	}
+	if out, ok := cn.c.respond(cn.node, req, frame); ok {
+		return out
+	}
+	return frame
+}
+
+// respond decodes a framed response to req from broker node, rewrites it
+// and re-frames it.
+func (c *Cluster) respond(node int32, req kmsg.Request, frame []byte) ([]byte, bool) {
+	resp := req.ResponseKind()
+	resp.SetVersion(req.GetVersion())
+	header := 8
+	if resp.IsFlexible() && resp.Key() != 18 {
+		header++ // empty tagged fields section
+	}
+	if len(frame) < header {
+		return nil, false
+	}
+	if err := resp.ReadFrom(frame[header:]); err != nil {
+		return nil, false
+	}
+
+	c.rewrite(node, req, resp)
+
+	out := append([]byte(nil), frame[:header]...)
+	out = resp.AppendTo(out)
+	binary.BigEndian.PutUint32(out, uint32(len(out)-4))
+	return out, true
+}
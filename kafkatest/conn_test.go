@@ -0,0 +1,72 @@
+package kafkatest
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// frame encodes a response the way kfake writes it.
+func frame(corr int32, resp kmsg.Response) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b[4:], uint32(corr))
+	if resp.IsFlexible() {
+		b = append(b, 0)
+	}
+	b = resp.AppendTo(b)
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	return b
+}
+
+func TestConnFramesResponsesAcrossWrites(t *testing.T) {
+	c, err := NewCluster([]string{"rack-a"})
+	require.NoError(t, err)
+	defer c.Close()
+
+	client, server := net.Pipe()
+	defer client.Close()
+	cn := &conn{Conn: server, c: c, pending: make(map[int32]kmsg.Request)}
+
+	req := kmsg.NewPtrMetadataRequest()
+	req.SetVersion(12)
+	cn.pending[2] = req
+	meta := kmsg.NewPtrMetadataResponse()
+	meta.SetVersion(12)
+	meta.Brokers = append(meta.Brokers, kmsg.MetadataResponseBroker{NodeID: 0, Host: "localhost", Port: 9092})
+
+	plain := frame(1, kmsg.NewPtrApiVersionsResponse())
+	rewritten := frame(2, meta)
+	stream := append(append(append([]byte(nil), plain...), rewritten...), plain...)
+
+	go func() {
+		defer server.Close()
+		// Two responses in one write, then the third split in two.
+		split := len(plain) + len(rewritten)
+		for _, b := range [][]byte{stream[:split], stream[split : split+3], stream[split+3:]} {
+			n, err := cn.Write(b)
+			if !assert.NoError(t, err) || !assert.Equal(t, len(b), n) {
+				return
+			}
+		}
+	}()
+	got, err := io.ReadAll(client)
+	require.NoError(t, err)
+
+	require.True(t, len(got) > 2*len(plain))
+	assert.Equal(t, plain, got[:len(plain)])
+	assert.Equal(t, plain, got[len(got)-len(plain):])
+
+	body := got[len(plain) : len(got)-len(plain)]
+	require.Equal(t, len(body)-4, int(binary.BigEndian.Uint32(body)))
+	out := kmsg.NewPtrMetadataResponse()
+	out.SetVersion(12)
+	require.NoError(t, out.ReadFrom(body[9:]))
+	require.Len(t, out.Brokers, 1)
+	require.NotNil(t, out.Brokers[0].Rack)
+	assert.Equal(t, "rack-a", *out.Brokers[0].Rack)
+}
@@ -0,0 +1,203 @@
+package kafkatest
+
+import (
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// rewrite replaces kfake's racks, replica layout and ISRs in resp, sent by
+// broker node, with the fixture's. Requests that change the layout update the
+// fixture first.
+func (c *Cluster) rewrite(node int32, req kmsg.Request, resp kmsg.Response) {
+	switch resp := resp.(type) {
+	case *kmsg.MetadataResponse:
+		for i := range resp.Brokers {
+			resp.Brokers[i].Rack = c.rack(resp.Brokers[i].NodeID)
+		}
+		for i := range resp.Topics {
+			t := &resp.Topics[i]
+			if t.Topic == nil {
+				continue
+			}
+			c.named(t.TopicID, *t.Topic)
+			for j := range t.Partitions {
+				p := &t.Partitions[j]
+				if p.ErrorCode != 0 {
+					continue
+				}
+				p.Replicas = c.adopt(*t.Topic, p.Partition, p.Replicas)
+				p.ISR = c.inSync(*t.Topic, p.Partition, p.Replicas)
+			}
+		}
+
+	case *kmsg.DescribeClusterResponse:
+		for i := range resp.Brokers {
+			resp.Brokers[i].Rack = c.rack(resp.Brokers[i].NodeID)
+		}
+
+	case *kmsg.CreateTopicsResponse:
+		c.createdTopics(req.(*kmsg.CreateTopicsRequest), resp)
+
+	case *kmsg.CreatePartitionsResponse:
+		c.createdPartitions(req.(*kmsg.CreatePartitionsRequest), resp)
+
+	case *kmsg.DeleteTopicsResponse:
+		for _, t := range resp.Topics {
+			if t.ErrorCode == 0 && t.Topic != nil {
+				c.drop(*t.Topic)
+			}
+		}
+
+	case *kmsg.AlterPartitionAssignmentsResponse:
+		c.reassigned(req.(*kmsg.AlterPartitionAssignmentsRequest), resp)
+
+	case *kmsg.ElectLeadersResponse:
+		c.elected(req.(*kmsg.ElectLeadersRequest), resp)
+
+	case *kmsg.FetchResponse:
+		c.fetched(node, req.(*kmsg.FetchRequest), resp)
+	}
+}
+
+// createdTopics records the replicas of every topic kfake created with an
+// explicit assignment. kfake honors only its partition and replica counts.
+// Other topics keep the replicas kfake chose.
+func (c *Cluster) createdTopics(req *kmsg.CreateTopicsRequest, resp *kmsg.CreateTopicsResponse) {
+	if req.ValidateOnly {
+		return
+	}
+	requested := make(map[string]kmsg.CreateTopicsRequestTopic, len(req.Topics))
+	for _, t := range req.Topics {
+		requested[t.Topic] = t
+	}
+
+	for _, t := range resp.Topics {
+		rt, ok := requested[t.Topic]
+		if t.ErrorCode != 0 || !ok || len(rt.ReplicaAssignment) == 0 {
+			continue
+		}
+		replicas := make(map[int32][]int32, len(rt.ReplicaAssignment))
+		for _, a := range rt.ReplicaAssignment {
+			replicas[a.Partition] = a.Replicas
+		}
+		c.set(t.Topic, replicas)
+		for p, r := range replicas {
+			c.lead(t.Topic, p, [][]int32{r})
+		}
+	}
+}
+
+// createdPartitions records the replicas of partitions added with an
+// explicit assignment. Other partitions keep the replicas kfake chose.
+func (c *Cluster) createdPartitions(req *kmsg.CreatePartitionsRequest, resp *kmsg.CreatePartitionsResponse) {
+	if req.ValidateOnly {
+		return
+	}
+	requested := make(map[string]kmsg.CreatePartitionsRequestTopic, len(req.Topics))
+	for _, t := range req.Topics {
+		requested[t.Topic] = t
+	}
+
+	for _, t := range resp.Topics {
+		rt, ok := requested[t.Topic]
+		if t.ErrorCode != 0 || !ok || len(rt.Assignment) == 0 {
+			continue
+		}
+		// The assignment lists the added partitions, which come last.
+		first := rt.Count - int32(len(rt.Assignment))
+		replicas := make(map[int32][]int32, len(rt.Assignment))
+		for i, a := range rt.Assignment {
+			replicas[first+int32(i)] = a.Replicas
+		}
+		c.set(t.Topic, replicas)
+		for p, r := range replicas {
+			c.lead(t.Topic, p, [][]int32{r})
+		}
+	}
+}
+
+// reassigned applies a completed reassignment at once and moves leadership
+// to the first new replica.
+func (c *Cluster) reassigned(req *kmsg.AlterPartitionAssignmentsRequest, resp *kmsg.AlterPartitionAssignmentsResponse) {
+	if resp.ErrorCode != 0 {
+		return
+	}
+	ok := make(map[string]map[int32]bool)
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			if p.ErrorCode == 0 {
+				if ok[t.Topic] == nil {
+					ok[t.Topic] = make(map[int32]bool)
+				}
+				ok[t.Topic][p.Partition] = true
+			}
+		}
+	}
+
+	for _, t := range req.Topics {
+		replicas := make(map[int32][]int32)
+		for _, p := range t.Partitions {
+			if p.Replicas != nil && ok[t.Topic][p.Partition] {
+				replicas[p.Partition] = p.Replicas
+			}
+		}
+		c.set(t.Topic, replicas)
+		for p, r := range replicas {
+			c.lead(t.Topic, p, [][]int32{r})
+		}
+	}
+}
+
+// elected moves leadership of every partition a preferred election
+// succeeded for to its first replica. kfake rotates the leader to the next
+// broker instead, which need not even hold a replica.
+func (c *Cluster) elected(req *kmsg.ElectLeadersRequest, resp *kmsg.ElectLeadersResponse) {
+	if req.ElectionType != 0 || resp.ErrorCode != 0 {
+		return
+	}
+	for _, t := range resp.Topics {
+		for _, p := range t.Partitions {
+			if replicas, ok := c.replicas(t.Topic, p.Partition); ok && p.ErrorCode == 0 {
+				c.lead(t.Topic, p.Partition, [][]int32{replicas})
+			}
+		}
+	}
+}
+
+// fetched sends a consumer that reported its rack to a replica in that
+// rack, as a broker with replica.selector.class set to
+// RackAwareReplicaSelector does (KIP-392): the partition comes back without
+// records and names the replica to fetch from instead. Only a replica in the
+// ISR qualifies.
+func (c *Cluster) fetched(node int32, req *kmsg.FetchRequest, resp *kmsg.FetchResponse) {
+	for i := range resp.Brokers {
+		resp.Brokers[i].Rack = c.rack(resp.Brokers[i].NodeID)
+	}
+	if req.Rack == "" || resp.Version < 11 {
+		return
+	}
+	if rack := c.rack(node); rack != nil && *rack == req.Rack {
+		return
+	}
+	for i := range resp.Topics {
+		t := &resp.Topics[i]
+		name := t.Topic
+		if name == "" {
+			name = c.name(t.TopicID)
+		}
+		for j := range t.Partitions {
+			p := &t.Partitions[j]
+			if p.ErrorCode != 0 || p.PreferredReadReplica >= 0 {
+				continue
+			}
+			replicas, _ := c.replicas(name, p.Partition)
+			for _, id := range c.inSync(name, p.Partition, replicas) {
+				if rack := c.rack(id); id != node && rack != nil && *rack == req.Rack {
+					p.PreferredReadReplica = id
+					p.RecordBatches = []byte{}
+					p.AbortedTransactions = nil
+					break
+				}
+			}
+		}
+	}
+}
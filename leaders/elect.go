@@ -0,0 +1,135 @@
+package leaders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+// Admin is the part of *kadm.Client the elector uses.
+type Admin interface {
+	ElectLeaders(ctx context.Context, how kadm.ElectLeadersHow, s kadm.TopicsSet) (kadm.ElectLeadersResults, error)
+}
+
+// Result is the outcome of electing one drifted partition.
+type Result struct {
+	Drift
+	// Elected reports whether an election request was sent and succeeded.
+	// It is false in dry-run mode.
+	Elected bool
+	// Err is the error the controller returned for the partition.
+	Err error
+}
+
+// Elector runs preferred-replica elections in batches. Each election moves
+// a partition's produce and fetch traffic to another broker at once, so
+// batches are kept small and spaced out.
+type Elector struct {
+	admin    Admin
+	batch    int
+	interval time.Duration
+	dryRun   bool
+	progress func([]Result)
+}
+
+// ElectorOption configures an Elector.
+type ElectorOption func(*Elector)
+
+// WithBatchSize sets how many partitions are elected per request. The
+// default is 10.
+func WithBatchSize(n int) ElectorOption {
+	return func(e *Elector) { e.batch = n }
+}
+
+// WithBatchInterval sets the pause between batches. The default is one
+// second.
+func WithBatchInterval(d time.Duration) ElectorOption {
+	return func(e *Elector) { e.interval = d }
+}
+
+// WithDryRun makes Elect report the batches it would send without sending
+// them.
+func WithDryRun() ElectorOption {
+	return func(e *Elector) { e.dryRun = true }
+}
+
+// WithBatchProgress registers a callback invoked with the results of every
+// batch.
+func WithBatchProgress(fn func([]Result)) ElectorOption {
+	return func(e *Elector) { e.progress = fn }
+}
+
+// NewElector returns an elector.
+func NewElector(admin Admin, opts ...ElectorOption) *Elector {
+	e := &Elector{admin: admin, batch: 10, interval: time.Second}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.batch <= 0 {
+		e.batch = 1
+	}
+	return e
+}
+
+// Elect moves the leadership of each drifted partition back to its
+// preferred replica. It stops at the first request that fails or when ctx
+// is done, returning the results so far. Per-partition failures, such as a
+// preferred replica that fell out of sync in the meantime, are recorded in
+// the results and do not stop later batches; a partition whose preferred
+// replica already leads counts as elected.
+func (e *Elector) Elect(ctx context.Context, drifts []Drift) ([]Result, error) {
+	results := make([]Result, 0, len(drifts))
+	for start := 0; start < len(drifts); start += e.batch {
+		if start > 0 && !e.dryRun {
+			select {
+			case <-ctx.Done():
+				return results, ctx.Err()
+			case <-time.After(e.interval):
+			}
+		}
+		batch, err := e.elect(ctx, drifts[start:min(start+e.batch, len(drifts))])
+		results = append(results, batch...)
+		if err != nil {
+			return results, err
+		}
+		if e.progress != nil {
+			e.progress(batch)
+		}
+	}
+	return results, nil
+}
+
+func (e *Elector) elect(ctx context.Context, drifts []Drift) ([]Result, error) {
+	results := make([]Result, len(drifts))
+	var s kadm.TopicsSet
+	for i, d := range drifts {
+		results[i].Drift = d
+		s.Add(d.Topic, d.Partition)
+	}
+	if e.dryRun {
+		return results, nil
+	}
+
+	resp, err := e.admin.ElectLeaders(ctx, kadm.ElectPreferredReplica, s)
+	if err != nil {
+		return nil, fmt.Errorf("electing leaders: %w", err)
+	}
+	for i := range results {
+		r, ok := resp[results[i].Topic][results[i].Partition]
+		switch {
+		case !ok:
+			results[i].Err = fmt.Errorf("no election result for %s", results[i].Drift)
+		case r.Err == nil || errors.Is(r.Err, kerr.ElectionNotNeeded):
+			results[i].Elected = true
+		case r.ErrMessage != "":
+			results[i].Err = fmt.Errorf("%w (%s)", r.Err, r.ErrMessage)
+		default:
+			results[i].Err = r.Err
+		}
+	}
+	return results, nil
+}
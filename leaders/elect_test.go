@@ -0,0 +1,100 @@
+package leaders
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+// fakeAdmin records election requests and answers with errs, keyed by
+// partition.
+type fakeAdmin struct {
+	requests []kadm.TopicsSet
+	errs     map[int32]error
+	err      error
+}
+
+func (a *fakeAdmin) ElectLeaders(_ context.Context, how kadm.ElectLeadersHow, s kadm.TopicsSet) (kadm.ElectLeadersResults, error) {
+	if how != kadm.ElectPreferredReplica {
+		return nil, errors.New("unexpected election type")
+	}
+	a.requests = append(a.requests, s)
+	if a.err != nil {
+		return nil, a.err
+	}
+	results := make(kadm.ElectLeadersResults)
+	for _, tp := range s.IntoList() {
+		results[tp.Topic] = make(map[int32]kadm.ElectLeadersResult)
+		for _, p := range tp.Partitions {
+			results[tp.Topic][p] = kadm.ElectLeadersResult{Topic: tp.Topic, Partition: p, Err: a.errs[p]}
+		}
+	}
+	return results, nil
+}
+
+func drifts(partitions ...int32) []Drift {
+	out := make([]Drift, len(partitions))
+	for i, p := range partitions {
+		out[i] = Drift{Topic: "orders", Partition: p, Electable: true}
+	}
+	return out
+}
+
+func TestElectBatches(t *testing.T) {
+	admin := &fakeAdmin{errs: map[int32]error{
+		1: kerr.ElectionNotNeeded,
+		2: kerr.PreferredLeaderNotAvailable,
+	}}
+	var batches [][]Result
+	e := NewElector(admin, WithBatchSize(2), WithBatchInterval(time.Millisecond),
+		WithBatchProgress(func(rs []Result) { batches = append(batches, rs) }))
+
+	results, err := e.Elect(context.Background(), drifts(0, 1, 2))
+	require.NoError(t, err)
+	require.Len(t, admin.requests, 2)
+	assert.Equal(t, []int32{0, 1}, admin.requests[0].IntoList()[0].Partitions)
+	assert.Len(t, batches, 2)
+
+	require.Len(t, results, 3)
+	assert.True(t, results[0].Elected)
+	assert.True(t, results[1].Elected, "an election that is not needed counts as done")
+	assert.False(t, results[2].Elected)
+	assert.ErrorIs(t, results[2].Err, kerr.PreferredLeaderNotAvailable)
+}
+
+func TestElectDryRun(t *testing.T) {
+	admin := &fakeAdmin{}
+	results, err := NewElector(admin, WithDryRun(), WithBatchSize(1)).Elect(context.Background(), drifts(0, 1))
+	require.NoError(t, err)
+	assert.Empty(t, admin.requests)
+	require.Len(t, results, 2)
+	assert.False(t, results[0].Elected)
+	assert.NoError(t, results[0].Err)
+}
+
+func TestElectStopsOnRequestError(t *testing.T) {
+	admin := &fakeAdmin{err: errors.New("not authorized")}
+	results, err := NewElector(admin, WithBatchSize(1)).Elect(context.Background(), drifts(0, 1))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not authorized")
+	assert.Empty(t, results)
+	assert.Len(t, admin.requests, 1)
+}
+
+func TestElectHonorsContextBetweenBatches(t *testing.T) {
+	admin := &fakeAdmin{}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := NewElector(admin, WithBatchSize(1), WithBatchInterval(time.Hour),
+		WithBatchProgress(func([]Result) { cancel() }))
+
+	results, err := e.Elect(ctx, drifts(0, 1))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Len(t, results, 1)
+	assert.Len(t, admin.requests, 1)
+}
@@ -0,0 +1,195 @@
+// Package leaders measures how partition leadership is spread across racks
+// and brokers, and moves drifted leaders back to their preferred replicas.
+//
+// A rack-aware assignment balances leaders by making a different rack the
+// first, preferred, replica of each partition. Leadership drifts away from
+// it whenever a broker restarts or fails over (Edge Case 5 in
+// docs/KAFKA_RACK_AWARENESS.md), and unless auto.leader.rebalance.enable
+// brings it back, one rack ends up serving most produce and fetch traffic.
+// Analyze reports that drift; an Elector runs preferred-replica elections
+// for the drifted partitions in small, spaced-out batches.
+package leaders
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"kafka-rack-awareness/topology"
+)
+
+// Load is the leadership of one broker or rack.
+type Load struct {
+	// Leaders is how many partitions it currently leads.
+	Leaders int `json:"leaders"`
+	// Preferred is how many partitions have their preferred replica here.
+	Preferred int `json:"preferred"`
+	// Lost is how many of those preferred partitions are led elsewhere.
+	Lost int `json:"lost"`
+}
+
+// Imbalance returns the share of preferred partitions led elsewhere, the
+// ratio Kafka compares against leader.imbalance.per.broker.percentage.
+func (l Load) Imbalance() float64 {
+	if l.Preferred == 0 {
+		return 0
+	}
+	return float64(l.Lost) / float64(l.Preferred)
+}
+
+func (l *Load) add(other Load) {
+	l.Leaders += other.Leaders
+	l.Preferred += other.Preferred
+	l.Lost += other.Lost
+}
+
+// BrokerLoad is the leadership of one broker.
+type BrokerLoad struct {
+	Broker int32  `json:"broker"`
+	Rack   string `json:"rack,omitempty"`
+	Load
+}
+
+// RackLoad is the leadership of one rack. Brokers without a rack are not
+// part of any rack.
+type RackLoad struct {
+	Rack string `json:"rack"`
+	Load
+}
+
+// Drift is a partition whose leader is not its preferred replica.
+type Drift struct {
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Leader        int32  `json:"leader"`
+	Preferred     int32  `json:"preferred"`
+	LeaderRack    string `json:"leader_rack,omitempty"`
+	PreferredRack string `json:"preferred_rack,omitempty"`
+	// Electable reports whether the preferred replica is in sync, which a
+	// preferred-replica election requires.
+	Electable bool `json:"electable"`
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s-%d", d.Topic, d.Partition)
+}
+
+// Report is the leadership of a cluster.
+type Report struct {
+	Brokers []BrokerLoad `json:"brokers"`
+	Racks   []RackLoad   `json:"racks"`
+	// Drifted lists partitions led by a replica other than the preferred
+	// one, ordered by topic and partition. Offline partitions are not
+	// listed.
+	Drifted []Drift `json:"drifted"`
+}
+
+// Analyze measures the leadership of every partition in the snapshot.
+func Analyze(c *topology.Cluster) *Report {
+	brokers := make(map[int32]*Load, len(c.Brokers))
+	for _, b := range c.Brokers {
+		brokers[b.ID] = &Load{}
+	}
+	load := func(id int32) *Load {
+		if _, ok := brokers[id]; !ok {
+			brokers[id] = &Load{}
+		}
+		return brokers[id]
+	}
+
+	r := &Report{}
+	for _, t := range c.Topics {
+		for _, p := range t.Partitions {
+			if p.Leader >= 0 {
+				load(p.Leader).Leaders++
+			}
+			if len(p.Replicas) == 0 {
+				continue
+			}
+			preferred := p.Replicas[0]
+			load(preferred).Preferred++
+			if p.Leader < 0 || p.Leader == preferred {
+				continue
+			}
+			load(preferred).Lost++
+			r.Drifted = append(r.Drifted, Drift{
+				Topic:         t.Name,
+				Partition:     p.ID,
+				Leader:        p.Leader,
+				Preferred:     preferred,
+				LeaderRack:    c.RackOf(p.Leader),
+				PreferredRack: c.RackOf(preferred),
+				Electable:     slices.Contains(p.ISR, preferred),
+			})
+		}
+	}
+
+	racks := make(map[string]*Load)
+	for id, l := range brokers {
+		rack := c.RackOf(id)
+		r.Brokers = append(r.Brokers, BrokerLoad{Broker: id, Rack: rack, Load: *l})
+		if rack == "" {
+			continue
+		}
+		if racks[rack] == nil {
+			racks[rack] = &Load{}
+		}
+		racks[rack].add(*l)
+	}
+	sort.Slice(r.Brokers, func(i, j int) bool { return r.Brokers[i].Broker < r.Brokers[j].Broker })
+	for rack, l := range racks {
+		r.Racks = append(r.Racks, RackLoad{Rack: rack, Load: *l})
+	}
+	sort.Slice(r.Racks, func(i, j int) bool { return r.Racks[i].Rack < r.Racks[j].Rack })
+	return r
+}
+
+// RackSkew returns the most leaders any rack has divided by the mean per
+// rack: 1 when leadership is spread evenly, and the number of racks when
+// one rack leads everything. It returns 0 if there are no racks or leaders.
+func (r *Report) RackSkew() float64 {
+	counts := make([]int, len(r.Racks))
+	for i, l := range r.Racks {
+		counts[i] = l.Leaders
+	}
+	return skew(counts)
+}
+
+// BrokerSkew is RackSkew across brokers.
+func (r *Report) BrokerSkew() float64 {
+	counts := make([]int, len(r.Brokers))
+	for i, l := range r.Brokers {
+		counts[i] = l.Leaders
+	}
+	return skew(counts)
+}
+
+func skew(counts []int) float64 {
+	total, most := 0, 0
+	for _, n := range counts {
+		total += n
+		most = max(most, n)
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(most) * float64(len(counts)) / float64(total)
+}
+
+// Candidates returns the electable drifted partitions whose preferred
+// broker's imbalance is above threshold, like the controller's automatic
+// rebalance with leader.imbalance.per.broker.percentage set to threshold
+// times 100. A threshold of 0 returns every electable drifted partition.
+func (r *Report) Candidates(threshold float64) []Drift {
+	imbalance := make(map[int32]float64, len(r.Brokers))
+	for _, b := range r.Brokers {
+		imbalance[b.Broker] = b.Imbalance()
+	}
+	var out []Drift
+	for _, d := range r.Drifted {
+		if d.Electable && imbalance[d.Preferred] > threshold {
+			out = append(out, d)
+		}
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package leaders
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// drifted has rack-a with two brokers and racks b and c with one, and an
+// "orders" topic where failovers have moved some leaders.
+func drifted() *topology.Cluster {
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+		topology.Broker{ID: 4, Rack: "rack-a"},
+	)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}},
+		// Failed over from rack-b to rack-c.
+		{ID: 1, Leader: 3, Replicas: []int32{2, 3, 1}, ISR: []int32{2, 3, 1}},
+		// The preferred replica is still catching up.
+		{ID: 2, Leader: 1, Replicas: []int32{3, 1, 2}, ISR: []int32{1, 2}},
+		// Offline partitions have no leader to move.
+		{ID: 3, Leader: -1, Replicas: []int32{4, 2, 3}, ISR: []int32{}},
+		{ID: 4, Leader: 1, Replicas: []int32{1, 3, 2}, ISR: []int32{1, 3, 2}},
+	}})
+	return c
+}
+
+func TestAnalyze(t *testing.T) {
+	r := Analyze(drifted())
+
+	assert.Equal(t, []BrokerLoad{
+		{Broker: 1, Rack: "rack-a", Load: Load{Leaders: 3, Preferred: 2}},
+		{Broker: 2, Rack: "rack-b", Load: Load{Leaders: 0, Preferred: 1, Lost: 1}},
+		{Broker: 3, Rack: "rack-c", Load: Load{Leaders: 1, Preferred: 1, Lost: 1}},
+		{Broker: 4, Rack: "rack-a", Load: Load{Leaders: 0, Preferred: 1}},
+	}, r.Brokers)
+	assert.Equal(t, []RackLoad{
+		{Rack: "rack-a", Load: Load{Leaders: 3, Preferred: 3}},
+		{Rack: "rack-b", Load: Load{Leaders: 0, Preferred: 1, Lost: 1}},
+		{Rack: "rack-c", Load: Load{Leaders: 1, Preferred: 1, Lost: 1}},
+	}, r.Racks)
+
+	require.Len(t, r.Drifted, 2)
+	assert.Equal(t, Drift{Topic: "orders", Partition: 1, Leader: 3, Preferred: 2,
+		LeaderRack: "rack-c", PreferredRack: "rack-b", Electable: true}, r.Drifted[0])
+	assert.Equal(t, "orders-2", r.Drifted[1].String())
+	assert.False(t, r.Drifted[1].Electable)
+
+	assert.InDelta(t, 2.25, r.RackSkew(), 1e-9)
+	assert.InDelta(t, 3.0, r.BrokerSkew(), 1e-9)
+}
+
+func TestAnalyzeBalanced(t *testing.T) {
+	c := drifted()
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1, 2}},
+		{ID: 1, Leader: 2, Replicas: []int32{2, 3}, ISR: []int32{2, 3}},
+		{ID: 2, Leader: 3, Replicas: []int32{3, 4}, ISR: []int32{3, 4}},
+	}})
+	r := Analyze(c)
+	assert.Empty(t, r.Drifted)
+	assert.InDelta(t, 1.0, r.RackSkew(), 1e-9)
+	assert.Zero(t, Analyze(topology.NewCluster()).RackSkew())
+}
+
+func TestLoadImbalance(t *testing.T) {
+	assert.Zero(t, Load{}.Imbalance())
+	assert.InDelta(t, 0.25, Load{Preferred: 4, Lost: 1}.Imbalance(), 1e-9)
+}
+
+func TestCandidates(t *testing.T) {
+	r := Analyze(drifted())
+
+	candidates := r.Candidates(0)
+	require.Len(t, candidates, 1, "the partition whose preferred replica is out of sync is skipped")
+	assert.Equal(t, int32(1), candidates[0].Partition)
+
+	assert.Len(t, r.Candidates(0.5), 1)
+	assert.Empty(t, r.Candidates(1), "broker 2 has lost all of its partitions, which is not above 100%")
+}
@@ -0,0 +1,229 @@
+// Package partitioner routes records from franz-go producers to partitions
+// led from the producer's own rack.
+//
+// kgo.Rack only makes consumers fetch from a nearby replica. Produce
+// requests always go to the partition leader, so a producer in rack-a
+// writing to a topic whose leaders are spread over three racks sends two
+// thirds of its bytes across racks. A Partitioner keeps unkeyed records on
+// partitions led from its rack, learning the leaders from topology
+// snapshots (see "Producer Locality" in docs/APPLICATION_AWARENESS.md).
+package partitioner
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+
+	"kafka-rack-awareness/topology"
+)
+
+// Partitioner is a kgo.Partitioner that prefers partitions whose leader is
+// in the producer's rack.
+//
+// Unkeyed records stick to one partition until its batch fills, like
+// kgo.StickyPartitioner, but the next partition is drawn from those led
+// from the local rack. A configurable share of batches still goes to
+// partitions led elsewhere so that they keep receiving data. Records with a
+// key are hashed over every partition exactly as kgo's default partitioner
+// does, so keys keep their partition wherever the producer runs.
+//
+// A Partitioner knows nothing until Update or Run gives it a snapshot;
+// until then, and for topics missing from the snapshot, it behaves like
+// the default partitioner.
+type Partitioner struct {
+	rack   string
+	remote float64
+
+	mu     sync.RWMutex
+	topics map[string]topicLeaders
+}
+
+// topicLeaders sorts a topic's partitions by where they are led from.
+type topicLeaders struct {
+	local, remote []int
+	// known is the partition count of the snapshot. Partitions at or above
+	// it were added later and count as remote until the next snapshot.
+	known int
+}
+
+// Option configures a Partitioner.
+type Option func(*Partitioner)
+
+// WithRemoteShare sets the fraction of unkeyed batches, between 0 and 1,
+// sent to partitions led from other racks even when the local rack leads
+// some. The default is 0: remote partitions only receive unkeyed records
+// when no partition of the topic is led from the local rack.
+func WithRemoteShare(share float64) Option {
+	return func(p *Partitioner) { p.remote = min(max(share, 0), 1) }
+}
+
+// New returns a partitioner for producers in rack. Pass the same rack as
+// kgo.Rack. With an empty rack every partition counts as remote.
+func New(rack string, opts ...Option) *Partitioner {
+	p := &Partitioner{rack: rack, topics: make(map[string]topicLeaders)}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Update replaces the partition leaders with those in c. Offline partitions
+// are skipped for unkeyed records until a later snapshot shows them led.
+func (p *Partitioner) Update(c *topology.Cluster) {
+	topics := make(map[string]topicLeaders, len(c.Topics))
+	for _, t := range c.Topics {
+		var l topicLeaders
+		for _, part := range t.Partitions {
+			l.known = max(l.known, int(part.ID)+1)
+			switch {
+			case part.Leader < 0:
+			case p.rack != "" && c.RackOf(part.Leader) == p.rack:
+				l.local = append(l.local, int(part.ID))
+			default:
+				l.remote = append(l.remote, int(part.ID))
+			}
+		}
+		topics[t.Name] = l
+	}
+	p.mu.Lock()
+	p.topics = topics
+	p.mu.Unlock()
+}
+
+// Refresh takes a snapshot of topics (all topics if none are given) and
+// passes it to Update.
+func (p *Partitioner) Refresh(ctx context.Context, src topology.MetadataSource, topics ...string) error {
+	c, err := src.Snapshot(ctx, topics...)
+	if err != nil {
+		return err
+	}
+	p.Update(c)
+	return nil
+}
+
+// Run refreshes immediately and then at every interval until ctx is done,
+// so the partitioner follows leader elections and failovers. Failed
+// snapshots are passed to onError, if not nil, and keep the previous
+// leaders.
+func (p *Partitioner) Run(ctx context.Context, src topology.MetadataSource, interval time.Duration, onError func(error), topics ...string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Refresh(ctx, src, topics...); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Partitioner) lookup(topic string) (topicLeaders, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	l, ok := p.topics[topic]
+	return l, ok
+}
+
+// ForTopic implements kgo.Partitioner.
+func (p *Partitioner) ForTopic(topic string) kgo.TopicPartitioner {
+	return &topicPartitioner{
+		p:     p,
+		topic: topic,
+		keyed: kgo.StickyKeyPartitioner(nil).ForTopic(topic),
+		on:    -1,
+		last:  -1,
+		rng:   rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())),
+	}
+}
+
+type topicPartitioner struct {
+	p     *Partitioner
+	topic string
+	keyed kgo.TopicPartitioner
+	// on is the partition of the current unkeyed batch, or -1 after
+	// OnNewBatch; last is the one before.
+	on, last int
+	rng      *rand.Rand
+}
+
+var _ kgo.TopicPartitionerOnNewBatch = (*topicPartitioner)(nil)
+
+// RequiresConsistency always returns true so that kgo passes every
+// partition, in partition order, and the index Partition returns is the
+// partition ID. kgo would otherwise pass only the partitions it can write
+// to, without saying which they are. The partitioner skips offline
+// partitions itself, using the last snapshot.
+func (*topicPartitioner) RequiresConsistency(*kgo.Record) bool { return true }
+
+// OnNewBatch moves unkeyed records to another partition once the current
+// batch is full (KIP-480).
+func (t *topicPartitioner) OnNewBatch() { t.on, t.last = -1, t.on }
+
+func (t *topicPartitioner) Partition(r *kgo.Record, n int) int {
+	if r.Key != nil {
+		return t.keyed.Partition(r, n)
+	}
+	if t.on >= 0 && t.on < n {
+		return t.on
+	}
+	t.on = t.pick(n, t.last)
+	return t.on
+}
+
+// pick returns a partition below n for the next unkeyed batch, avoiding
+// last when there is a choice.
+func (t *topicPartitioner) pick(n, last int) int {
+	l, ok := t.p.lookup(t.topic)
+	if !ok {
+		return t.draw(nil, n, last)
+	}
+	local := below(l.local, n)
+	remote := below(l.remote, n)
+	for id := l.known; id < n; id++ {
+		remote = append(remote, id)
+	}
+	switch {
+	case len(local) == 0 && len(remote) == 0:
+		return t.draw(nil, n, last)
+	case len(local) == 0, len(remote) > 0 && t.rng.Float64() < t.p.remote:
+		return t.draw(remote, n, last)
+	default:
+		return t.draw(local, n, last)
+	}
+}
+
+// draw returns a random partition from ids, or from 0..n-1 if ids is nil.
+func (t *topicPartitioner) draw(ids []int, n, last int) int {
+	size := n
+	if ids != nil {
+		size = len(ids)
+	}
+	at := func(i int) int {
+		if ids == nil {
+			return i
+		}
+		return ids[i]
+	}
+	i := t.rng.IntN(size)
+	if at(i) == last && size > 1 {
+		i = (i + 1 + t.rng.IntN(size-1)) % size
+	}
+	return at(i)
+}
+
+// below returns the IDs in ids that are less than n.
+func below(ids []int, n int) []int {
+	var out []int
+	for _, id := range ids {
+		if id < n {
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package partitioner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"kafka-rack-awareness/kafkatest"
+	"kafka-rack-awareness/source"
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// orders has six partitions led round-robin from three racks, with
+// partition 4, led from rack-b, offline.
+func orders() *topology.Cluster {
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+	)
+	var parts []topology.Partition
+	for i := int32(0); i < 6; i++ {
+		leader := i%3 + 1
+		if i == 4 {
+			leader = -1
+		}
+		parts = append(parts, topology.Partition{ID: i, Leader: leader, Replicas: []int32{i%3 + 1}})
+	}
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: parts})
+	return c
+}
+
+// batches partitions one unkeyed record per batch and counts the
+// partitions chosen.
+func batches(tp kgo.TopicPartitioner, n, count int) map[int]int {
+	seen := make(map[int]int)
+	for i := 0; i < count; i++ {
+		seen[tp.Partition(&kgo.Record{Value: []byte("v")}, n)]++
+		tp.(kgo.TopicPartitionerOnNewBatch).OnNewBatch()
+	}
+	return seen
+}
+
+func TestUnkeyedRecordsStayInRack(t *testing.T) {
+	p := New("rack-a")
+	p.Update(orders())
+
+	seen := batches(p.ForTopic("orders"), 6, 200)
+	assert.Equal(t, 200, seen[0]+seen[3])
+	assert.Positive(t, seen[0])
+	assert.Positive(t, seen[3])
+}
+
+func TestUnkeyedRecordsStickUntilNewBatch(t *testing.T) {
+	p := New("rack-a")
+	p.Update(orders())
+	tp := p.ForTopic("orders")
+
+	first := tp.Partition(&kgo.Record{}, 6)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, first, tp.Partition(&kgo.Record{}, 6))
+	}
+	tp.(kgo.TopicPartitionerOnNewBatch).OnNewBatch()
+	assert.NotEqual(t, first, tp.Partition(&kgo.Record{}, 6), "a new batch moves to the other local partition")
+}
+
+func TestRemoteShare(t *testing.T) {
+	p := New("rack-a", WithRemoteShare(0.5))
+	p.Update(orders())
+
+	seen := batches(p.ForTopic("orders"), 6, 400)
+	assert.Zero(t, seen[4], "offline partitions are skipped")
+	local := seen[0] + seen[3]
+	assert.InDelta(t, 200, local, 60)
+	for _, id := range []int{1, 2, 5} {
+		assert.Positive(t, seen[id], "partition %d", id)
+	}
+}
+
+func TestNoLocalLeaders(t *testing.T) {
+	p := New("rack-z")
+	p.Update(orders())
+
+	seen := batches(p.ForTopic("orders"), 6, 200)
+	assert.Zero(t, seen[4])
+	assert.Len(t, seen, 5)
+}
+
+func TestUnknownTopicUsesEveryPartition(t *testing.T) {
+	p := New("rack-a")
+	p.Update(orders())
+
+	seen := batches(p.ForTopic("payments"), 4, 200)
+	assert.Len(t, seen, 4)
+}
+
+func TestPartitionsAddedSinceSnapshotCountAsRemote(t *testing.T) {
+	p := New("rack-a", WithRemoteShare(1))
+	p.Update(orders())
+
+	seen := batches(p.ForTopic("orders"), 8, 400)
+	assert.Zero(t, seen[0]+seen[3])
+	assert.Positive(t, seen[6])
+	assert.Positive(t, seen[7])
+}
+
+func TestKeyedRecordsHashLikeDefault(t *testing.T) {
+	p := New("rack-a")
+	p.Update(orders())
+	tp := p.ForTopic("orders")
+	def := kgo.StickyKeyPartitioner(nil).ForTopic("orders")
+
+	for i := 0; i < 50; i++ {
+		r := &kgo.Record{Key: []byte(fmt.Sprintf("key-%d", i))}
+		assert.Equal(t, def.Partition(r, 6), tp.Partition(r, 6))
+	}
+	assert.True(t, tp.RequiresConsistency(&kgo.Record{}))
+}
+
+type fakeSource struct {
+	cluster *topology.Cluster
+	err     error
+}
+
+func (s fakeSource) Snapshot(context.Context, ...string) (*topology.Cluster, error) {
+	return s.cluster, s.err
+}
+
+func TestRefresh(t *testing.T) {
+	p := New("rack-a")
+	require.NoError(t, p.Refresh(context.Background(), fakeSource{cluster: orders()}))
+	_, ok := p.lookup("orders")
+	assert.True(t, ok)
+
+	boom := errors.New("boom")
+	assert.ErrorIs(t, p.Refresh(context.Background(), fakeSource{err: boom}), boom)
+	_, ok = p.lookup("orders")
+	assert.True(t, ok, "a failed refresh keeps the previous leaders")
+}
+
+func TestProduceToLocalLeaders(t *testing.T) {
+	c := kafkatest.Start(t, "rack-a", "rack-b", "rack-c")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	p := New("rack-a")
+	cl, err := kgo.NewClient(
+		kgo.SeedBrokers(c.Addrs()...),
+		kgo.Rack("rack-a"),
+		kgo.RecordPartitioner(p),
+		kgo.ProducerLinger(0),
+	)
+	require.NoError(t, err)
+	t.Cleanup(cl.Close)
+
+	require.NoError(t, kafkatest.CreateTopic(ctx, cl, "orders", nil,
+		[]int32{0, 1, 2}, []int32{1, 2, 0}, []int32{2, 0, 1}, []int32{0, 1, 2}, []int32{1, 2, 0}, []int32{2, 0, 1}))
+	require.NoError(t, p.Refresh(ctx, source.NewFranz(cl), "orders"))
+
+	snap, err := source.NewFranz(cl).Snapshot(ctx, "orders")
+	require.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		r := cl.ProduceSync(ctx, &kgo.Record{Topic: "orders", Value: []byte(fmt.Sprintf("value-%d", i))})
+		require.NoError(t, r.FirstErr())
+		topic, _ := snap.Topic("orders")
+		leader := topic.Partitions[r[0].Record.Partition].Leader
+		assert.Equal(t, "rack-a", snap.RackOf(leader), "partition %d", r[0].Record.Partition)
+	}
+}
@@ -0,0 +1,101 @@
+package planner
+
+import (
+	"fmt"
+
+	"kafka-rack-awareness/topology"
+)
+
+// Move is a new replica list for an existing partition.
+type Move struct {
+	Topic     string
+	Partition int32
+	From      []int32
+	To        []int32
+}
+
+// Drain moves every replica off the given brokers, which must not be among
+// the planner's brokers. Each replica on a drained broker is replaced in
+// place, so a drained preferred leader is replaced by a new preferred leader,
+// and replacements are chosen by the same ranking Assign uses: a rack the
+// partition does not use yet wins, then the least loaded broker. Every topic
+// is drained, internal ones included, because a broker cannot be shut down
+// while it still holds __consumer_offsets replicas.
+func (p *Planner) Drain(c *topology.Cluster, drained ...int32) ([]Move, error) {
+	drop := make(map[int32]bool, len(drained))
+	for _, id := range drained {
+		drop[id] = true
+	}
+	for _, b := range p.brokers {
+		if drop[b.ID] {
+			return nil, fmt.Errorf("broker %d is both drained and a placement target", b.ID)
+		}
+	}
+
+	var moves []Move
+	for _, t := range c.Topics {
+		for _, part := range t.Partitions {
+			to, err := p.replace(c, part, drop)
+			if err != nil {
+				return nil, fmt.Errorf("%s-%d: %w", t.Name, part.ID, err)
+			}
+			if to == nil {
+				continue
+			}
+			moves = append(moves, Move{
+				Topic:     t.Name,
+				Partition: part.ID,
+				From:      append([]int32(nil), part.Replicas...),
+				To:        to,
+			})
+		}
+	}
+	return moves, nil
+}
+
+// replace returns the partition's replica list with every dropped broker
+// swapped for a planner broker, or nil if no replica is dropped.
+func (p *Planner) replace(c *topology.Cluster, part topology.Partition, drop map[int32]bool) ([]int32, error) {
+	chosen := make(map[int32]bool, len(part.Replicas))
+	use := p.newSpread()
+	affected := false
+	for _, id := range part.Replicas {
+		if drop[id] {
+			affected = true
+			continue
+		}
+		chosen[id] = true
+		use.add(p.domains(brokerOf(c, id)))
+	}
+	if !affected {
+		return nil, nil
+	}
+
+	to := append([]int32(nil), part.Replicas...)
+	for pos, id := range to {
+		if !drop[id] {
+			continue
+		}
+		best := p.pick(pos, chosen, use, nil)
+		if best == nil {
+			return nil, fmt.Errorf("%w: RF %d, %d broker(s) left", ErrNotEnoughBrokers, len(to), len(p.brokers))
+		}
+		to[pos] = best.ID
+		chosen[best.ID] = true
+		use.add(p.domains(*best))
+		p.load.Replicas[best.ID]++
+		if pos == 0 {
+			p.load.Leaders[best.ID]++
+		}
+	}
+	return to, nil
+}
+
+// brokerOf returns the broker with the given ID, or a rack-less placeholder
+// for a broker missing from metadata.
+func brokerOf(c *topology.Cluster, id int32) topology.Broker {
+	if b, ok := c.Broker(id); ok {
+		return b
+	}
+	return topology.Broker{ID: id}
+}
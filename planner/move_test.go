@@ -0,0 +1,71 @@
+package planner
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainReplacesInPlace(t *testing.T) {
+	// Rack C (brokers 3 and 6) is leaving.
+	bs := brokers("rack-a", "rack-b", "rack-c", "rack-a", "rack-b", "rack-c")
+	c, _ := assign(t, bs, 12, 3)
+
+	p, err := New([]topology.Broker{bs[0], bs[1], bs[3], bs[4]}, WithLoad(LoadOf(c)))
+	require.NoError(t, err)
+	moves, err := p.Drain(c, 3, 6)
+	require.NoError(t, err)
+	require.Len(t, moves, 12, "every partition has a replica in rack-c")
+
+	replicas := make(map[int32]int)
+	for _, m := range moves {
+		require.Len(t, m.To, 3)
+		for i, id := range m.To {
+			assert.NotContains(t, []int32{3, 6}, id)
+			if m.From[i] != 3 && m.From[i] != 6 {
+				assert.Equal(t, m.From[i], id, "%s-%d: surviving replica %d moved", m.Topic, m.Partition, m.From[i])
+			}
+			replicas[id]++
+		}
+		projected := topology.Partition{Replicas: m.To}
+		assert.Equal(t, 2, c.RackSpread(projected), "%s-%d keeps both remaining racks", m.Topic, m.Partition)
+	}
+	for id, n := range replicas {
+		assert.InDelta(t, 9, n, 1, "broker %d", id)
+	}
+}
+
+func TestDrainSkipsUnaffectedPartitions(t *testing.T) {
+	bs := brokers("rack-a", "rack-b", "rack-c", "rack-d")
+	c := topology.NewCluster(bs...)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2}},
+		{ID: 1, Leader: 4, Replicas: []int32{4, 1}},
+	}})
+
+	p, err := New(bs[:3])
+	require.NoError(t, err)
+	moves, err := p.Drain(c, 4)
+	require.NoError(t, err)
+	require.Len(t, moves, 1)
+	assert.Equal(t, Move{Topic: "orders", Partition: 1, From: []int32{4, 1}, To: []int32{2, 1}}, moves[0])
+}
+
+func TestDrainErrors(t *testing.T) {
+	bs := brokers("rack-a", "rack-b")
+	c := topology.NewCluster(bs...)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2}},
+	}})
+
+	p, err := New(bs[:1])
+	require.NoError(t, err)
+	_, err = p.Drain(c, 2)
+	assert.ErrorIs(t, err, ErrNotEnoughBrokers)
+
+	_, err = p.Drain(c, 1)
+	assert.ErrorContains(t, err, "both drained and a placement target")
+}
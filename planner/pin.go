@@ -0,0 +1,101 @@
+package planner
+
+import (
+	"fmt"
+
+	"kafka-rack-awareness/topology"
+)
+
+// PinLeaders makes a broker in rack the preferred leader of every partition
+// of a topic, for clients that want leaders close to them (see
+// docs/APPLICATION_AWARENESS.md). A partition that already has a replica in
+// the rack only has its replica list reordered, which moves no data; the
+// least-leading such replica goes first and the others keep their order.
+// A partition without one swaps a replica for the least-loaded broker in the
+// rack, taking it from the rack with the most replicas of the partition, so
+// it spans no fewer racks than before.
+//
+// The moves only change the preferred leader. Leadership follows once a
+// preferred-replica election runs for the partitions.
+func (p *Planner) PinLeaders(c *topology.Cluster, topic, rack string) ([]Move, error) {
+	t, ok := c.Topic(topic)
+	if !ok {
+		return nil, fmt.Errorf("unknown topic %q", topic)
+	}
+	var inRack []topology.Broker
+	for _, b := range p.brokers {
+		if b.Rack == rack {
+			inRack = append(inRack, b)
+		}
+	}
+	if rack == "" || len(inRack) == 0 {
+		return nil, fmt.Errorf("no brokers in rack %q", rack)
+	}
+
+	var moves []Move
+	for _, part := range t.Partitions {
+		if len(part.Replicas) == 0 || c.RackOf(part.Replicas[0]) == rack {
+			continue
+		}
+		to := p.pin(c, part, rack, inRack)
+		p.load.Leaders[part.Replicas[0]]--
+		p.load.Leaders[to[0]]++
+		moves = append(moves, Move{
+			Topic:     t.Name,
+			Partition: part.ID,
+			From:      append([]int32(nil), part.Replicas...),
+			To:        to,
+		})
+	}
+	return moves, nil
+}
+
+// pin returns the partition's replica list led by a broker in rack. If no
+// replica is in the rack, every broker in inRack is free to take one.
+func (p *Planner) pin(c *topology.Cluster, part topology.Partition, rack string, inRack []topology.Broker) []int32 {
+	leader := -1
+	for i, id := range part.Replicas {
+		if c.RackOf(id) == rack && (leader < 0 || p.load.Leaders[id] < p.load.Leaders[part.Replicas[leader]]) {
+			leader = i
+		}
+	}
+	if leader >= 0 {
+		return promote(part.Replicas, leader, part.Replicas[leader])
+	}
+
+	var best *topology.Broker
+	for i := range inRack {
+		b := &inRack[i]
+		if best == nil || p.load.Replicas[b.ID] < p.load.Replicas[best.ID] ||
+			(p.load.Replicas[b.ID] == p.load.Replicas[best.ID] && p.load.Leaders[b.ID] < p.load.Leaders[best.ID]) {
+			best = b
+		}
+	}
+
+	// Give up the last replica of the most crowded rack.
+	perRack := make(map[string]int)
+	for _, id := range part.Replicas {
+		perRack[p.rackOf(brokerOf(c, id))]++
+	}
+	drop := 0
+	for i, id := range part.Replicas {
+		if perRack[p.rackOf(brokerOf(c, id))] >= perRack[p.rackOf(brokerOf(c, part.Replicas[drop]))] {
+			drop = i
+		}
+	}
+	p.load.Replicas[part.Replicas[drop]]--
+	p.load.Replicas[best.ID]++
+	return promote(part.Replicas, drop, best.ID)
+}
+
+// promote returns replicas with the one at pos removed and id put first.
+func promote(replicas []int32, pos int, id int32) []int32 {
+	to := make([]int32, 0, len(replicas))
+	to = append(to, id)
+	for i, r := range replicas {
+		if i != pos {
+			to = append(to, r)
+		}
+	}
+	return to
+}
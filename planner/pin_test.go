@@ -0,0 +1,68 @@
+package planner
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinLeaders(t *testing.T) {
+	bs := brokers("rack-a", "rack-b", "rack-c", "rack-a")
+	c := topology.NewCluster(bs...)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		// Already led from rack-a.
+		{ID: 0, Replicas: []int32{1, 2, 3}},
+		// One replica in rack-a moves to the front.
+		{ID: 1, Replicas: []int32{2, 3, 1}},
+		// Two replicas in rack-a: broker 4 leads nothing yet, so it wins.
+		{ID: 2, Replicas: []int32{3, 4, 1}},
+		// No replica in rack-a: one is swapped in for the last replica.
+		{ID: 3, Replicas: []int32{2, 3}},
+	}})
+
+	p, err := New(bs, WithLoad(LoadOf(c)))
+	require.NoError(t, err)
+	moves, err := p.PinLeaders(c, "orders", "rack-a")
+	require.NoError(t, err)
+
+	assert.Equal(t, []Move{
+		{Topic: "orders", Partition: 1, From: []int32{2, 3, 1}, To: []int32{1, 2, 3}},
+		{Topic: "orders", Partition: 2, From: []int32{3, 4, 1}, To: []int32{4, 3, 1}},
+		{Topic: "orders", Partition: 3, From: []int32{2, 3}, To: []int32{4, 2}},
+	}, moves)
+	for _, m := range moves {
+		part := c.Topics[0].Partitions[m.Partition]
+		assert.Equal(t, c.RackSpread(part), len(topology.RacksForBrokers(c.BrokerRacks(), m.To)), "partition %d", m.Partition)
+	}
+}
+
+func TestPinLeadersGivesUpCrowdedRack(t *testing.T) {
+	bs := brokers("rack-a", "rack-b", "rack-b", "rack-c")
+	c := topology.NewCluster(bs...)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Replicas: []int32{2, 3, 4}},
+	}})
+
+	p, err := New(bs)
+	require.NoError(t, err)
+	moves, err := p.PinLeaders(c, "orders", "rack-a")
+	require.NoError(t, err)
+	require.Len(t, moves, 1)
+	assert.Equal(t, []int32{1, 2, 4}, moves[0].To, "rack-b had two replicas, so it gives one up")
+}
+
+func TestPinLeadersErrors(t *testing.T) {
+	bs := brokers("rack-a", "rack-b")
+	c := topology.NewCluster(bs...)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{{ID: 0, Replicas: []int32{2, 1}}}})
+
+	p, err := New(bs)
+	require.NoError(t, err)
+	_, err = p.PinLeaders(c, "orders", "rack-z")
+	assert.EqualError(t, err, `no brokers in rack "rack-z"`)
+	_, err = p.PinLeaders(c, "payments", "rack-a")
+	assert.EqualError(t, err, `unknown topic "payments"`)
+}
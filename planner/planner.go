@@ -0,0 +1,306 @@
+// Package planner generates rack-aware replica assignments.
+//
+// kafka-reassign-partitions.sh --generate does not guarantee rack diversity
+// (see Edge Case 9 in docs/KAFKA_RACK_AWARENESS.md). The planner places
+// replicas greedily, one partition at a time, ranking candidate brokers by:
+//
+//  1. how many replicas of the partition are already in the broker's rack,
+//     so every partition spans min(RF, racks) racks and the surplus is spread
+//     as evenly as possible when RF exceeds the rack count;
+//  2. how many replicas (or, for the first replica, leaders) the broker
+//     already holds, so load stays balanced across brokers even when racks
+//     have unequal broker counts;
+//  3. how often the broker already sits at this position in a replica list,
+//     so failover leadership is balanced as well.
+//
+// Brokers without a rack are treated as if each were its own rack, matching
+// Kafka's behaviour for mixed configurations.
+//
+// With WithHierarchy, the first criterion becomes a series: replicas already
+// in the broker's datacenter (or whatever the outermost level is), then in
+// its zone, and so on down to its rack. Partitions therefore span as many
+// datacenters as possible first and as many racks as possible second.
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"kafka-rack-awareness/topology"
+)
+
+// ErrNotEnoughBrokers is returned when the replication factor exceeds the
+// number of brokers (Edge Case 7).
+var ErrNotEnoughBrokers = errors.New("replication factor exceeds broker count")
+
+// Request describes the partitions to assign.
+type Request struct {
+	Topic             string
+	Partitions        int
+	ReplicationFactor int
+}
+
+// Assignment is an explicit replica assignment for one topic. Replicas[i] is
+// the ordered replica list of partition i; its first entry is the preferred
+// leader.
+type Assignment struct {
+	Topic    string
+	Replicas [][]int32
+}
+
+// Leaders returns the preferred leader of every partition.
+func (a Assignment) Leaders() []int32 {
+	leaders := make([]int32, len(a.Replicas))
+	for i, replicas := range a.Replicas {
+		leaders[i] = -1
+		if len(replicas) > 0 {
+			leaders[i] = replicas[0]
+		}
+	}
+	return leaders
+}
+
+// AsTopic returns the topology the assignment would produce once every
+// replica is in sync and the preferred leaders lead, so it can be audited
+// before it is applied.
+func (a Assignment) AsTopic() topology.Topic {
+	t := topology.Topic{Name: a.Topic}
+	for i, replicas := range a.Replicas {
+		p := topology.Partition{
+			Topic:    a.Topic,
+			ID:       int32(i),
+			Leader:   -1,
+			Replicas: append([]int32(nil), replicas...),
+			ISR:      append([]int32(nil), replicas...),
+		}
+		if len(replicas) > 0 {
+			p.Leader = replicas[0]
+		}
+		t.Partitions = append(t.Partitions, p)
+	}
+	return t
+}
+
+// Load counts replicas and leaders per broker.
+type Load struct {
+	Replicas map[int32]int
+	Leaders  map[int32]int
+}
+
+// NewLoad returns an empty load.
+func NewLoad() Load {
+	return Load{Replicas: make(map[int32]int), Leaders: make(map[int32]int)}
+}
+
+// LoadOf counts the replicas and preferred leaders already placed in a
+// cluster, so new assignments can balance against existing topics.
+func LoadOf(c *topology.Cluster) Load {
+	l := NewLoad()
+	for _, t := range c.Topics {
+		for _, p := range t.Partitions {
+			l.add(p.Replicas)
+		}
+	}
+	return l
+}
+
+func (l Load) add(replicas []int32) {
+	for i, id := range replicas {
+		l.Replicas[id]++
+		if i == 0 {
+			l.Leaders[id]++
+		}
+	}
+}
+
+func (l Load) clone() Load {
+	c := NewLoad()
+	for id, n := range l.Replicas {
+		c.Replicas[id] = n
+	}
+	for id, n := range l.Leaders {
+		c.Leaders[id] = n
+	}
+	return c
+}
+
+// Planner assigns replicas to a fixed set of brokers.
+type Planner struct {
+	brokers   []topology.Broker
+	load      Load
+	hierarchy *topology.Hierarchy
+}
+
+// Option configures a Planner.
+type Option func(*Planner)
+
+// WithLoad makes the planner balance against replicas that are already
+// placed, for example LoadOf(snapshot).
+func WithLoad(l Load) Option {
+	return func(p *Planner) { p.load = l.clone() }
+}
+
+// WithHierarchy spreads replicas over every level of a rack hierarchy,
+// outermost first. Every broker's rack must fit the hierarchy.
+func WithHierarchy(h *topology.Hierarchy) Option {
+	return func(p *Planner) { p.hierarchy = h }
+}
+
+// New returns a planner for the given brokers.
+func New(brokers []topology.Broker, opts ...Option) (*Planner, error) {
+	if len(brokers) == 0 {
+		return nil, errors.New("no brokers to assign to")
+	}
+	p := &Planner{
+		brokers: append([]topology.Broker(nil), brokers...),
+		load:    NewLoad(),
+	}
+	sort.Slice(p.brokers, func(i, j int) bool { return p.brokers[i].ID < p.brokers[j].ID })
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.hierarchy != nil {
+		if err := p.hierarchy.Check(&topology.Cluster{Brokers: p.brokers}); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Assign produces a replica assignment for req. The planner's load is
+// updated, so successive calls balance across topics.
+func (p *Planner) Assign(req Request) (Assignment, error) {
+	if req.Partitions <= 0 {
+		return Assignment{}, fmt.Errorf("partition count must be positive, got %d", req.Partitions)
+	}
+	if req.ReplicationFactor <= 0 {
+		return Assignment{}, fmt.Errorf("replication factor must be positive, got %d", req.ReplicationFactor)
+	}
+	if req.ReplicationFactor > len(p.brokers) {
+		return Assignment{}, fmt.Errorf("%w: RF %d, %d broker(s)", ErrNotEnoughBrokers, req.ReplicationFactor, len(p.brokers))
+	}
+
+	positions := make([]map[int32]int, req.ReplicationFactor)
+	for i := range positions {
+		positions[i] = make(map[int32]int)
+	}
+
+	a := Assignment{Topic: req.Topic, Replicas: make([][]int32, req.Partitions)}
+	for partition := range a.Replicas {
+		replicas := p.place(req.ReplicationFactor, positions)
+		a.Replicas[partition] = replicas
+		p.load.add(replicas)
+		for i, id := range replicas {
+			positions[i][id]++
+		}
+	}
+	return a, nil
+}
+
+// place picks rf distinct brokers for one partition.
+func (p *Planner) place(rf int, positions []map[int32]int) []int32 {
+	replicas := make([]int32, 0, rf)
+	chosen := make(map[int32]bool, rf)
+	use := p.newSpread()
+
+	for pos := 0; pos < rf; pos++ {
+		best := p.pick(pos, chosen, use, positions[pos])
+		replicas = append(replicas, best.ID)
+		chosen[best.ID] = true
+		use.add(p.domains(*best))
+	}
+	return replicas
+}
+
+// pick returns the best broker for position pos that is not already chosen,
+// or nil if every broker is.
+func (p *Planner) pick(pos int, chosen map[int32]bool, use spread, position map[int32]int) *topology.Broker {
+	var best *topology.Broker
+	var bestScore []int
+	for i := range p.brokers {
+		b := &p.brokers[i]
+		if chosen[b.ID] {
+			continue
+		}
+		score := p.score(b, pos, use, position)
+		if best == nil || less(score, bestScore) {
+			best, bestScore = b, score
+		}
+	}
+	return best
+}
+
+func (p *Planner) score(b *topology.Broker, pos int, use spread, position map[int32]int) []int {
+	load := p.load.Replicas[b.ID]
+	if pos == 0 {
+		load = p.load.Leaders[b.ID]
+	}
+	score := make([]int, 0, len(use)+3)
+	for level, d := range p.domains(*b) {
+		score = append(score, use[level][d])
+	}
+	return append(score, load, position[b.ID], p.load.Replicas[b.ID])
+}
+
+// spread counts the replicas of one partition per failure domain, with one
+// map per level from the outermost hierarchy level down to the rack.
+type spread []map[string]int
+
+func (p *Planner) newSpread() spread {
+	depth := 1
+	if p.hierarchy != nil {
+		depth = len(p.hierarchy.Levels())
+	}
+	s := make(spread, depth)
+	for i := range s {
+		s[i] = make(map[string]int)
+	}
+	return s
+}
+
+func (s spread) add(domains []string) {
+	for level, d := range domains {
+		s[level][d]++
+	}
+}
+
+// domains returns the failure domain of a broker at every level, outermost
+// first. Without a hierarchy that is just its rack.
+func (p *Planner) domains(b topology.Broker) []string {
+	if p.hierarchy == nil {
+		return []string{rackKey(b)}
+	}
+	if b.HasRack() {
+		if ds, err := p.hierarchy.Domains(b.Rack); err == nil {
+			return ds
+		}
+	}
+	// Brokers without a rack, or outside the planner such as unknown
+	// replicas in a snapshot, are their own domain at every level.
+	ds := make([]string, len(p.hierarchy.Levels()))
+	for i := range ds {
+		ds[i] = rackKey(b)
+	}
+	return ds
+}
+
+// rackKey returns the failure domain of a broker. Brokers without a rack
+// are their own failure domain.
+func rackKey(b topology.Broker) string {
+	if b.HasRack() {
+		return b.Rack
+	}
+	return fmt.Sprintf("\x00broker-%d", b.ID)
+}
+
+// less compares two scores lexicographically. Brokers are visited in ID
+// order, so ties resolve to the lowest ID.
+func less(a, b []int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package planner
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/audit"
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func brokers(racks ...string) []topology.Broker {
+	bs := make([]topology.Broker, len(racks))
+	for i, rack := range racks {
+		bs[i] = topology.Broker{ID: int32(i + 1), Rack: rack}
+	}
+	return bs
+}
+
+func assign(t *testing.T, bs []topology.Broker, partitions, rf int, opts ...Option) (*topology.Cluster, Assignment) {
+	t.Helper()
+	p, err := New(bs, opts...)
+	require.NoError(t, err)
+	a, err := p.Assign(Request{Topic: "orders", Partitions: partitions, ReplicationFactor: rf})
+	require.NoError(t, err)
+	require.Len(t, a.Replicas, partitions)
+
+	c := topology.NewCluster(bs...)
+	c.AddTopic(a.AsTopic())
+	return c, a
+}
+
+func assertDistinctReplicas(t *testing.T, a Assignment, rf int) {
+	t.Helper()
+	for i, replicas := range a.Replicas {
+		require.Len(t, replicas, rf, "partition %d", i)
+		seen := make(map[int32]bool)
+		for _, id := range replicas {
+			assert.False(t, seen[id], "partition %d repeats broker %d", i, id)
+			seen[id] = true
+		}
+	}
+}
+
+func TestAssignOneBrokerPerRack(t *testing.T) {
+	c, a := assign(t, brokers("rack-a", "rack-b", "rack-c"), 9, 3)
+	assertDistinctReplicas(t, a, 3)
+
+	topic, _ := c.Topic("orders")
+	for _, p := range topic.Partitions {
+		assert.Equal(t, 3, c.RackSpread(p))
+	}
+	assert.Equal(t, map[string]int{"rack-a": 3, "rack-b": 3, "rack-c": 3}, c.LeadersByRack(topic.Partitions))
+	assert.Empty(t, audit.New().Audit(c).Violations)
+}
+
+func TestAssignBalancesBrokers(t *testing.T) {
+	bs := brokers("rack-a", "rack-b", "rack-c", "rack-a", "rack-b", "rack-c")
+	_, a := assign(t, bs, 12, 3)
+	assertDistinctReplicas(t, a, 3)
+
+	load := NewLoad()
+	for _, replicas := range a.Replicas {
+		load.add(replicas)
+	}
+	for _, b := range bs {
+		assert.Equal(t, 6, load.Replicas[b.ID], "replicas on broker %d", b.ID)
+		assert.Equal(t, 2, load.Leaders[b.ID], "leaders on broker %d", b.ID)
+	}
+}
+
+func TestAssignUnequalRacks(t *testing.T) {
+	// Case 5: rack-a has four brokers, rack-b and rack-c one each.
+	bs := brokers("rack-a", "rack-a", "rack-a", "rack-a", "rack-b", "rack-c")
+	c, a := assign(t, bs, 12, 3)
+	assertDistinctReplicas(t, a, 3)
+
+	topic, _ := c.Topic("orders")
+	for _, p := range topic.Partitions {
+		assert.Equal(t, 3, c.RackSpread(p), "partition %d", p.ID)
+	}
+
+	load := NewLoad()
+	for _, replicas := range a.Replicas {
+		load.add(replicas)
+	}
+	// Diversity forces every partition onto the single rack-b and rack-c
+	// brokers; rack-a's share is spread evenly over its four brokers.
+	assert.Equal(t, 12, load.Replicas[5])
+	assert.Equal(t, 12, load.Replicas[6])
+	for id := int32(1); id <= 4; id++ {
+		assert.Equal(t, 3, load.Replicas[id], "replicas on broker %d", id)
+	}
+	for _, b := range bs {
+		assert.Equal(t, 2, load.Leaders[b.ID], "leaders on broker %d", b.ID)
+	}
+}
+
+func TestAssignRFExceedsRacks(t *testing.T) {
+	// Case 3: two racks, RF=3.
+	bs := brokers("rack-a", "rack-a", "rack-b", "rack-b")
+	c, a := assign(t, bs, 8, 3)
+	assertDistinctReplicas(t, a, 3)
+
+	topic, _ := c.Topic("orders")
+	perRack := c.ReplicasByRack(topic.Partitions)
+	assert.Equal(t, 12, perRack["rack-a"])
+	assert.Equal(t, 12, perRack["rack-b"])
+	for _, p := range topic.Partitions {
+		assert.Equal(t, 2, c.RackSpread(p), "partition %d", p.ID)
+	}
+
+	report := audit.New().Audit(c)
+	assert.Empty(t, report.OfKind(audit.KindSharedRack))
+	assert.Len(t, report.OfKind(audit.KindRFExceedsRacks), 1)
+}
+
+func TestAssignBrokersWithoutRack(t *testing.T) {
+	bs := brokers("rack-a", "rack-a", "", "")
+	_, a := assign(t, bs, 4, 3)
+
+	for i, replicas := range a.Replicas {
+		inRackA := 0
+		for _, id := range replicas {
+			if id == 1 || id == 2 {
+				inRackA++
+			}
+		}
+		assert.Equal(t, 1, inRackA, "partition %d should have one rack-a replica", i)
+	}
+}
+
+func TestAssignWithExistingLoad(t *testing.T) {
+	bs := brokers("rack-a", "rack-a", "rack-b", "rack-b")
+	existing := topology.NewCluster(bs...)
+	existing.AddTopic(topology.Topic{Name: "old", Partitions: []topology.Partition{
+		{ID: 0, Replicas: []int32{1, 3}},
+		{ID: 1, Replicas: []int32{1, 3}},
+	}})
+
+	_, a := assign(t, bs, 2, 2, WithLoad(LoadOf(existing)))
+
+	for _, replicas := range a.Replicas {
+		assert.ElementsMatch(t, []int32{2, 4}, replicas)
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	_, err := New(nil)
+	assert.Error(t, err)
+
+	p, err := New(brokers("rack-a", "rack-b", "rack-c"))
+	require.NoError(t, err)
+
+	// Edge Case 7: RF=5 with three brokers.
+	_, err = p.Assign(Request{Topic: "t", Partitions: 1, ReplicationFactor: 5})
+	assert.ErrorIs(t, err, ErrNotEnoughBrokers)
+
+	_, err = p.Assign(Request{Topic: "t", Partitions: 0, ReplicationFactor: 1})
+	assert.Error(t, err)
+	_, err = p.Assign(Request{Topic: "t", Partitions: 1, ReplicationFactor: 0})
+	assert.Error(t, err)
+}
+
+func TestAssignmentAsTopic(t *testing.T) {
+	a := Assignment{Topic: "orders", Replicas: [][]int32{{2, 1}, {}}}
+
+	assert.Equal(t, []int32{2, -1}, a.Leaders())
+	topic := a.AsTopic()
+	require.Len(t, topic.Partitions, 2)
+	assert.Equal(t, topology.Partition{Topic: "orders", ID: 0, Leader: 2, Replicas: []int32{2, 1}, ISR: []int32{2, 1}}, topic.Partitions[0])
+	assert.Equal(t, int32(-1), topic.Partitions[1].Leader)
+}
+
+func TestAssignHierarchy(t *testing.T) {
+	// dc1 has three racks and dc2 one: flat rack spread happily keeps both
+	// replicas in dc1.
+	bs := brokers("dc1-a", "dc1-b", "dc1-c", "dc2-a")
+	h, err := topology.SplitHierarchy("-", "dc", "rack")
+	require.NoError(t, err)
+	auditor := audit.New(audit.WithHierarchy(h))
+
+	c, _ := assign(t, bs, 4, 2)
+	assert.NotEmpty(t, auditor.Audit(c).OfKind(audit.KindSharedDomain))
+
+	c, a := assign(t, bs, 4, 2, WithHierarchy(h))
+	assertDistinctReplicas(t, a, 2)
+	for i, replicas := range a.Replicas {
+		assert.Contains(t, replicas, int32(4), "partition %d must reach dc2", i)
+	}
+	assert.Empty(t, auditor.Audit(c).Violations)
+
+	_, err = New(brokers("dc1-a", "legacy"), WithHierarchy(h))
+	assert.EqualError(t, err, `broker 2: rack "legacy" does not match the dc/rack hierarchy`)
+}
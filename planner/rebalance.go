@@ -0,0 +1,276 @@
+package planner
+
+import (
+	"kafka-rack-awareness/topology"
+)
+
+// BrokerUsage is how much a broker holds.
+type BrokerUsage struct {
+	Broker   int32
+	Rack     string
+	Replicas int
+	// Bytes is the size of the broker's replicas, or 0 when sizes are unknown.
+	Bytes int64
+}
+
+// Usage returns the replica count and, if sizes is non-nil, the bytes on
+// disk of every broker in the cluster, in broker order. A replica missing
+// from sizes, such as one a plan has yet to create, is counted at the size
+// of the partition's largest known replica.
+func Usage(c *topology.Cluster, sizes topology.ReplicaSizes) []BrokerUsage {
+	usage := make([]BrokerUsage, len(c.Brokers))
+	index := make(map[int32]int, len(c.Brokers))
+	for i, b := range c.Brokers {
+		usage[i] = BrokerUsage{Broker: b.ID, Rack: b.Rack}
+		index[b.ID] = i
+	}
+	for _, t := range c.Topics {
+		for _, p := range t.Partitions {
+			for _, id := range p.Replicas {
+				i, ok := index[id]
+				if !ok {
+					continue
+				}
+				usage[i].Replicas++
+				bytes, ok := sizes.Size(id, t.Name, p.ID)
+				if !ok {
+					bytes = sizes.PartitionSize(p)
+				}
+				usage[i].Bytes += bytes
+			}
+		}
+	}
+	return usage
+}
+
+// RebalanceOption configures Rebalance.
+type RebalanceOption func(*rebalance)
+
+type rebalance struct {
+	sizes     topology.ReplicaSizes
+	tolerance float64
+}
+
+// WithSizes balances bytes on disk, as reported by DescribeLogDirs, instead
+// of replica counts.
+func WithSizes(sizes topology.ReplicaSizes) RebalanceOption {
+	return func(r *rebalance) { r.sizes = sizes }
+}
+
+// WithTolerance sets how far below the mean a broker may sit before it is
+// considered under-loaded, as a fraction of the mean. The default is 0.1.
+func WithTolerance(t float64) RebalanceOption {
+	return func(r *rebalance) { r.tolerance = t }
+}
+
+// Rebalance moves replicas onto under-loaded brokers, such as brokers just
+// added to a rack, which Kafka never does on its own (Edge Case 3 in
+// docs/KAFKA_RACK_AWARENESS.md). Only the planner's brokers give or receive
+// replicas.
+//
+// Each step moves one replica from a busier broker to the most under-loaded
+// broker, in the same position of the replica list, and only
+// if the receiving broker's rack holds no other replica of the partition.
+// Replicas from the receiver's own rack are preferred, so rack layout is
+// untouched where possible. A move is only made if it narrows the gap
+// between the two brokers and does not push the receiver past the tolerance
+// band above the mean, so the plan stops as soon as no under-loaded broker
+// can be helped, keeping the number of moves small.
+func (p *Planner) Rebalance(c *topology.Cluster, opts ...RebalanceOption) []Move {
+	cfg := rebalance{tolerance: 0.1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	weight := func(part topology.Partition) int64 {
+		if cfg.sizes == nil {
+			return 1
+		}
+		return cfg.sizes.PartitionSize(part)
+	}
+
+	member := make(map[int32]bool, len(p.brokers))
+	for _, b := range p.brokers {
+		member[b.ID] = true
+	}
+
+	var parts []topology.Partition
+	load := make(map[int32]int64, len(p.brokers))
+	var total int64
+	for _, t := range c.Topics {
+		for _, part := range t.Partitions {
+			part.Replicas = append([]int32(nil), part.Replicas...)
+			parts = append(parts, part)
+			w := weight(part)
+			for _, id := range part.Replicas {
+				if member[id] {
+					load[id] += w
+					total += w
+				}
+			}
+		}
+	}
+	mean := float64(total) / float64(len(p.brokers))
+	low, high := mean*(1-cfg.tolerance), mean*(1+cfg.tolerance)
+
+	original := make([][]int32, len(parts))
+	for i, part := range parts {
+		original[i] = append([]int32(nil), part.Replicas...)
+	}
+
+	stuck := make(map[int32]bool)
+	for {
+		receiver := p.mostUnderLoaded(load, low, stuck)
+		if receiver == nil {
+			break
+		}
+		i, pos := p.bestDonation(c, parts, load, receiver, high, weight)
+		if i < 0 {
+			stuck[receiver.ID] = true
+			continue
+		}
+		donor := parts[i].Replicas[pos]
+		w := weight(parts[i])
+		parts[i].Replicas[pos] = receiver.ID
+		load[donor] -= w
+		load[receiver.ID] += w
+		p.load.Replicas[donor]--
+		p.load.Replicas[receiver.ID]++
+		if pos == 0 {
+			p.load.Leaders[donor]--
+			p.load.Leaders[receiver.ID]++
+		}
+	}
+
+	var moves []Move
+	for i, part := range parts {
+		if !equal(original[i], part.Replicas) {
+			moves = append(moves, Move{Topic: part.Topic, Partition: part.ID, From: original[i], To: part.Replicas})
+		}
+	}
+	return moves
+}
+
+// mostUnderLoaded returns the least loaded broker below low that is not
+// stuck, or nil.
+func (p *Planner) mostUnderLoaded(load map[int32]int64, low float64, stuck map[int32]bool) *topology.Broker {
+	var best *topology.Broker
+	for i := range p.brokers {
+		b := &p.brokers[i]
+		if stuck[b.ID] || float64(load[b.ID]) >= low {
+			continue
+		}
+		if best == nil || load[b.ID] < load[best.ID] {
+			best = b
+		}
+	}
+	return best
+}
+
+// bestDonation picks the partition and replica position to hand to the
+// receiver, or -1 if no move is allowed.
+func (p *Planner) bestDonation(c *topology.Cluster, parts []topology.Partition, load map[int32]int64,
+	receiver *topology.Broker, high float64, weight func(topology.Partition) int64) (int, int) {
+
+	member := make(map[int32]bool, len(p.brokers))
+	for _, b := range p.brokers {
+		member[b.ID] = true
+	}
+	receiverRack := rackKey(*receiver)
+
+	bestPart, bestPos := -1, -1
+	var bestScore []int64
+	for i, part := range parts {
+		if contains(part.Replicas, receiver.ID) {
+			continue
+		}
+		w := weight(part)
+		if w <= 0 || float64(load[receiver.ID]+w) > high {
+			continue
+		}
+		for pos, donor := range part.Replicas {
+			if !member[donor] || w >= load[donor]-load[receiver.ID] {
+				continue
+			}
+			if !p.rackFree(c, part.Replicas, pos, receiverRack) || !p.keepsSpread(c, part.Replicas, pos, *receiver) {
+				continue
+			}
+			sameRack := int64(0)
+			if rackKey(brokerOf(c, donor)) == receiverRack {
+				sameRack = 1
+			}
+			follower := int64(0)
+			if pos > 0 {
+				follower = 1
+			}
+			// Higher is better: biggest replica first so fewer moves close
+			// the gap, then same-rack moves, the busiest donor, and
+			// followers over leaders.
+			score := []int64{w, sameRack, load[donor], follower}
+			if bestPart < 0 || greater(score, bestScore) {
+				bestPart, bestPos, bestScore = i, pos, score
+			}
+		}
+	}
+	return bestPart, bestPos
+}
+
+// rackFree reports whether no replica other than the one at skip is in rack.
+func (p *Planner) rackFree(c *topology.Cluster, replicas []int32, skip int, rack string) bool {
+	for i, id := range replicas {
+		if i != skip && rackKey(brokerOf(c, id)) == rack {
+			return false
+		}
+	}
+	return true
+}
+
+// keepsSpread reports whether replacing the replica at pos with receiver
+// leaves the partition in at least as many domains at every hierarchy level.
+func (p *Planner) keepsSpread(c *topology.Cluster, replicas []int32, pos int, receiver topology.Broker) bool {
+	before, after := p.newSpread(), p.newSpread()
+	for i, id := range replicas {
+		before.add(p.domains(brokerOf(c, id)))
+		if i == pos {
+			after.add(p.domains(receiver))
+		} else {
+			after.add(p.domains(brokerOf(c, id)))
+		}
+	}
+	for level := range before {
+		if len(after[level]) < len(before[level]) {
+			return false
+		}
+	}
+	return true
+}
+
+func greater(a, b []int64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+func contains(ids []int32, id int32) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b []int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package planner
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// apply returns a copy of c with the moves applied.
+func apply(c *topology.Cluster, moves []Move) *topology.Cluster {
+	out := topology.NewCluster(c.Brokers...)
+	for _, t := range c.Topics {
+		copied := t
+		copied.Partitions = append([]topology.Partition(nil), t.Partitions...)
+		for i, p := range copied.Partitions {
+			for _, m := range moves {
+				if m.Topic == t.Name && m.Partition == p.ID {
+					copied.Partitions[i].Replicas = m.To
+				}
+			}
+		}
+		out.AddTopic(copied)
+	}
+	return out
+}
+
+func TestRebalanceOntoNewBroker(t *testing.T) {
+	bs := brokers("rack-a", "rack-b", "rack-c", "rack-a", "rack-b", "rack-c")
+	existing, _ := assign(t, bs, 12, 3)
+
+	// Broker 7 joins rack-a with no replicas.
+	bs = append(bs, topology.Broker{ID: 7, Rack: "rack-a"})
+	c := apply(existing, nil)
+	c.Brokers = topology.NewCluster(bs...).Brokers
+
+	p, err := New(bs)
+	require.NoError(t, err)
+	moves := p.Rebalance(c)
+	// Every partition already spans all three racks, so broker 7 can only
+	// take rack-a replicas: 12 of them over brokers 1, 4 and 7.
+	require.Len(t, moves, 4)
+
+	for _, m := range moves {
+		assert.Contains(t, m.To, int32(7))
+		for i := range m.From {
+			if m.From[i] != m.To[i] {
+				assert.Contains(t, []int32{1, 4}, m.From[i], "replica came from rack-a")
+			}
+		}
+	}
+
+	after := apply(c, moves)
+	for _, u := range Usage(after, nil) {
+		want := 6
+		if u.Rack == "rack-a" {
+			want = 4
+		}
+		assert.Equal(t, want, u.Replicas, "broker %d", u.Broker)
+	}
+	topic, _ := after.Topic("orders")
+	for _, part := range topic.Partitions {
+		assert.Equal(t, 3, after.RackSpread(part), "partition %d", part.ID)
+	}
+}
+
+func TestRebalanceNeverSharesRack(t *testing.T) {
+	bs := brokers("rack-a", "rack-b", "rack-c")
+	c := topology.NewCluster(append(bs, topology.Broker{ID: 4, Rack: "rack-b"})...)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2}},
+		{ID: 1, Leader: 1, Replicas: []int32{1, 3}},
+		{ID: 2, Leader: 3, Replicas: []int32{3, 1}},
+		{ID: 3, Leader: 2, Replicas: []int32{2, 1}},
+	}})
+
+	p, err := New(c.Brokers)
+	require.NoError(t, err)
+	moves := p.Rebalance(c)
+	require.NotEmpty(t, moves)
+	for _, m := range moves {
+		assert.Equal(t, len(m.To), len(topology.RacksForBrokers(c.BrokerRacks(), m.To)), "%s-%d: %v", m.Topic, m.Partition, m.To)
+	}
+}
+
+func TestRebalanceKeepsDatacenterSpread(t *testing.T) {
+	// Broker 3 is new in dc1. Taking the dc2 follower would keep two racks
+	// but leave the partition in dc1 only.
+	bs := brokers("dc1-a", "dc2-a", "dc1-b")
+	c := topology.NewCluster(bs...)
+	var parts []topology.Partition
+	for i := range 4 {
+		parts = append(parts, topology.Partition{ID: int32(i), Leader: 1, Replicas: []int32{1, 2}})
+	}
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: parts})
+	h, err := topology.SplitHierarchy("-", "dc", "rack")
+	require.NoError(t, err)
+
+	p, err := New(bs, WithHierarchy(h))
+	require.NoError(t, err)
+	moves := p.Rebalance(c)
+	require.NotEmpty(t, moves)
+	for _, m := range moves {
+		assert.Equal(t, []string{"dc1", "dc2"}, topology.RacksForBrokers(h.BrokerDomains(c, 0), m.To), "%s-%d: %v", m.Topic, m.Partition, m.To)
+	}
+}
+
+func TestRebalanceBySize(t *testing.T) {
+	bs := brokers("rack-a", "rack-a", "rack-a")
+	c := topology.NewCluster(bs...)
+	c.AddTopic(topology.Topic{Name: "logs", Partitions: []topology.Partition{
+		{ID: 0, Replicas: []int32{1}},
+		{ID: 1, Replicas: []int32{1}},
+		{ID: 2, Replicas: []int32{2}},
+		{ID: 3, Replicas: []int32{2}},
+	}})
+	sizes := make(topology.ReplicaSizes)
+	sizes.Set(1, "logs", 0, 900)
+	sizes.Set(1, "logs", 1, 100)
+	sizes.Set(2, "logs", 2, 100)
+	sizes.Set(2, "logs", 3, 100)
+
+	p, err := New(bs)
+	require.NoError(t, err)
+
+	// By count every broker but 3 has two replicas, so one moves to 3.
+	byCount := p.Rebalance(c)
+	require.Len(t, byCount, 1)
+
+	p, err = New(bs)
+	require.NoError(t, err)
+	bySize := p.Rebalance(c, WithSizes(sizes), WithTolerance(0.5))
+	require.Len(t, bySize, 1)
+	assert.Equal(t, Move{Topic: "logs", Partition: 1, From: []int32{1}, To: []int32{3}}, bySize[0],
+		"moving the 900-byte replica would only shift the imbalance")
+
+	usage := Usage(apply(c, bySize), sizes)
+	assert.Equal(t, BrokerUsage{Broker: 1, Rack: "rack-a", Replicas: 1, Bytes: 900}, usage[0])
+	assert.Equal(t, BrokerUsage{Broker: 3, Rack: "rack-a", Replicas: 1, Bytes: 100}, usage[2], "new replica sized like its source")
+}
+
+func TestRebalanceBalancedClusterIsNoop(t *testing.T) {
+	bs := brokers("rack-a", "rack-b", "rack-c")
+	c, _ := assign(t, bs, 6, 3)
+	p, err := New(bs)
+	require.NoError(t, err)
+	assert.Empty(t, p.Rebalance(c))
+}
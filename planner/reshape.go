@@ -0,0 +1,105 @@
+package planner
+
+import (
+	"fmt"
+
+	"kafka-rack-awareness/topology"
+)
+
+// Reshape brings every partition of a topic to rf replicas on the planner's
+// brokers, spanning at least minSpread racks; 0 means min(rf, racks), the
+// most the planner's brokers allow. Partitions that already comply are left
+// alone. The others keep their replicas in order as long as they are on a
+// planner broker and their rack is not over-used, and are then topped up by
+// the ranking Assign uses, so a kept preferred leader stays the leader.
+func (p *Planner) Reshape(c *topology.Cluster, topic string, rf, minSpread int) ([]Move, error) {
+	t, ok := c.Topic(topic)
+	if !ok {
+		return nil, fmt.Errorf("unknown topic %q", topic)
+	}
+	if rf <= 0 {
+		return nil, fmt.Errorf("replication factor must be positive, got %d", rf)
+	}
+	if rf > len(p.brokers) {
+		return nil, fmt.Errorf("%w: RF %d, %d broker(s)", ErrNotEnoughBrokers, rf, len(p.brokers))
+	}
+
+	member := make(map[int32]bool, len(p.brokers))
+	racks := make(map[string]bool)
+	for _, b := range p.brokers {
+		member[b.ID] = true
+		racks[p.rackOf(b)] = true
+	}
+	best := min(rf, len(racks))
+	if minSpread == 0 {
+		minSpread = best
+	}
+	if minSpread > best {
+		return nil, fmt.Errorf("%d replica(s) on %d rack(s) cannot span %d racks", rf, len(racks), minSpread)
+	}
+	perRack := (rf + len(racks) - 1) / len(racks)
+
+	var moves []Move
+	for _, part := range t.Partitions {
+		if p.complies(c, part, rf, minSpread, member) {
+			continue
+		}
+
+		to := make([]int32, 0, rf)
+		chosen := make(map[int32]bool, rf)
+		use := p.newSpread()
+		inRack := make(map[string]int)
+		for _, id := range part.Replicas {
+			b := brokerOf(c, id)
+			if len(to) == rf || !member[id] || inRack[p.rackOf(b)] >= perRack {
+				continue
+			}
+			to = append(to, id)
+			chosen[id] = true
+			use.add(p.domains(b))
+			inRack[p.rackOf(b)]++
+		}
+		for len(to) < rf {
+			b := p.pick(len(to), chosen, use, nil)
+			if len(to) == 0 {
+				p.load.Leaders[b.ID]++
+			}
+			p.load.Replicas[b.ID]++
+			to = append(to, b.ID)
+			chosen[b.ID] = true
+			use.add(p.domains(*b))
+		}
+		if equal(to, part.Replicas) {
+			continue
+		}
+		moves = append(moves, Move{
+			Topic:     t.Name,
+			Partition: part.ID,
+			From:      append([]int32(nil), part.Replicas...),
+			To:        to,
+		})
+	}
+	return moves, nil
+}
+
+// complies reports whether a partition already has rf replicas, all on
+// planner brokers, spanning at least minSpread racks.
+func (p *Planner) complies(c *topology.Cluster, part topology.Partition, rf, minSpread int, member map[int32]bool) bool {
+	if len(part.Replicas) != rf {
+		return false
+	}
+	racks := make(map[string]bool)
+	for _, id := range part.Replicas {
+		if !member[id] {
+			return false
+		}
+		racks[p.rackOf(brokerOf(c, id))] = true
+	}
+	return len(racks) >= minSpread
+}
+
+// rackOf returns the innermost failure domain of a broker.
+func (p *Planner) rackOf(b topology.Broker) string {
+	domains := p.domains(b)
+	return domains[len(domains)-1]
+}
@@ -0,0 +1,62 @@
+package planner
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReshape(t *testing.T) {
+	bs := brokers("rack-a", "rack-b", "rack-c", "rack-a")
+	c := topology.NewCluster(bs...)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		// Already fine at RF 2.
+		{ID: 0, Replicas: []int32{1, 2}},
+		// Both replicas in rack-a: the leader stays, the follower moves.
+		{ID: 1, Replicas: []int32{1, 4}},
+		// RF 3: the last replica goes.
+		{ID: 2, Replicas: []int32{3, 2, 1}},
+		// RF 1: a replica in another rack is added.
+		{ID: 3, Replicas: []int32{2}},
+	}})
+
+	p, err := New(bs)
+	require.NoError(t, err)
+	moves, err := p.Reshape(c, "orders", 2, 0)
+	require.NoError(t, err)
+	require.Len(t, moves, 3)
+
+	assert.Equal(t, int32(1), moves[0].Partition)
+	assert.Equal(t, int32(1), moves[0].To[0])
+	assert.NotEqual(t, "rack-a", c.RackOf(moves[0].To[1]))
+	assert.Equal(t, Move{Topic: "orders", Partition: 2, From: []int32{3, 2, 1}, To: []int32{3, 2}}, moves[1])
+	assert.Equal(t, int32(2), moves[2].To[0])
+	assert.Len(t, topology.RacksForBrokers(c.BrokerRacks(), moves[2].To), 2)
+}
+
+func TestReshapeOntoRacks(t *testing.T) {
+	bs := brokers("rack-a", "rack-b", "rack-c")
+	c := topology.NewCluster(bs...)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Replicas: []int32{3, 1}},
+		{ID: 1, Replicas: []int32{1, 2}},
+	}})
+
+	// Only racks a and b are allowed, so broker 3 gives way.
+	p, err := New(bs[:2])
+	require.NoError(t, err)
+	moves, err := p.Reshape(c, "orders", 2, 0)
+	require.NoError(t, err)
+	require.Len(t, moves, 1)
+	assert.Equal(t, []int32{1, 2}, moves[0].To)
+
+	_, err = p.Reshape(c, "orders", 2, 3)
+	assert.EqualError(t, err, "2 replica(s) on 2 rack(s) cannot span 3 racks")
+	_, err = p.Reshape(c, "orders", 3, 0)
+	assert.ErrorIs(t, err, ErrNotEnoughBrokers)
+	_, err = p.Reshape(c, "payments", 2, 0)
+	assert.EqualError(t, err, `unknown topic "payments"`)
+}
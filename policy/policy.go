@@ -0,0 +1,255 @@
+// Package policy validates a proposed topic against a cluster's rack layout
+// before it is created.
+//
+// Edge Cases 4 and 7 in docs/KAFKA_RACK_AWARENESS.md describe topics that
+// Kafka accepts but that cannot keep their promises: a replication factor
+// larger than the number of racks or brokers, or a min.insync.replicas that
+// leaves no room for a failure. A Policy turns them into explicit rejections
+// with a reason for each, so provisioning can refuse a topic before
+// CreateTopics is sent.
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"kafka-rack-awareness/failure"
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/topology"
+)
+
+// Topic is a proposed topic.
+type Topic struct {
+	Name              string            `json:"name"`
+	Partitions        int               `json:"partitions"`
+	ReplicationFactor int               `json:"replication_factor"`
+	Configs           map[string]string `json:"configs,omitempty"`
+}
+
+// Rule identifies which check rejected a topic.
+type Rule string
+
+const (
+	// RuleInvalid means the proposal itself is malformed, such as a
+	// non-positive partition count.
+	RuleInvalid Rule = "invalid"
+	// RuleRFExceedsBrokers means there are fewer brokers than replicas
+	// (Edge Case 7).
+	RuleRFExceedsBrokers Rule = "rf-exceeds-brokers"
+	// RuleRFExceedsRacks means some replicas would have to share a rack.
+	RuleRFExceedsRacks Rule = "rf-exceeds-racks"
+	// RuleMinReplicationFactor means the replication factor is below the
+	// policy minimum.
+	RuleMinReplicationFactor Rule = "rf-below-minimum"
+	// RuleMaxPartitions means the partition count is above the policy
+	// maximum.
+	RuleMaxPartitions Rule = "too-many-partitions"
+	// RuleMinInSync means min.insync.replicas is below the policy minimum.
+	RuleMinInSync Rule = "min-insync-below-minimum"
+	// RuleMinInSyncExceedsRF means min.insync.replicas is larger than the
+	// replication factor, so acks=all writes can never succeed.
+	RuleMinInSyncExceedsRF Rule = "min-insync-exceeds-rf"
+	// RuleNoSpareISR means min.insync.replicas equals the replication
+	// factor, so losing any one broker blocks acks=all writes (Edge Case 4).
+	RuleNoSpareISR Rule = "no-spare-isr"
+	// RuleRackLoss means losing a single rack would leave partitions below
+	// min.insync.replicas with the layout the topic would be created with.
+	RuleRackLoss Rule = "rack-loss-blocks-writes"
+	// RuleUnplaceable means the planner cannot lay the topic out, or its
+	// layout cannot be checked, so it cannot be shown to survive a rack
+	// loss.
+	RuleUnplaceable Rule = "unplaceable"
+)
+
+// Reason is one failed check.
+type Reason struct {
+	Rule    Rule   `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (r Reason) String() string {
+	return fmt.Sprintf("%s: %s", r.Rule, r.Message)
+}
+
+// Rejection is the error returned for a topic that violates a policy.
+type Rejection struct {
+	Topic   string
+	Reasons []Reason
+}
+
+func (r *Rejection) Error() string {
+	msgs := make([]string, len(r.Reasons))
+	for i, reason := range r.Reasons {
+		msgs[i] = reason.Message
+	}
+	return fmt.Sprintf("topic %q rejected: %s", r.Topic, strings.Join(msgs, "; "))
+}
+
+// Policy is a set of constraints on new topics. The zero value enforces
+// the rack checks with no size limits; the Allow fields relax them.
+type Policy struct {
+	// MinReplicationFactor and MinInSync set lower bounds on the
+	// replication factor and min.insync.replicas; 0 means no bound.
+	MinReplicationFactor int `json:"min_replication_factor,omitempty"`
+	MinInSync            int `json:"min_insync_replicas,omitempty"`
+	// MaxPartitions caps the partition count; 0 means no cap.
+	MaxPartitions int `json:"max_partitions,omitempty"`
+
+	// AllowRFAboveRacks accepts topics whose replicas must share racks.
+	AllowRFAboveRacks bool `json:"allow_rf_above_racks,omitempty"`
+	// AllowNoSpareISR accepts min.insync.replicas equal to the replication
+	// factor.
+	AllowNoSpareISR bool `json:"allow_no_spare_isr,omitempty"`
+	// AllowRackLoss accepts topics that would stop taking acks=all writes
+	// when a rack fails.
+	AllowRackLoss bool `json:"allow_rack_loss,omitempty"`
+
+	// Hierarchy is how rack names nest, if they do. Check plans the topic
+	// over it the way rackctl assign -rack-delimiter or -rack-pattern
+	// would.
+	Hierarchy *topology.Hierarchy `json:"-"`
+}
+
+// Validate returns a *Rejection listing every reason t violates the policy
+// on cluster c, or nil if it may be created.
+func (p Policy) Validate(c *topology.Cluster, t Topic) error {
+	return rejection(t, p.Check(c, t))
+}
+
+// ValidateAssignment is Validate for a topic that will be created with the
+// replica assignment a.
+func (p Policy) ValidateAssignment(c *topology.Cluster, t Topic, a planner.Assignment) error {
+	return rejection(t, p.CheckAssignment(c, t, a))
+}
+
+func rejection(t Topic, reasons []Reason) error {
+	if len(reasons) > 0 {
+		return &Rejection{Topic: t.Name, Reasons: reasons}
+	}
+	return nil
+}
+
+// Check returns every reason t violates the policy on cluster c. Rack loss
+// is judged on the layout the planner would choose.
+func (p Policy) Check(c *topology.Cluster, t Topic) []Reason {
+	return p.check(c, t, func() (planner.Assignment, error) { return p.plan(c, t) })
+}
+
+// CheckAssignment returns every reason t violates the policy on cluster c
+// when it is created with the replica assignment a, such as one planned by
+// rackctl assign or a spec. Rack loss is judged on a itself.
+func (p Policy) CheckAssignment(c *topology.Cluster, t Topic, a planner.Assignment) []Reason {
+	return p.check(c, t, func() (planner.Assignment, error) { return a, nil })
+}
+
+// check runs every rule. The assignment is only needed, and so only
+// planned, for the rack loss check.
+func (p Policy) check(c *topology.Cluster, t Topic, assignment func() (planner.Assignment, error)) []Reason {
+	var reasons []Reason
+	reject := func(rule Rule, format string, args ...any) {
+		reasons = append(reasons, Reason{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if t.Name == "" {
+		reject(RuleInvalid, "topic name is empty")
+	}
+	if t.Partitions <= 0 {
+		reject(RuleInvalid, "partition count must be positive, got %d", t.Partitions)
+	}
+	if t.ReplicationFactor <= 0 {
+		reject(RuleInvalid, "replication factor must be positive, got %d", t.ReplicationFactor)
+	}
+	minInSync, err := minInSyncOf(t)
+	if err != nil {
+		reject(RuleInvalid, "%v", err)
+	}
+	if len(reasons) > 0 {
+		return reasons
+	}
+
+	if p.MaxPartitions > 0 && t.Partitions > p.MaxPartitions {
+		reject(RuleMaxPartitions, "%d partition(s) exceed the maximum of %d", t.Partitions, p.MaxPartitions)
+	}
+	if t.ReplicationFactor < p.MinReplicationFactor {
+		reject(RuleMinReplicationFactor, "replication factor %d is below the minimum of %d", t.ReplicationFactor, p.MinReplicationFactor)
+	}
+	if minInSync < p.MinInSync {
+		reject(RuleMinInSync, "%s %d is below the minimum of %d", failure.MinInSyncConfig, minInSync, p.MinInSync)
+	}
+
+	brokers, racks := len(c.Brokers), len(c.RackNames())
+	fits := true
+	if t.ReplicationFactor > brokers {
+		reject(RuleRFExceedsBrokers, "replication factor %d exceeds %d broker(s)", t.ReplicationFactor, brokers)
+		fits = false
+	} else if racks > 0 && t.ReplicationFactor > racks && !p.AllowRFAboveRacks {
+		reject(RuleRFExceedsRacks, "replication factor %d exceeds %d rack(s), so replicas would share racks", t.ReplicationFactor, racks)
+	}
+
+	switch {
+	case minInSync > t.ReplicationFactor:
+		reject(RuleMinInSyncExceedsRF, "%s %d exceeds replication factor %d, so acks=all writes can never succeed",
+			failure.MinInSyncConfig, minInSync, t.ReplicationFactor)
+	case minInSync == t.ReplicationFactor && !p.AllowNoSpareISR:
+		reject(RuleNoSpareISR, "%s %d equals replication factor %d, so losing any broker blocks acks=all writes",
+			failure.MinInSyncConfig, minInSync, t.ReplicationFactor)
+	case fits && racks > 1 && !p.AllowRackLoss:
+		a, err := assignment()
+		if err != nil {
+			reject(RuleUnplaceable, "cannot plan the topic: %v", err)
+		} else if reason, ok := rackLoss(c, t, a); ok {
+			reasons = append(reasons, reason)
+		}
+	}
+	return reasons
+}
+
+// plan lays the topic out the way rackctl assign would.
+func (p Policy) plan(c *topology.Cluster, t Topic) (planner.Assignment, error) {
+	opts := []planner.Option{planner.WithLoad(planner.LoadOf(c))}
+	if p.Hierarchy != nil {
+		opts = append(opts, planner.WithHierarchy(p.Hierarchy))
+	}
+	pl, err := planner.New(c.Brokers, opts...)
+	if err != nil {
+		return planner.Assignment{}, err
+	}
+	return pl.Assign(planner.Request{Topic: t.Name, Partitions: t.Partitions, ReplicationFactor: t.ReplicationFactor})
+}
+
+// rackLoss reports the first rack whose loss leaves a partition of the
+// assignment below min.insync.replicas.
+func rackLoss(c *topology.Cluster, t Topic, a planner.Assignment) (Reason, bool) {
+	proposed := topology.NewCluster(c.Brokers...)
+	topic := a.AsTopic()
+	topic.Configs = t.Configs
+	proposed.AddTopic(topic)
+	tols, err := failure.Tolerances(proposed, failure.ByRack, 1)
+	if err != nil {
+		return Reason{Rule: RuleUnplaceable, Message: fmt.Sprintf("cannot check rack loss: %v", err)}, true
+	}
+	if len(tols) == 0 || tols[0].Unwritable == nil {
+		return Reason{}, false
+	}
+	set := tols[0].Unwritable
+	return Reason{
+		Rule: RuleRackLoss,
+		Message: fmt.Sprintf("losing %s would leave partition(s) %v unable to take acks=all writes",
+			set, set.Partitions),
+	}, true
+}
+
+// minInSyncOf returns the topic's min.insync.replicas, or Kafka's default
+// of 1 if it is not set.
+func minInSyncOf(t Topic) (int, error) {
+	v, ok := t.Configs[failure.MinInSyncConfig]
+	if !ok {
+		return 1, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s %q is not a positive integer", failure.MinInSyncConfig, v)
+	}
+	return n, nil
+}
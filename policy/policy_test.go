@@ -0,0 +1,139 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func threeRacks() *topology.Cluster {
+	return topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+		topology.Broker{ID: 4, Rack: "rack-a"},
+	)
+}
+
+func minISR(n string) map[string]string {
+	return map[string]string{"min.insync.replicas": n}
+}
+
+func rules(reasons []Reason) []Rule {
+	var out []Rule
+	for _, r := range reasons {
+		out = append(out, r.Rule)
+	}
+	return out
+}
+
+func TestValidateAccepts(t *testing.T) {
+	err := Policy{MinReplicationFactor: 3, MinInSync: 2}.Validate(threeRacks(),
+		Topic{Name: "orders", Partitions: 6, ReplicationFactor: 3, Configs: minISR("2")})
+	assert.NoError(t, err)
+}
+
+func TestValidateRejects(t *testing.T) {
+	c := threeRacks()
+	tests := []struct {
+		name   string
+		policy Policy
+		topic  Topic
+		want   []Rule
+	}{
+		{"malformed", Policy{}, Topic{Partitions: 0, ReplicationFactor: 3, Configs: minISR("two")},
+			[]Rule{RuleInvalid, RuleInvalid, RuleInvalid}},
+		{"edge case 7", Policy{}, Topic{Name: "t", Partitions: 1, ReplicationFactor: 5},
+			[]Rule{RuleRFExceedsBrokers}},
+		{"shared racks", Policy{}, Topic{Name: "t", Partitions: 1, ReplicationFactor: 4},
+			[]Rule{RuleRFExceedsRacks}},
+		{"edge case 4", Policy{}, Topic{Name: "t", Partitions: 1, ReplicationFactor: 3, Configs: minISR("3")},
+			[]Rule{RuleNoSpareISR}},
+		{"never writable", Policy{AllowNoSpareISR: true}, Topic{Name: "t", Partitions: 1, ReplicationFactor: 2, Configs: minISR("3")},
+			[]Rule{RuleMinInSyncExceedsRF}},
+		{"limits", Policy{MinReplicationFactor: 3, MinInSync: 2, MaxPartitions: 10}, Topic{Name: "t", Partitions: 12, ReplicationFactor: 2},
+			[]Rule{RuleMaxPartitions, RuleMinReplicationFactor, RuleMinInSync}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, rules(tt.policy.Check(c, tt.topic)))
+		})
+	}
+}
+
+func TestValidateRackLoss(t *testing.T) {
+	// Two racks: RF 3 puts two replicas in one rack, which min ISR 2 cannot
+	// lose.
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-a"},
+	)
+	topic := Topic{Name: "orders", Partitions: 2, ReplicationFactor: 3, Configs: minISR("2")}
+	p := Policy{AllowRFAboveRacks: true}
+
+	err := p.Validate(c, topic)
+	var rejection *Rejection
+	require.True(t, errors.As(err, &rejection))
+	require.Len(t, rejection.Reasons, 1)
+	assert.Equal(t, RuleRackLoss, rejection.Reasons[0].Rule)
+	assert.Equal(t, `topic "orders" rejected: losing rack-a would leave partition(s) [0 1] unable to take acks=all writes`, err.Error())
+
+	p.AllowRackLoss = true
+	assert.NoError(t, p.Validate(c, topic))
+}
+
+func TestValidateRackLossWithHierarchy(t *testing.T) {
+	// Zone z1 has three racks and z2 one. Flat, RF 4 puts a replica in each
+	// rack; spread over zones first, z2 gets two replicas in its only rack,
+	// which min ISR 3 cannot lose.
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "z1-a"},
+		topology.Broker{ID: 2, Rack: "z1-b"},
+		topology.Broker{ID: 3, Rack: "z1-c"},
+		topology.Broker{ID: 4, Rack: "z2-a"},
+		topology.Broker{ID: 5, Rack: "z2-a"},
+	)
+	topic := Topic{Name: "orders", Partitions: 2, ReplicationFactor: 4, Configs: minISR("3")}
+	assert.NoError(t, Policy{}.Validate(c, topic))
+
+	h, err := topology.SplitHierarchy("-", "zone", "rack")
+	require.NoError(t, err)
+	err = Policy{Hierarchy: h}.Validate(c, topic)
+	var rejection *Rejection
+	require.True(t, errors.As(err, &rejection))
+	assert.Equal(t, []Rule{RuleRackLoss}, rules(rejection.Reasons))
+	assert.Contains(t, err.Error(), "losing z2-a")
+}
+
+func TestValidateAssignment(t *testing.T) {
+	// The planner would use all three racks, but this assignment keeps
+	// two replicas of every partition in rack-a, as placement.racks
+	// [rack-a, rack-b] would.
+	c := threeRacks()
+	topic := Topic{Name: "orders", Partitions: 2, ReplicationFactor: 3, Configs: minISR("2")}
+	a := planner.Assignment{Topic: "orders", Replicas: [][]int32{{1, 2, 4}, {2, 4, 1}}}
+	assert.NoError(t, Policy{}.Validate(c, topic))
+
+	err := Policy{}.ValidateAssignment(c, topic, a)
+	var rejection *Rejection
+	require.True(t, errors.As(err, &rejection))
+	assert.Equal(t, []Rule{RuleRackLoss}, rules(rejection.Reasons))
+	assert.Contains(t, err.Error(), "losing rack-a")
+}
+
+func TestValidateRejectsUnplaceable(t *testing.T) {
+	// No rack of the cluster fits the hierarchy, so the planner cannot lay
+	// the topic out and it cannot be shown to survive a rack loss.
+	h, err := topology.RegexpHierarchy(`^(?P<zone>z\d)-(?P<rack>.+)$`)
+	require.NoError(t, err)
+	reasons := Policy{Hierarchy: h}.Check(threeRacks(), Topic{Name: "orders", Partitions: 1, ReplicationFactor: 3, Configs: minISR("2")})
+	require.Len(t, reasons, 1)
+	assert.Equal(t, RuleUnplaceable, reasons[0].Rule)
+	assert.Contains(t, reasons[0].Message, "cannot plan the topic")
+}
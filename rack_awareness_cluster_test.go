@@ -0,0 +1,100 @@
+package kafka_rack_awareness
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-rack-awareness/kafkatest"
+	"kafka-rack-awareness/readiness"
+	"kafka-rack-awareness/snapshot"
+	"kafka-rack-awareness/source"
+	"kafka-rack-awareness/topology"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+)
+
+// testCluster is the cluster a test runs against.
+type testCluster struct {
+	Brokers []string
+	// Racks is the rack of every broker, keyed by broker ID.
+	Racks map[int32]string
+	// InMemory is set for the in-memory cluster.
+	InMemory bool
+}
+
+// startCluster returns a cluster with one broker per rack. By default the
+// cluster is in memory and lives for the duration of the test. Setting
+// KAFKA_BROKERS to a comma-separated seed list runs the test against a real
+// cluster instead, such as the one in docker-compose.yml, whose broker IDs
+// start at 1 and follow the order of racks. Either way, brokers serve
+// consumers that set a rack from a replica in that rack.
+func startCluster(t *testing.T, racks ...string) testCluster {
+	t.Helper()
+	if seeds := os.Getenv("KAFKA_BROKERS"); seeds != "" {
+		env := testCluster{Brokers: strings.Split(seeds, ","), Racks: make(map[int32]string)}
+		for i, rack := range racks {
+			env.Racks[int32(i+1)] = rack
+		}
+		return env
+	}
+	c := kafkatest.Start(t, racks...)
+	c.EnableFollowerFetching()
+	return testCluster{Brokers: c.Addrs(), Racks: c.Racks(), InMemory: true}
+}
+
+// skipLeaderPlacement skips the rest of a test of how brokers spread the
+// leaders of a new topic over racks when it runs in memory: kfake leads each
+// new partition from a random broker, where Kafka rotates leadership over
+// the brokers.
+func skipLeaderPlacement(t *testing.T, env testCluster) {
+	t.Helper()
+	if env.InMemory {
+		t.Skip("the in-memory cluster places leaders at random; set KAFKA_BROKERS to check Kafka's placement")
+	}
+}
+
+// startThreeRackCluster starts the cluster most tests use: one broker in
+// each of rack-a, rack-b and rack-c.
+func startThreeRackCluster(t *testing.T) testCluster {
+	t.Helper()
+	return startCluster(t, "rack-a", "rack-b", "rack-c")
+}
+
+// waitForTopic waits until every partition of topic has a leader, a full ISR
+// and its replicas spread across racks.
+func waitForTopic(t *testing.T, src topology.MetadataSource, topic string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	require.NoError(t, readiness.WaitForTopicReady(ctx, src, []string{topic}))
+}
+
+// kafkaGoSource reads metadata from the cluster with kafka-go, for tests
+// that should not depend on franz-go.
+func kafkaGoSource(env testCluster) topology.MetadataSource {
+	return source.NewKafkaGo(&kafka.Client{Addr: kafka.TCP(env.Brokers...)})
+}
+
+// saveSnapshot writes cluster to a snapshot file named after the test, with
+// subtest separators flattened so the file lands directly in the dir, and
+// checks that it reads back unchanged. Setting KAFKA_SNAPSHOT_DIR keeps the
+// files, for comparing runs with rackctl diff; otherwise they are removed
+// with the test.
+func saveSnapshot(t *testing.T, cluster *topology.Cluster) {
+	t.Helper()
+	dir := os.Getenv("KAFKA_SNAPSHOT_DIR")
+	if dir == "" {
+		dir = t.TempDir()
+	}
+	path := filepath.Join(dir, strings.ReplaceAll(t.Name(), "/", "_")+".json")
+	require.NoError(t, snapshot.WriteFile(path, &snapshot.Snapshot{TakenAt: time.Now(), Cluster: cluster}))
+	saved, err := snapshot.ReadFile(path)
+	require.NoError(t, err)
+	require.Zero(t, snapshot.Diff(cluster, saved.Cluster).Len(), "snapshot should read back unchanged")
+	t.Logf("Saved snapshot to %s", path)
+}
@@ -3,10 +3,13 @@ package kafka_rack_awareness
 import (
 	"context"
 	"fmt"
-	"sort"
 	"testing"
 	"time"
 
+	"kafka-rack-awareness/audit"
+	"kafka-rack-awareness/fetchsource"
+	"kafka-rack-awareness/source"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/twmb/franz-go/pkg/kadm"
@@ -18,9 +21,9 @@ const (
 )
 
 // Helper function to create franz-go admin client
-func createFranzAdminClient(t *testing.T) *kadm.Client {
+func createFranzAdminClient(t *testing.T, seeds []string) *kadm.Client {
 	client, err := kgo.NewClient(
-		kgo.SeedBrokers(brokers...),
+		kgo.SeedBrokers(seeds...),
 		kgo.RequestTimeoutOverhead(10*time.Second),
 	)
 	require.NoError(t, err, "Failed to create franz-go client")
@@ -29,10 +32,19 @@ func createFranzAdminClient(t *testing.T) *kadm.Client {
 	return adminClient
 }
 
+// Helper function to create a franz-go metadata source. Its client is
+// closed when the test ends.
+func createFranzSource(t *testing.T, seeds []string) *source.Franz {
+	client, err := kgo.NewClient(kgo.SeedBrokers(seeds...))
+	require.NoError(t, err, "Failed to create franz-go client")
+	t.Cleanup(client.Close)
+	return source.NewFranz(client)
+}
+
 // Helper function to create franz-go producer client
-func createFranzProducer(t *testing.T, opts ...kgo.Opt) *kgo.Client {
+func createFranzProducer(t *testing.T, seeds []string, opts ...kgo.Opt) *kgo.Client {
 	defaultOpts := []kgo.Opt{
-		kgo.SeedBrokers(brokers...),
+		kgo.SeedBrokers(seeds...),
 		kgo.ProducerBatchMaxBytes(1000000),
 		kgo.RequestTimeoutOverhead(10 * time.Second),
 	}
@@ -45,9 +57,9 @@ func createFranzProducer(t *testing.T, opts ...kgo.Opt) *kgo.Client {
 }
 
 // Helper function to create franz-go consumer client
-func createFranzConsumer(t *testing.T, groupID string, topics []string, opts ...kgo.Opt) *kgo.Client {
+func createFranzConsumer(t *testing.T, seeds []string, groupID string, topics []string, opts ...kgo.Opt) *kgo.Client {
 	defaultOpts := []kgo.Opt{
-		kgo.SeedBrokers(brokers...),
+		kgo.SeedBrokers(seeds...),
 		kgo.ConsumerGroup(groupID),
 		kgo.ConsumeTopics(topics...),
 		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
@@ -61,19 +73,32 @@ func createFranzConsumer(t *testing.T, groupID string, topics []string, opts ...
 	return client
 }
 
-// Helper function to extract rack list from map
-func getRackListFromMap(racks map[string]bool) []string {
-	rackList := make([]string, 0, len(racks))
-	for rack := range racks {
-		rackList = append(rackList, rack)
-	}
-	sort.Strings(rackList)
-	return rackList
+// waitForGroupMembers waits until the group is stable with n members and
+// every member has been assigned partitions.
+func waitForGroupMembers(t *testing.T, admin *kadm.Client, group string, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		described, err := admin.DescribeGroups(context.Background(), group)
+		if err != nil {
+			return false
+		}
+		g := described[group]
+		if g.Err != nil || g.State != "Stable" || len(g.Members) != n {
+			return false
+		}
+		for _, m := range g.Members {
+			if assigned, ok := m.Assigned.AsConsumer(); !ok || len(assigned.Topics) == 0 {
+				return false
+			}
+		}
+		return true
+	}, franzTimeout, 50*time.Millisecond, "group %s never settled with %d member(s)", group, n)
 }
 
 // Test 1: Verify broker metadata and rack configuration
 func TestFranz_BrokerMetadata(t *testing.T) {
-	adminClient := createFranzAdminClient(t)
+	env := startThreeRackCluster(t)
+	adminClient := createFranzAdminClient(t, env.Brokers)
 	defer adminClient.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), franzTimeout)
@@ -90,11 +115,7 @@ func TestFranz_BrokerMetadata(t *testing.T) {
 	require.Equal(t, 3, len(brokers), "Expected 3 brokers")
 
 	// Check each broker and its rack configuration
-	expectedRacks := map[int32]string{
-		1: "rack-a",
-		2: "rack-b",
-		3: "rack-c",
-	}
+	expectedRacks := env.Racks
 
 	for _, broker := range brokers {
 		brokerID := broker.NodeID
@@ -122,7 +143,8 @@ func TestFranz_BrokerMetadata(t *testing.T) {
 
 // Test 2: Create topic and verify replica distribution
 func TestFranz_ReplicaDistribution(t *testing.T) {
-	adminClient := createFranzAdminClient(t)
+	env := startThreeRackCluster(t)
+	adminClient := createFranzAdminClient(t, env.Brokers)
 	defer adminClient.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), franzTimeout)
@@ -138,42 +160,31 @@ func TestFranz_ReplicaDistribution(t *testing.T) {
 	t.Logf("Created topic: %s", topicName)
 
 	// Wait for topic to be ready
-	time.Sleep(3 * time.Second)
+	waitForTopic(t, createFranzSource(t, env.Brokers), topicName)
 
 	// Get topic metadata
 	metadata, err := adminClient.Metadata(ctx, topicName)
 	require.NoError(t, err, "Failed to get topic metadata")
 
-	// Get broker rack mapping
-	brokerRacks := make(map[int32]string)
-	for _, broker := range metadata.Brokers {
-		if broker.Rack != nil {
-			brokerRacks[broker.NodeID] = *broker.Rack
-		}
-	}
+	cluster := source.FromFranz(metadata)
+	topic, ok := cluster.Topic(topicName)
+	require.True(t, ok, "Topic should be in cluster metadata")
 
 	// Check replica distribution for each partition
-	topicMetadata := metadata.Topics[topicName]
-	for _, partition := range topicMetadata.Partitions {
-		racks := make(map[string]bool)
-		replicaIDs := []int32{}
-
-		for _, replica := range partition.Replicas {
-			replicaIDs = append(replicaIDs, replica)
-			if rack, exists := brokerRacks[replica]; exists {
-				racks[rack] = true
-			}
-		}
-
-		rackList := getRackListFromMap(racks)
+	for _, partition := range topic.Partitions {
 		t.Logf("Partition %d: Leader=%d, Replicas=%v, Racks=%v",
-			partition.Partition, partition.Leader, replicaIDs, rackList)
+			partition.ID, partition.Leader, partition.Replicas, cluster.RacksForPartition(partition))
 
 		// With RF=3 and 3 racks, all replicas should be in different racks
-		assert.Equal(t, 3, len(racks),
-			"Partition %d should have replicas in 3 different racks", partition.Partition)
+		assert.Equal(t, 3, cluster.RackSpread(partition),
+			"Partition %d should have replicas in 3 different racks", partition.ID)
 	}
 
+	// The auditor should agree with the per-partition checks above
+	report := audit.New(audit.WithTopics(topicName)).Audit(cluster)
+	assert.False(t, report.HasViolations(audit.SeverityCritical),
+		"Audit should report no critical violations: %v", report.Violations)
+
 	// Cleanup
 	_, err = adminClient.DeleteTopics(ctx, topicName)
 	if err != nil {
@@ -185,7 +196,8 @@ func TestFranz_ReplicaDistribution(t *testing.T) {
 
 // Test 3: Producer with rack awareness
 func TestFranz_ProducerWithRackAwareness(t *testing.T) {
-	adminClient := createFranzAdminClient(t)
+	env := startThreeRackCluster(t)
+	adminClient := createFranzAdminClient(t, env.Brokers)
 	defer adminClient.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), franzTimeout)
@@ -198,10 +210,10 @@ func TestFranz_ProducerWithRackAwareness(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, resp[topicName].Err)
 
-	time.Sleep(2 * time.Second)
+	waitForTopic(t, createFranzSource(t, env.Brokers), topicName)
 
 	// Create producer with rack awareness
-	producer := createFranzProducer(t,
+	producer := createFranzProducer(t, env.Brokers,
 		kgo.ClientID("franz-producer-rack-a"),
 		kgo.Rack("rack-a"), // Enable rack awareness
 		kgo.RequiredAcks(kgo.AllISRAcks()),
@@ -240,7 +252,8 @@ func TestFranz_ProducerWithRackAwareness(t *testing.T) {
 
 // Test 4: Consumer with rack awareness
 func TestFranz_ConsumerWithRackAwareness(t *testing.T) {
-	adminClient := createFranzAdminClient(t)
+	env := startThreeRackCluster(t)
+	adminClient := createFranzAdminClient(t, env.Brokers)
 	defer adminClient.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), franzTimeout)
@@ -253,10 +266,10 @@ func TestFranz_ConsumerWithRackAwareness(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, resp[topicName].Err)
 
-	time.Sleep(2 * time.Second)
+	waitForTopic(t, createFranzSource(t, env.Brokers), topicName)
 
 	// Produce some messages first
-	producer := createFranzProducer(t)
+	producer := createFranzProducer(t, env.Brokers)
 	defer producer.Close()
 
 	messageCount := 10
@@ -272,11 +285,14 @@ func TestFranz_ConsumerWithRackAwareness(t *testing.T) {
 
 	producer.Flush(ctx)
 
-	// Create consumer with rack awareness
-	consumer := createFranzConsumer(t,
+	// Create consumer with rack awareness, recording which brokers serve
+	// its fetches
+	fetched := fetchsource.New()
+	consumer := createFranzConsumer(t, env.Brokers,
 		"franz-test-group",
 		[]string{topicName},
 		kgo.Rack("rack-a"), // Prefer to fetch from rack-a
+		kgo.WithHooks(fetched),
 	)
 	defer consumer.Close()
 
@@ -305,6 +321,11 @@ func TestFranz_ConsumerWithRackAwareness(t *testing.T) {
 		}
 	}
 
+	// Every partition has a replica in rack-a, so the rack-aware replica
+	// selector should have served nearly all bytes from there
+	t.Logf("Fetched bytes by rack: %v", fetched.Racks())
+	assert.NoError(t, fetched.CheckRack("rack-a", 0.9))
+
 	// Cleanup
 	_, err = adminClient.DeleteTopics(ctx, topicName)
 	if err != nil {
@@ -316,7 +337,8 @@ func TestFranz_ConsumerWithRackAwareness(t *testing.T) {
 
 // Test 5: Verify partition distribution
 func TestFranz_PartitionDistribution(t *testing.T) {
-	adminClient := createFranzAdminClient(t)
+	env := startThreeRackCluster(t)
+	adminClient := createFranzAdminClient(t, env.Brokers)
 	defer adminClient.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), franzTimeout)
@@ -329,33 +351,21 @@ func TestFranz_PartitionDistribution(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, resp[topicName].Err)
 
-	time.Sleep(2 * time.Second)
+	waitForTopic(t, createFranzSource(t, env.Brokers), topicName)
 
 	// Get metadata
 	metadata, err := adminClient.Metadata(ctx, topicName)
 	require.NoError(t, err)
 
-	topicMeta := metadata.Topics[topicName]
+	cluster := source.FromFranz(metadata)
+	topic, ok := cluster.Topic(topicName)
+	require.True(t, ok, "Topic should be in cluster metadata")
 
 	// Count leaders per rack
-	leaderRacks := make(map[string]int)
-
-	// Build broker map for lookup
-	brokerMap := make(map[int32]kgo.BrokerMetadata)
-	for _, broker := range metadata.Brokers {
-		brokerMap[broker.NodeID] = broker
-	}
-
-	for _, partition := range topicMeta.Partitions {
-		leaderID := partition.Leader
-		if broker, ok := brokerMap[leaderID]; ok {
-			if broker.Rack != nil {
-				leaderRacks[*broker.Rack]++
-			}
-		}
-	}
+	leaderRacks := cluster.LeadersByRack(topic.Partitions)
 
 	t.Logf("Leader distribution: %v", leaderRacks)
+	skipLeaderPlacement(t, env)
 
 	// With 9 partitions and 3 racks, each rack should have 3 leaders
 	for rack, count := range leaderRacks {
@@ -373,7 +383,8 @@ func TestFranz_PartitionDistribution(t *testing.T) {
 
 // Test 6: Transactional producer with rack awareness
 func TestFranz_TransactionalProducer(t *testing.T) {
-	adminClient := createFranzAdminClient(t)
+	env := startThreeRackCluster(t)
+	adminClient := createFranzAdminClient(t, env.Brokers)
 	defer adminClient.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), franzTimeout)
@@ -386,10 +397,10 @@ func TestFranz_TransactionalProducer(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, resp[topicName].Err)
 
-	time.Sleep(2 * time.Second)
+	waitForTopic(t, createFranzSource(t, env.Brokers), topicName)
 
 	// Create transactional producer
-	producer := createFranzProducer(t,
+	producer := createFranzProducer(t, env.Brokers,
 		kgo.TransactionalID("franz-txn-producer"),
 		kgo.Rack("rack-a"),
 	)
@@ -428,7 +439,8 @@ func TestFranz_TransactionalProducer(t *testing.T) {
 
 // Test 7: Idempotent producer
 func TestFranz_IdempotentProducer(t *testing.T) {
-	adminClient := createFranzAdminClient(t)
+	env := startThreeRackCluster(t)
+	adminClient := createFranzAdminClient(t, env.Brokers)
 	defer adminClient.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), franzTimeout)
@@ -441,11 +453,11 @@ func TestFranz_IdempotentProducer(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, resp[topicName].Err)
 
-	time.Sleep(2 * time.Second)
+	waitForTopic(t, createFranzSource(t, env.Brokers), topicName)
 
 	// Create producer (idempotence is enabled by default in franz-go)
 	// Note: franz-go enables idempotence by default, no need to configure
-	producer := createFranzProducer(t)
+	producer := createFranzProducer(t, env.Brokers)
 	defer producer.Close()
 
 	// Produce messages
@@ -471,7 +483,8 @@ func TestFranz_IdempotentProducer(t *testing.T) {
 
 // Test 8: Consumer rebalance with rack awareness
 func TestFranz_ConsumerRebalance(t *testing.T) {
-	adminClient := createFranzAdminClient(t)
+	env := startThreeRackCluster(t)
+	adminClient := createFranzAdminClient(t, env.Brokers)
 	defer adminClient.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), franzTimeout)
@@ -485,21 +498,21 @@ func TestFranz_ConsumerRebalance(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, resp[topicName].Err)
 
-	time.Sleep(2 * time.Second)
+	waitForTopic(t, createFranzSource(t, env.Brokers), topicName)
 
 	// Create first consumer
-	consumer1 := createFranzConsumer(t, groupID, []string{topicName})
+	consumer1 := createFranzConsumer(t, env.Brokers, groupID, []string{topicName})
 	defer consumer1.Close()
 
 	// Wait for initial assignment
-	time.Sleep(2 * time.Second)
+	waitForGroupMembers(t, adminClient, groupID, 1)
 
 	// Create second consumer to trigger rebalance
-	consumer2 := createFranzConsumer(t, groupID, []string{topicName})
+	consumer2 := createFranzConsumer(t, env.Brokers, groupID, []string{topicName})
 	defer consumer2.Close()
 
 	// Wait for rebalance
-	time.Sleep(3 * time.Second)
+	waitForGroupMembers(t, adminClient, groupID, 2)
 
 	t.Log("✓ Consumer rebalance completed successfully")
 
@@ -512,7 +525,8 @@ func TestFranz_ConsumerRebalance(t *testing.T) {
 
 // Test 9: Verify ISR (In-Sync Replicas) includes all racks
 func TestFranz_ISRVerification(t *testing.T) {
-	adminClient := createFranzAdminClient(t)
+	env := startThreeRackCluster(t)
+	adminClient := createFranzAdminClient(t, env.Brokers)
 	defer adminClient.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), franzTimeout)
@@ -525,25 +539,23 @@ func TestFranz_ISRVerification(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, resp[topicName].Err)
 
-	time.Sleep(2 * time.Second)
+	waitForTopic(t, createFranzSource(t, env.Brokers), topicName)
 
 	// Get metadata
 	metadata, err := adminClient.Metadata(ctx, topicName)
 	require.NoError(t, err)
 
-	topicMeta := metadata.Topics[topicName]
+	cluster := source.FromFranz(metadata)
+	topic, ok := cluster.Topic(topicName)
+	require.True(t, ok, "Topic should be in cluster metadata")
 
-	// Get broker rack mapping
-	brokerRacks := make(map[int32]string)
-	for _, broker := range metadata.Brokers {
-		if broker.Rack != nil {
-			brokerRacks[broker.NodeID] = *broker.Rack
-		}
-	}
+	// Keep the ISR and topic configs as evidence
+	require.NoError(t, source.TopicConfigs(ctx, adminClient, cluster))
+	saveSnapshot(t, cluster)
 
 	// Check ISR for each partition
-	for _, partition := range topicMeta.Partitions {
-		partitionID := partition.Partition
+	for _, partition := range topic.Partitions {
+		partitionID := partition.ID
 		t.Logf("Partition %d: ISR=%v (size=%d)", partitionID, partition.ISR, len(partition.ISR))
 
 		// Verify ISR size (should be 3 with min.insync.replicas=2)
@@ -551,15 +563,8 @@ func TestFranz_ISRVerification(t *testing.T) {
 			"Partition %d ISR should have at least 2 replicas", partitionID)
 
 		// Get racks in ISR
-		isrRacks := make(map[string]bool)
-		for _, replicaID := range partition.ISR {
-			if rack, exists := brokerRacks[replicaID]; exists {
-				isrRacks[rack] = true
-			}
-		}
-
-		rackList := getRackListFromMap(isrRacks)
-		t.Logf("Partition %d ISR racks: %v", partitionID, rackList)
+		isrRacks := cluster.RacksForISR(partition)
+		t.Logf("Partition %d ISR racks: %v", partitionID, isrRacks)
 
 		// ISR should span multiple racks for fault tolerance
 		assert.GreaterOrEqual(t, len(isrRacks), 2,
@@ -577,7 +582,8 @@ func TestFranz_ISRVerification(t *testing.T) {
 
 // Test 10: High partition count with rack awareness
 func TestFranz_HighPartitionCount(t *testing.T) {
-	adminClient := createFranzAdminClient(t)
+	env := startThreeRackCluster(t)
+	adminClient := createFranzAdminClient(t, env.Brokers)
 	defer adminClient.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), franzTimeout)
@@ -592,40 +598,24 @@ func TestFranz_HighPartitionCount(t *testing.T) {
 
 	t.Logf("Created topic: %s with 30 partitions", topicName)
 
-	time.Sleep(3 * time.Second)
+	waitForTopic(t, createFranzSource(t, env.Brokers), topicName)
 
 	// Get metadata
 	metadata, err := adminClient.Metadata(ctx, topicName)
 	require.NoError(t, err)
 
-	topicMeta := metadata.Topics[topicName]
-
-	// Get broker rack mapping
-	brokerRacks := make(map[int32]string)
-	for _, broker := range metadata.Brokers {
-		if broker.Rack != nil {
-			brokerRacks[broker.NodeID] = *broker.Rack
-		}
-	}
+	cluster := source.FromFranz(metadata)
+	topic, ok := cluster.Topic(topicName)
+	require.True(t, ok, "Topic should be in cluster metadata")
 
 	// Track rack distribution
 	partitionsWithAllRacks := 0
-	rackDistribution := make(map[string]int)
-
-	for _, partition := range topicMeta.Partitions {
-		racks := make(map[string]bool)
-
-		for _, replica := range partition.Replicas {
-			if rack, exists := brokerRacks[replica]; exists {
-				racks[rack] = true
-				rackDistribution[rack]++
-			}
-		}
-
-		if len(racks) == 3 {
+	for _, partition := range topic.Partitions {
+		if cluster.RackSpread(partition) == 3 {
 			partitionsWithAllRacks++
 		}
 	}
+	rackDistribution := cluster.ReplicasByRack(topic.Partitions)
 
 	t.Logf("Partitions with all racks: %d/30", partitionsWithAllRacks)
 	t.Logf("Overall rack distribution: %v", rackDistribution)
@@ -3,26 +3,21 @@ package kafka_rack_awareness
 import (
 	"context"
 	"fmt"
-	"sort"
 	"testing"
 	"time"
 
+	"kafka-rack-awareness/audit"
+	"kafka-rack-awareness/source"
+
 	"github.com/segmentio/kafka-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-const (
-	broker1 = "localhost:9092"
-	broker2 = "localhost:9093"
-	broker3 = "localhost:9094"
-)
-
-var brokers = []string{broker1, broker2, broker3}
-
 // Test 1: Verify brokers are accessible
 func TestPureGo_BrokersAccessible(t *testing.T) {
-	conn, err := kafka.Dial("tcp", broker1)
+	env := startThreeRackCluster(t)
+	conn, err := kafka.Dial("tcp", env.Brokers[0])
 	require.NoError(t, err, "Should connect to broker 1")
 	defer conn.Close()
 
@@ -40,33 +35,31 @@ func TestPureGo_BrokersAccessible(t *testing.T) {
 
 // Test 2: Verify rack configuration
 func TestPureGo_RackConfiguration(t *testing.T) {
-	conn, err := kafka.Dial("tcp", broker1)
+	env := startThreeRackCluster(t)
+	conn, err := kafka.Dial("tcp", env.Brokers[0])
 	require.NoError(t, err)
 	defer conn.Close()
 
 	brokerList, err := conn.Brokers()
 	require.NoError(t, err)
 
-	rackMap := make(map[string]int)
-	for _, broker := range brokerList {
-		if broker.Rack != "" {
-			rackMap[broker.Rack]++
-		}
-	}
+	cluster := source.FromKafkaGo(brokerList, nil)
 
 	// We expect 3 racks: rack-a, rack-b, rack-c
-	assert.Equal(t, 3, len(rackMap), "Should have 3 different racks")
+	racks := cluster.Racks()
+	assert.Equal(t, 3, len(racks), "Should have 3 different racks")
 
-	for rack, count := range rackMap {
-		t.Logf("Rack '%s' has %d broker(s)", rack, count)
+	for _, rack := range racks {
+		t.Logf("Rack '%s' has %d broker(s)", rack.Name, len(rack.Brokers))
 	}
 }
 
 // Test 3: Create topic and verify replica distribution
 func TestPureGo_TopicReplicaDistribution(t *testing.T) {
+	env := startThreeRackCluster(t)
 	topicName := fmt.Sprintf("test-rack-dist-%d", time.Now().Unix())
 
-	conn, err := kafka.Dial("tcp", broker1)
+	conn, err := kafka.Dial("tcp", env.Brokers[0])
 	require.NoError(t, err)
 	defer conn.Close()
 
@@ -89,7 +82,7 @@ func TestPureGo_TopicReplicaDistribution(t *testing.T) {
 	t.Logf("Created topic: %s", topicName)
 
 	// Wait for topic to be ready
-	time.Sleep(3 * time.Second)
+	waitForTopic(t, kafkaGoSource(env), topicName)
 
 	// Get metadata
 	partitions, err := conn.ReadPartitions(topicName)
@@ -99,30 +92,26 @@ func TestPureGo_TopicReplicaDistribution(t *testing.T) {
 	brokerList, err := conn.Brokers()
 	require.NoError(t, err)
 
-	// Create broker ID to rack mapping
-	brokerRacks := make(map[int]string)
-	for _, broker := range brokerList {
-		brokerRacks[broker.ID] = broker.Rack
-	}
+	cluster := source.FromKafkaGo(brokerList, partitions)
+	topic, ok := cluster.Topic(topicName)
+	require.True(t, ok, "Topic should be in cluster metadata")
+	saveSnapshot(t, cluster)
 
 	// Check replica distribution
-	for _, partition := range partitions {
-		racks := make(map[string]bool)
-
-		for _, replica := range partition.Replicas {
-			if rack, exists := brokerRacks[replica.ID]; exists {
-				racks[rack] = true
-			}
-		}
-
+	for _, partition := range topic.Partitions {
 		t.Logf("Partition %d: Leader=%d, Replicas=%v, Racks=%v",
-			partition.ID, partition.Leader.ID, getBrokerIDs(partition.Replicas), getRacksFromBrokers(partition.Replicas, brokerRacks))
+			partition.ID, partition.Leader, partition.Replicas, cluster.RacksForPartition(partition))
 
 		// With RF=3 and 3 racks, replicas should be in different racks
-		assert.Equal(t, 3, len(racks),
+		assert.Equal(t, 3, cluster.RackSpread(partition),
 			"Partition %d should have replicas in 3 different racks", partition.ID)
 	}
 
+	// The auditor should agree with the per-partition checks above
+	report := audit.New(audit.WithTopics(topicName)).Audit(cluster)
+	assert.False(t, report.HasViolations(audit.SeverityCritical),
+		"Audit should report no critical violations: %v", report.Violations)
+
 	// Cleanup
 	err = controllerConn.DeleteTopics(topicName)
 	if err != nil {
@@ -132,10 +121,11 @@ func TestPureGo_TopicReplicaDistribution(t *testing.T) {
 
 // Test 4: Producer with messages
 func TestPureGo_ProducerMessages(t *testing.T) {
+	env := startThreeRackCluster(t)
 	topicName := fmt.Sprintf("test-producer-%d", time.Now().Unix())
 
 	// Create topic first
-	conn, err := kafka.Dial("tcp", broker1)
+	conn, err := kafka.Dial("tcp", env.Brokers[0])
 	require.NoError(t, err)
 	defer conn.Close()
 
@@ -152,11 +142,11 @@ func TestPureGo_ProducerMessages(t *testing.T) {
 		ReplicationFactor: 3,
 	})
 	require.NoError(t, err)
-	time.Sleep(2 * time.Second)
+	waitForTopic(t, kafkaGoSource(env), topicName)
 
 	// Create producer
 	writer := &kafka.Writer{
-		Addr:     kafka.TCP(brokers...),
+		Addr:     kafka.TCP(env.Brokers...),
 		Topic:    topicName,
 		Balancer: &kafka.LeastBytes{},
 	}
@@ -187,10 +177,11 @@ func TestPureGo_ProducerMessages(t *testing.T) {
 
 // Test 5: Consumer reading messages
 func TestPureGo_ConsumerMessages(t *testing.T) {
+	env := startThreeRackCluster(t)
 	topicName := fmt.Sprintf("test-consumer-%d", time.Now().Unix())
 
 	// Create topic
-	conn, err := kafka.Dial("tcp", broker1)
+	conn, err := kafka.Dial("tcp", env.Brokers[0])
 	require.NoError(t, err)
 	defer conn.Close()
 
@@ -207,11 +198,11 @@ func TestPureGo_ConsumerMessages(t *testing.T) {
 		ReplicationFactor: 3,
 	})
 	require.NoError(t, err)
-	time.Sleep(5 * time.Second) // Wait longer for topic to be ready
+	waitForTopic(t, kafkaGoSource(env), topicName)
 
 	// Produce messages
 	writer := &kafka.Writer{
-		Addr:  kafka.TCP(brokers...),
+		Addr:  kafka.TCP(env.Brokers...),
 		Topic: topicName,
 	}
 
@@ -226,7 +217,7 @@ func TestPureGo_ConsumerMessages(t *testing.T) {
 
 	// Consume messages
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: brokers,
+		Brokers: env.Brokers,
 		Topic:   topicName,
 		GroupID: fmt.Sprintf("test-group-%d", time.Now().Unix()),
 	})
@@ -265,9 +256,10 @@ consumerLoop:
 
 // Test 6: Leader distribution across racks
 func TestPureGo_LeaderDistribution(t *testing.T) {
+	env := startThreeRackCluster(t)
 	topicName := fmt.Sprintf("test-leaders-%d", time.Now().Unix())
 
-	conn, err := kafka.Dial("tcp", broker1)
+	conn, err := kafka.Dial("tcp", env.Brokers[0])
 	require.NoError(t, err)
 	defer conn.Close()
 
@@ -285,7 +277,7 @@ func TestPureGo_LeaderDistribution(t *testing.T) {
 		ReplicationFactor: 3,
 	})
 	require.NoError(t, err)
-	time.Sleep(3 * time.Second)
+	waitForTopic(t, kafkaGoSource(env), topicName)
 
 	// Get partitions
 	partitions, err := conn.ReadPartitions(topicName)
@@ -295,19 +287,17 @@ func TestPureGo_LeaderDistribution(t *testing.T) {
 	brokerList, err := conn.Brokers()
 	require.NoError(t, err)
 
-	brokerRacks := make(map[int]string)
-	for _, broker := range brokerList {
-		brokerRacks[broker.ID] = broker.Rack
-	}
+	cluster := source.FromKafkaGo(brokerList, partitions)
+	topic, ok := cluster.Topic(topicName)
+	require.True(t, ok, "Topic should be in cluster metadata")
 
 	// Count leaders per rack
-	leadersByRack := make(map[string]int)
-	for _, partition := range partitions {
-		rack := brokerRacks[partition.Leader.ID]
-		leadersByRack[rack]++
+	for _, partition := range topic.Partitions {
 		t.Logf("Partition %d leader is broker %d in rack %s",
-			partition.ID, partition.Leader.ID, rack)
+			partition.ID, partition.Leader, cluster.RackOf(partition.Leader))
 	}
+	leadersByRack := cluster.LeadersByRack(topic.Partitions)
+	skipLeaderPlacement(t, env)
 
 	// Verify leaders are distributed
 	assert.Equal(t, 3, len(leadersByRack), "Leaders should be in all 3 racks")
@@ -326,9 +316,10 @@ func TestPureGo_LeaderDistribution(t *testing.T) {
 
 // Test 7: High partition count
 func TestPureGo_HighPartitionCount(t *testing.T) {
+	env := startThreeRackCluster(t)
 	topicName := fmt.Sprintf("test-high-part-%d", time.Now().Unix())
 
-	conn, err := kafka.Dial("tcp", broker1)
+	conn, err := kafka.Dial("tcp", env.Brokers[0])
 	require.NoError(t, err)
 	defer conn.Close()
 
@@ -345,7 +336,7 @@ func TestPureGo_HighPartitionCount(t *testing.T) {
 		ReplicationFactor: 3,
 	})
 	require.NoError(t, err)
-	time.Sleep(3 * time.Second)
+	waitForTopic(t, kafkaGoSource(env), topicName)
 
 	partitions, err := conn.ReadPartitions(topicName)
 	require.NoError(t, err)
@@ -353,21 +344,13 @@ func TestPureGo_HighPartitionCount(t *testing.T) {
 	brokerList, err := conn.Brokers()
 	require.NoError(t, err)
 
-	brokerRacks := make(map[int]string)
-	for _, broker := range brokerList {
-		brokerRacks[broker.ID] = broker.Rack
-	}
+	cluster := source.FromKafkaGo(brokerList, partitions)
+	topic, ok := cluster.Topic(topicName)
+	require.True(t, ok, "Topic should be in cluster metadata")
 
 	wellDistributed := 0
-	for _, partition := range partitions {
-		racks := make(map[string]bool)
-		for _, replica := range partition.Replicas {
-			if rack, exists := brokerRacks[replica.ID]; exists {
-				racks[rack] = true
-			}
-		}
-
-		if len(racks) == 3 {
+	for _, partition := range topic.Partitions {
+		if cluster.RackSpread(partition) == 3 {
 			wellDistributed++
 		}
 	}
@@ -385,9 +368,10 @@ func TestPureGo_HighPartitionCount(t *testing.T) {
 
 // Test 8: Single partition with rack awareness
 func TestPureGo_SinglePartition(t *testing.T) {
+	env := startThreeRackCluster(t)
 	topicName := fmt.Sprintf("test-single-part-%d", time.Now().Unix())
 
-	conn, err := kafka.Dial("tcp", broker1)
+	conn, err := kafka.Dial("tcp", env.Brokers[0])
 	require.NoError(t, err)
 	defer conn.Close()
 
@@ -404,7 +388,7 @@ func TestPureGo_SinglePartition(t *testing.T) {
 		ReplicationFactor: 3,
 	})
 	require.NoError(t, err)
-	time.Sleep(2 * time.Second)
+	waitForTopic(t, kafkaGoSource(env), topicName)
 
 	partitions, err := conn.ReadPartitions(topicName)
 	require.NoError(t, err)
@@ -413,22 +397,14 @@ func TestPureGo_SinglePartition(t *testing.T) {
 	brokerList, err := conn.Brokers()
 	require.NoError(t, err)
 
-	brokerRacks := make(map[int]string)
-	for _, broker := range brokerList {
-		brokerRacks[broker.ID] = broker.Rack
-	}
-
-	partition := partitions[0]
-	racks := make(map[string]bool)
-	for _, replica := range partition.Replicas {
-		if rack, exists := brokerRacks[replica.ID]; exists {
-			racks[rack] = true
-		}
-	}
+	cluster := source.FromKafkaGo(brokerList, partitions)
+	topic, ok := cluster.Topic(topicName)
+	require.True(t, ok, "Topic should be in cluster metadata")
 
-	assert.Equal(t, 3, len(racks), "Single partition should have replicas in all 3 racks")
+	partition := topic.Partitions[0]
+	assert.Equal(t, 3, cluster.RackSpread(partition), "Single partition should have replicas in all 3 racks")
 	t.Logf("Single partition replicas: %v, Racks: %v",
-		getBrokerIDs(partition.Replicas), getRacksFromBrokers(partition.Replicas, brokerRacks))
+		partition.Replicas, cluster.RacksForPartition(partition))
 
 	// Cleanup
 	err = controllerConn.DeleteTopics(topicName)
@@ -436,24 +412,3 @@ func TestPureGo_SinglePartition(t *testing.T) {
 		t.Logf("Warning: Failed to delete topic: %v", err)
 	}
 }
-
-// Helper function to get broker IDs from broker list
-func getBrokerIDs(brokers []kafka.Broker) []int {
-	ids := []int{}
-	for _, broker := range brokers {
-		ids = append(ids, broker.ID)
-	}
-	return ids
-}
-
-// Helper function to get racks from broker list
-func getRacksFromBrokers(brokers []kafka.Broker, brokerRacks map[int]string) []string {
-	racks := []string{}
-	for _, broker := range brokers {
-		if rack, exists := brokerRacks[broker.ID]; exists {
-			racks = append(racks, rack)
-		}
-	}
-	sort.Strings(racks)
-	return racks
-}
@@ -0,0 +1,171 @@
+// Package readiness waits for a cluster, or topics on it, to be usable.
+//
+// Kafka answers CreateTopics before every partition has elected a leader and
+// filled its ISR, and brokers accept connections before they have joined
+// the cluster. Instead of sleeping for a fixed time, the waits here poll
+// metadata with exponential backoff until the cluster looks the way the
+// caller expects, or the context is done.
+package readiness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"kafka-rack-awareness/topology"
+)
+
+// ErrNotReady is returned when the context ends before the cluster is ready.
+// The error also wraps the context's error and describes what was missing at
+// the last poll.
+var ErrNotReady = errors.New("not ready")
+
+// config holds the expectations and polling schedule of a wait.
+type config struct {
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	brokers    int
+	racks      int
+	spread     int
+}
+
+// Option configures a wait.
+type Option func(*config)
+
+// WithBackoff sets the delay before the second poll and the cap the delay
+// doubles up to. The defaults are 50ms and 1s.
+func WithBackoff(initial, limit time.Duration) Option {
+	return func(c *config) { c.minBackoff, c.maxBackoff = initial, limit }
+}
+
+// WithBrokers makes WaitForClusterReady wait until at least n brokers are
+// registered.
+func WithBrokers(n int) Option {
+	return func(c *config) { c.brokers = n }
+}
+
+// WithRacks makes WaitForClusterReady wait until the brokers span at least
+// n racks.
+func WithRacks(n int) Option {
+	return func(c *config) { c.racks = n }
+}
+
+// WithRackSpread sets how many racks every partition must span. By default
+// a partition must span min(replication factor, racks in the cluster), the
+// spread a rack-aware assignment achieves.
+func WithRackSpread(n int) Option {
+	return func(c *config) { c.spread = n }
+}
+
+func newConfig(opts []Option) config {
+	c := config{minBackoff: 50 * time.Millisecond, maxBackoff: time.Second}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// WaitForTopicReady polls until every topic exists and each of its
+// partitions has a leader, a full ISR and the expected rack spread.
+// Snapshot errors, such as an unknown topic right after creation, are
+// retried.
+func WaitForTopicReady(ctx context.Context, src topology.MetadataSource, topics []string, opts ...Option) error {
+	if len(topics) == 0 {
+		return errors.New("no topics to wait for")
+	}
+	cfg := newConfig(opts)
+	return poll(ctx, cfg, fmt.Sprintf("topics %v", topics), func() error {
+		c, err := src.Snapshot(ctx, topics...)
+		if err != nil {
+			return err
+		}
+		for _, name := range topics {
+			if err := CheckTopic(c, name, cfg.spread); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// WaitForClusterReady polls until the cluster reports the expected brokers
+// and racks and every partition of every topic has a leader and a full ISR.
+func WaitForClusterReady(ctx context.Context, src topology.MetadataSource, opts ...Option) error {
+	cfg := newConfig(opts)
+	return poll(ctx, cfg, "cluster", func() error {
+		c, err := src.Snapshot(ctx)
+		if err != nil {
+			return err
+		}
+		if len(c.Brokers) < cfg.brokers {
+			return fmt.Errorf("%d of %d broker(s) registered", len(c.Brokers), cfg.brokers)
+		}
+		if racks := len(c.RackNames()); racks < cfg.racks {
+			return fmt.Errorf("brokers span %d of %d rack(s)", racks, cfg.racks)
+		}
+		for _, t := range c.Topics {
+			for _, p := range t.Partitions {
+				if err := checkPartition(t.Name, p); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// CheckTopic reports why a topic is not ready in c, or nil if it is. A
+// spread of zero expects the default spread described in WithRackSpread.
+func CheckTopic(c *topology.Cluster, name string, spread int) error {
+	t, ok := c.Topic(name)
+	if !ok || len(t.Partitions) == 0 {
+		return fmt.Errorf("topic %q has no partitions yet", name)
+	}
+	racks := len(c.RackNames())
+	for _, p := range t.Partitions {
+		if err := checkPartition(name, p); err != nil {
+			return err
+		}
+		want := spread
+		if want == 0 {
+			want = min(len(p.Replicas), racks)
+		}
+		if got := c.RackSpread(p); got < want {
+			return fmt.Errorf("%s-%d spans %d of %d rack(s)", name, p.ID, got, want)
+		}
+	}
+	return nil
+}
+
+func checkPartition(topic string, p topology.Partition) error {
+	if p.Leader < 0 || !slices.Contains(p.Replicas, p.Leader) {
+		return fmt.Errorf("%s-%d has no leader", topic, p.ID)
+	}
+	for _, id := range p.Replicas {
+		if !slices.Contains(p.ISR, id) {
+			return fmt.Errorf("%s-%d ISR %v is missing replicas of %v", topic, p.ID, p.ISR, p.Replicas)
+		}
+	}
+	return nil
+}
+
+// poll calls check until it returns nil, sleeping with exponential backoff
+// between calls.
+func poll(ctx context.Context, cfg config, what string, check func() error) error {
+	backoff := cfg.minBackoff
+	for {
+		err := check()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s %w: %w (last check: %v)", what, ErrNotReady, ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, cfg.maxBackoff)
+	}
+}
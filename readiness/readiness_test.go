@@ -0,0 +1,127 @@
+package readiness
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// steppedSource returns one snapshot per poll, repeating the last one.
+type steppedSource struct {
+	steps []func() (*topology.Cluster, error)
+	polls int
+}
+
+func (s *steppedSource) Snapshot(context.Context, ...string) (*topology.Cluster, error) {
+	step := s.steps[min(s.polls, len(s.steps)-1)]
+	s.polls++
+	return step()
+}
+
+func snapshot(c *topology.Cluster) func() (*topology.Cluster, error) {
+	return func() (*topology.Cluster, error) { return c, nil }
+}
+
+func threeRacks() *topology.Cluster {
+	return topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+	)
+}
+
+func withOrders(c *topology.Cluster, partitions ...topology.Partition) *topology.Cluster {
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: partitions})
+	return c
+}
+
+var fast = WithBackoff(time.Millisecond, time.Millisecond)
+
+func TestCheckTopic(t *testing.T) {
+	ready := topology.Partition{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}}
+
+	tests := []struct {
+		name      string
+		partition topology.Partition
+		spread    int
+		want      string
+	}{
+		{"ready", ready, 0, ""},
+		{"no leader", topology.Partition{ID: 0, Leader: -1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}}, 0, "orders-0 has no leader"},
+		{"short ISR", topology.Partition{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2}}, 0, "orders-0 ISR [1 2] is missing replicas of [1 2 3]"},
+		{"same rack", topology.Partition{ID: 0, Leader: 1, Replicas: []int32{1, 4}, ISR: []int32{1, 4}}, 0, "orders-0 spans 1 of 2 rack(s)"},
+		{"explicit spread", ready, 4, "orders-0 spans 3 of 4 rack(s)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := threeRacks()
+			c.Brokers = append(c.Brokers, topology.Broker{ID: 4, Rack: "rack-a"})
+			withOrders(c, tt.partition)
+
+			err := CheckTopic(c, "orders", tt.spread)
+			if tt.want == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.want)
+			}
+		})
+	}
+
+	assert.EqualError(t, CheckTopic(threeRacks(), "orders", 0), `topic "orders" has no partitions yet`)
+}
+
+func TestWaitForTopicReadyPollsUntilReady(t *testing.T) {
+	src := &steppedSource{steps: []func() (*topology.Cluster, error){
+		func() (*topology.Cluster, error) { return nil, errors.New("unknown topic") },
+		snapshot(withOrders(threeRacks(), topology.Partition{ID: 0, Leader: -1, Replicas: []int32{1, 2, 3}})),
+		snapshot(withOrders(threeRacks(), topology.Partition{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1}})),
+		snapshot(withOrders(threeRacks(), topology.Partition{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{3, 1, 2}})),
+	}}
+
+	require.NoError(t, WaitForTopicReady(context.Background(), src, []string{"orders"}, fast))
+	assert.Equal(t, 4, src.polls)
+}
+
+func TestWaitForTopicReadyReportsLastProblem(t *testing.T) {
+	src := &steppedSource{steps: []func() (*topology.Cluster, error){
+		snapshot(withOrders(threeRacks(), topology.Partition{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1}})),
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := WaitForTopicReady(ctx, src, []string{"orders"}, fast)
+	require.ErrorIs(t, err, ErrNotReady)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "orders-0 ISR [1] is missing replicas of [1 2 3]")
+	assert.Greater(t, src.polls, 1)
+}
+
+func TestWaitForClusterReady(t *testing.T) {
+	oneBroker := topology.NewCluster(topology.Broker{ID: 1, Rack: "rack-a"})
+	noRacks := topology.NewCluster(topology.Broker{ID: 1}, topology.Broker{ID: 2}, topology.Broker{ID: 3})
+	src := &steppedSource{steps: []func() (*topology.Cluster, error){
+		snapshot(oneBroker),
+		snapshot(noRacks),
+		snapshot(withOrders(threeRacks(), topology.Partition{ID: 0, Leader: -1, Replicas: []int32{1, 2}})),
+		snapshot(withOrders(threeRacks(), topology.Partition{ID: 0, Leader: 2, Replicas: []int32{1, 2}, ISR: []int32{1, 2}})),
+	}}
+
+	require.NoError(t, WaitForClusterReady(context.Background(), src, WithBrokers(3), WithRacks(3), fast))
+	assert.Equal(t, 4, src.polls)
+}
+
+func TestWaitForClusterReadyStopsOnContext(t *testing.T) {
+	src := &steppedSource{steps: []func() (*topology.Cluster, error){snapshot(threeRacks())}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := WaitForClusterReady(ctx, src, WithBrokers(4), fast)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "3 of 4 broker(s) registered")
+}
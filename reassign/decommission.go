@@ -0,0 +1,104 @@
+package reassign
+
+import (
+	"fmt"
+
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/topology"
+)
+
+// FromMoves converts planner moves into a plan.
+func FromMoves(moves ...planner.Move) *Plan {
+	p := &Plan{Version: Version, Partitions: []PartitionReassignment{}}
+	for _, m := range moves {
+		p.Partitions = append(p.Partitions, PartitionReassignment{
+			Topic:     m.Topic,
+			Partition: m.Partition,
+			Replicas:  append([]int32(nil), m.To...),
+			LogDirs:   anyLogDirs(len(m.To)),
+		})
+	}
+	p.sort()
+	return p
+}
+
+// DecommissionRack plans moving every replica, internal topics included,
+// off the brokers in rack onto the remaining brokers. Each partition keeps
+// the widest rack spread the remaining racks allow.
+//
+// The plan necessarily reduces the spread of partitions that used the rack
+// when RF is at least the number of racks, so it has to be executed with
+// WithForce.
+func DecommissionRack(c *topology.Cluster, rack string) (*Plan, error) {
+	leaving := brokersIn(c, rack)
+	if rack == "" || len(leaving) == 0 {
+		return nil, fmt.Errorf("no brokers in rack %q", rack)
+	}
+
+	var remaining []topology.Broker
+	for _, b := range c.Brokers {
+		if b.Rack != rack {
+			remaining = append(remaining, b)
+		}
+	}
+	if len(remaining) == 0 {
+		return nil, fmt.Errorf("rack %q holds every broker in the cluster", rack)
+	}
+
+	p, err := planner.New(remaining, planner.WithLoad(planner.LoadOf(c)))
+	if err != nil {
+		return nil, err
+	}
+	moves, err := p.Drain(c, leaving...)
+	if err != nil {
+		return nil, err
+	}
+	return FromMoves(moves...), nil
+}
+
+// DecommissionStatus tells whether a rack can be shut down.
+type DecommissionStatus struct {
+	Rack    string
+	Brokers []int32
+	// Remaining are partitions that still have a replica in the rack.
+	Remaining []topology.Partition
+	// UnderReplicated are partitions anywhere in the cluster whose ISR is
+	// smaller than their replica list. Shutting a rack down while any exist
+	// risks losing the only in-sync copy.
+	UnderReplicated []topology.Partition
+}
+
+// Safe reports whether the rack holds no replicas and every partition is
+// fully replicated.
+func (s DecommissionStatus) Safe() bool {
+	return len(s.Remaining) == 0 && len(s.UnderReplicated) == 0
+}
+
+// CheckDecommission inspects a snapshot taken after a decommission plan has
+// completed.
+func CheckDecommission(c *topology.Cluster, rack string) DecommissionStatus {
+	s := DecommissionStatus{Rack: rack, Brokers: brokersIn(c, rack)}
+	for _, t := range c.Topics {
+		for _, p := range t.Partitions {
+			for _, id := range p.Replicas {
+				if c.RackOf(id) == rack {
+					s.Remaining = append(s.Remaining, p)
+					break
+				}
+			}
+			if len(p.ISR) < len(p.Replicas) {
+				s.UnderReplicated = append(s.UnderReplicated, p)
+			}
+		}
+	}
+	return s
+}
+
+func brokersIn(c *topology.Cluster, rack string) []int32 {
+	for _, r := range c.Racks() {
+		if r.Name == rack {
+			return r.Brokers
+		}
+	}
+	return nil
+}
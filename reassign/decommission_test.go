@@ -0,0 +1,72 @@
+package reassign
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sixBrokerCluster() *topology.Cluster {
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+		topology.Broker{ID: 4, Rack: "rack-a"},
+		topology.Broker{ID: 5, Rack: "rack-b"},
+		topology.Broker{ID: 6, Rack: "rack-c"},
+	)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}},
+		{ID: 1, Leader: 6, Replicas: []int32{6, 4, 5}, ISR: []int32{6, 4, 5}},
+		{ID: 2, Leader: 4, Replicas: []int32{4, 5}, ISR: []int32{4, 5}},
+	}})
+	c.AddTopic(topology.Topic{Name: "__consumer_offsets", Internal: true, Partitions: []topology.Partition{
+		{ID: 0, Leader: 3, Replicas: []int32{3, 1, 2}, ISR: []int32{3, 1, 2}},
+	}})
+	return c
+}
+
+func TestDecommissionRack(t *testing.T) {
+	c := sixBrokerCluster()
+
+	plan, err := DecommissionRack(c, "rack-c")
+	require.NoError(t, err)
+	require.NoError(t, plan.Check(c))
+	assert.Equal(t, []string{"__consumer_offsets", "orders"}, plan.Topics(), "internal topics are drained too")
+	require.Len(t, plan.Partitions, 3, "orders-2 has no replica in rack-c")
+
+	projected := plan.Project(c)
+	status := CheckDecommission(projected, "rack-c")
+	assert.True(t, status.Safe())
+	assert.Equal(t, []int32{3, 6}, status.Brokers)
+
+	for _, loss := range CheckDiversity(c, plan) {
+		assert.Equal(t, 2, loss.After, "%s-%d keeps every remaining rack", loss.Topic, loss.Partition)
+	}
+}
+
+func TestDecommissionRackErrors(t *testing.T) {
+	_, err := DecommissionRack(sixBrokerCluster(), "rack-z")
+	assert.ErrorContains(t, err, `no brokers in rack "rack-z"`)
+
+	single := topology.NewCluster(topology.Broker{ID: 1, Rack: "rack-a"})
+	_, err = DecommissionRack(single, "rack-a")
+	assert.ErrorContains(t, err, "every broker")
+}
+
+func TestCheckDecommission(t *testing.T) {
+	c := sixBrokerCluster()
+	c.AddTopic(topology.Topic{Name: "payments", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1}},
+	}})
+
+	status := CheckDecommission(c, "rack-c")
+	assert.False(t, status.Safe())
+	require.Len(t, status.Remaining, 3)
+	assert.Equal(t, "__consumer_offsets", status.Remaining[0].Topic)
+	require.Len(t, status.UnderReplicated, 1)
+	assert.Equal(t, "payments", status.UnderReplicated[0].Topic)
+}
@@ -0,0 +1,329 @@
+package reassign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+)
+
+// ErrReducesRackDiversity is returned when a plan would leave a partition
+// spanning fewer racks than it does today.
+var ErrReducesRackDiversity = errors.New("plan reduces rack diversity")
+
+// Admin is the part of *kadm.Client the executor uses.
+type Admin interface {
+	AlterPartitionAssignments(ctx context.Context, req kadm.AlterPartitionAssignmentsReq) (kadm.AlterPartitionAssignmentsResponses, error)
+	ListPartitionReassignments(ctx context.Context, s kadm.TopicsSet) (kadm.ListPartitionReassignmentsResponses, error)
+}
+
+// DiversityLoss is a partition whose rack spread a plan would reduce.
+type DiversityLoss struct {
+	Topic     string
+	Partition int32
+	Before    int
+	After     int
+}
+
+// CheckDiversity compares every planned partition's rack spread before and
+// after the plan.
+func CheckDiversity(c *topology.Cluster, p *Plan) []DiversityLoss {
+	projected := p.Project(c)
+	var losses []DiversityLoss
+	for _, part := range p.Partitions {
+		before, ok := findPartition(c, part.Topic, part.Partition)
+		if !ok {
+			continue
+		}
+		after, _ := findPartition(projected, part.Topic, part.Partition)
+		if b, a := c.RackSpread(before), projected.RackSpread(after); a < b {
+			losses = append(losses, DiversityLoss{Topic: part.Topic, Partition: part.Partition, Before: b, After: a})
+		}
+	}
+	return losses
+}
+
+// Rollback returns a plan that restores the current replicas of every
+// partition in p.
+func (p *Plan) Rollback(c *topology.Cluster) *Plan {
+	rollback := &Plan{Version: Version, Partitions: []PartitionReassignment{}}
+	for _, part := range p.Partitions {
+		current, ok := findPartition(c, part.Topic, part.Partition)
+		if !ok {
+			continue
+		}
+		rollback.Partitions = append(rollback.Partitions, PartitionReassignment{
+			Topic:     part.Topic,
+			Partition: part.Partition,
+			Replicas:  append([]int32(nil), current.Replicas...),
+			LogDirs:   anyLogDirs(len(current.Replicas)),
+		})
+	}
+	rollback.sort()
+	return rollback
+}
+
+// PartitionProgress is the reassignment state of one partition.
+type PartitionProgress struct {
+	Topic     string
+	Partition int32
+	Target    []int32
+	// Replicas, Adding and Removing are reported by the controller while
+	// the reassignment is in flight. Otherwise Replicas is what the
+	// cluster's metadata reports.
+	Replicas []int32
+	Adding   []int32
+	Removing []int32
+	// Done means the partition's replicas are the target. Stopped means
+	// nothing is moving it but its replicas are not the target: its
+	// reassignment was cancelled or never accepted, or metadata has not
+	// caught up with the controller yet.
+	Done    bool
+	Stopped bool
+}
+
+// Progress is a snapshot of an executing plan.
+type Progress struct {
+	Partitions []PartitionProgress
+	Done       int
+}
+
+// Complete reports whether every partition has finished moving.
+func (p Progress) Complete() bool {
+	return p.Done == len(p.Partitions)
+}
+
+// Executor applies plans with AlterPartitionReassignments and tracks them
+// with ListPartitionReassignments and the cluster's metadata.
+type Executor struct {
+	admin    Admin
+	source   topology.MetadataSource
+	poll     time.Duration
+	progress func(Progress)
+	force    bool
+}
+
+// ExecutorOption configures an Executor.
+type ExecutorOption func(*Executor)
+
+// WithPollInterval sets how often Wait polls for progress. The default is
+// five seconds.
+func WithPollInterval(d time.Duration) ExecutorOption {
+	return func(e *Executor) { e.poll = d }
+}
+
+// WithProgress registers a callback invoked after every poll.
+func WithProgress(fn func(Progress)) ExecutorOption {
+	return func(e *Executor) { e.progress = fn }
+}
+
+// WithForce skips the rack diversity check, for example when restoring a
+// rollback plan.
+func WithForce() ExecutorOption {
+	return func(e *Executor) { e.force = true }
+}
+
+// NewExecutor returns an executor. The metadata source is used to check
+// the plan against the live cluster before it starts and to confirm that
+// finished partitions reached their target.
+func NewExecutor(admin Admin, src topology.MetadataSource, opts ...ExecutorOption) *Executor {
+	e := &Executor{admin: admin, source: src, poll: 5 * time.Second}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Start validates the plan against the live cluster, refuses it if it
+// reduces rack diversity, and submits it. It returns the rollback plan that
+// restores the assignment the cluster had before.
+func (e *Executor) Start(ctx context.Context, p *Plan) (*Plan, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	cluster, err := e.source.Snapshot(ctx, p.Topics()...)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Check(cluster); err != nil {
+		return nil, err
+	}
+	if losses := CheckDiversity(cluster, p); len(losses) > 0 && !e.force {
+		return nil, diversityError(losses)
+	}
+
+	rollback := p.Rollback(cluster)
+
+	var req kadm.AlterPartitionAssignmentsReq
+	for _, part := range p.Partitions {
+		req.Assign(part.Topic, part.Partition, part.Replicas)
+	}
+	if err := e.alter(ctx, req); err != nil {
+		return rollback, err
+	}
+	return rollback, nil
+}
+
+// Wait polls until every partition in the plan has finished moving or ctx
+// is done. A partition that is still stopped on the next poll is an error,
+// since nothing will move it to the target.
+func (e *Executor) Wait(ctx context.Context, p *Plan) error {
+	ticker := time.NewTicker(e.poll)
+	defer ticker.Stop()
+
+	var stopped map[string]bool
+	for {
+		progress, err := e.Progress(ctx, p)
+		if err != nil {
+			return err
+		}
+		if e.progress != nil {
+			e.progress(progress)
+		}
+		if progress.Complete() {
+			return nil
+		}
+		stopped, err = checkStopped(progress, stopped)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkStopped returns the partitions stopped in progress, keyed by
+// topic-partition, or an error for those that were already stopped in
+// before.
+func checkStopped(progress Progress, before map[string]bool) (map[string]bool, error) {
+	now := make(map[string]bool)
+	var errs []error
+	for _, pp := range progress.Partitions {
+		if !pp.Stopped {
+			continue
+		}
+		key := fmt.Sprintf("%s-%d", pp.Topic, pp.Partition)
+		now[key] = true
+		if before[key] {
+			errs = append(errs, fmt.Errorf("%s is not being reassigned but has replicas %v, not %v", key, pp.Replicas, pp.Target))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("reassignment stopped: %w", errors.Join(errs...))
+	}
+	return now, nil
+}
+
+// Progress reports the state of every partition in the plan. A partition
+// that the controller no longer lists as reassigning is done once the
+// cluster's metadata reports the target replicas.
+func (e *Executor) Progress(ctx context.Context, p *Plan) (Progress, error) {
+	var set kadm.TopicsSet
+	for _, part := range p.Partitions {
+		set.Add(part.Topic, part.Partition)
+	}
+	listed, err := e.admin.ListPartitionReassignments(ctx, set)
+	if err != nil {
+		return Progress{}, fmt.Errorf("listing partition reassignments: %w", err)
+	}
+	cluster, err := e.source.Snapshot(ctx, p.Topics()...)
+	if err != nil {
+		return Progress{}, err
+	}
+
+	var progress Progress
+	for _, part := range p.Partitions {
+		pp := PartitionProgress{Topic: part.Topic, Partition: part.Partition, Target: part.Replicas}
+		if r, ok := listed[part.Topic][part.Partition]; ok {
+			pp.Replicas, pp.Adding, pp.Removing = r.Replicas, r.AddingReplicas, r.RemovingReplicas
+		} else {
+			live, _ := findPartition(cluster, part.Topic, part.Partition)
+			pp.Replicas = live.Replicas
+			if slices.Equal(live.Replicas, part.Replicas) {
+				pp.Done = true
+				progress.Done++
+			} else {
+				pp.Stopped = true
+			}
+		}
+		progress.Partitions = append(progress.Partitions, pp)
+	}
+	return progress, nil
+}
+
+// Cancel aborts any in-flight reassignment of the plan's partitions. Kafka
+// reverts a cancelled partition to its original replicas; partitions that
+// already finished are not touched.
+func (e *Executor) Cancel(ctx context.Context, p *Plan) error {
+	progress, err := e.Progress(ctx, p)
+	if err != nil {
+		return err
+	}
+	var req kadm.AlterPartitionAssignmentsReq
+	for _, pp := range progress.Partitions {
+		if !pp.Done && !pp.Stopped {
+			req.CancelAssign(pp.Topic, pp.Partition)
+		}
+	}
+	return e.alter(ctx, req)
+}
+
+// Rollback cancels whatever is still moving and then submits a reassignment
+// of every partition back to the replicas recorded in rollback. Like Start,
+// it does not wait for the data to move; follow it with Wait(ctx, rollback).
+func (e *Executor) Rollback(ctx context.Context, p, rollback *Plan) error {
+	if err := e.Cancel(ctx, p); err != nil {
+		return fmt.Errorf("cancelling reassignment: %w", err)
+	}
+	var req kadm.AlterPartitionAssignmentsReq
+	for _, part := range rollback.Partitions {
+		req.Assign(part.Topic, part.Partition, part.Replicas)
+	}
+	if err := e.alter(ctx, req); err != nil {
+		return fmt.Errorf("restoring original assignment: %w", err)
+	}
+	return nil
+}
+
+func (e *Executor) alter(ctx context.Context, req kadm.AlterPartitionAssignmentsReq) error {
+	if len(req) == 0 {
+		return nil
+	}
+	resps, err := e.admin.AlterPartitionAssignments(ctx, req)
+	if err != nil {
+		return fmt.Errorf("altering partition assignments: %w", err)
+	}
+	var errs []error
+	for _, r := range resps.Sorted() {
+		if r.Err == nil {
+			continue
+		}
+		err := fmt.Errorf("%s-%d: %w", r.Topic, r.Partition, r.Err)
+		if r.ErrMessage != "" {
+			err = fmt.Errorf("%w (%s)", err, r.ErrMessage)
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("altering partition assignments: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func diversityError(losses []DiversityLoss) error {
+	parts := make([]string, 0, len(losses))
+	for _, l := range losses {
+		parts = append(parts, fmt.Sprintf("%s-%d %d->%d racks", l.Topic, l.Partition, l.Before, l.After))
+	}
+	return fmt.Errorf("%w: %s", ErrReducesRackDiversity, strings.Join(parts, ", "))
+}
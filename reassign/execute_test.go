@@ -0,0 +1,234 @@
+package reassign
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+type staticSource struct{ c *topology.Cluster }
+
+func (s staticSource) Snapshot(context.Context, ...string) (*topology.Cluster, error) {
+	return s.c, nil
+}
+
+// fakeAdmin records every alter request and reports each partition as
+// reassigning for a fixed number of polls, after which its replicas in
+// cluster become the target. It is also the executor's metadata source.
+type fakeAdmin struct {
+	cluster *topology.Cluster
+	alters  []kadm.AlterPartitionAssignmentsReq
+	polls   map[string]map[int32]int
+	targets map[string]map[int32][]int32
+	pending int
+	fail    error
+}
+
+func newFakeAdmin(pending int) *fakeAdmin {
+	return &fakeAdmin{
+		cluster: testCluster(),
+		polls:   make(map[string]map[int32]int),
+		targets: make(map[string]map[int32][]int32),
+		pending: pending,
+	}
+}
+
+func (f *fakeAdmin) Snapshot(context.Context, ...string) (*topology.Cluster, error) {
+	return f.cluster, nil
+}
+
+func (f *fakeAdmin) AlterPartitionAssignments(_ context.Context, req kadm.AlterPartitionAssignmentsReq) (kadm.AlterPartitionAssignmentsResponses, error) {
+	f.alters = append(f.alters, req)
+	resps := make(kadm.AlterPartitionAssignmentsResponses)
+	for topic, partitions := range req {
+		resps[topic] = make(map[int32]kadm.AlterPartitionAssignmentsResponse)
+		for p, replicas := range partitions {
+			resps[topic][p] = kadm.AlterPartitionAssignmentsResponse{Topic: topic, Partition: p, Err: f.fail}
+			if f.fail != nil {
+				continue
+			}
+			if f.polls[topic] == nil {
+				f.polls[topic] = make(map[int32]int)
+				f.targets[topic] = make(map[int32][]int32)
+			}
+			switch {
+			case replicas == nil:
+				// Cancelled: the partition keeps its original replicas.
+				delete(f.polls[topic], p)
+			case f.pending == 0:
+				f.finish(topic, p, replicas)
+			default:
+				f.polls[topic][p] = f.pending
+				f.targets[topic][p] = replicas
+			}
+		}
+	}
+	return resps, nil
+}
+
+func (f *fakeAdmin) ListPartitionReassignments(_ context.Context, s kadm.TopicsSet) (kadm.ListPartitionReassignmentsResponses, error) {
+	resps := make(kadm.ListPartitionReassignmentsResponses)
+	for topic, partitions := range s {
+		for p := range partitions {
+			if f.polls[topic][p] == 0 {
+				continue
+			}
+			f.polls[topic][p]--
+			if f.polls[topic][p] == 0 {
+				f.finish(topic, p, f.targets[topic][p])
+			}
+			if resps[topic] == nil {
+				resps[topic] = make(map[int32]kadm.ListPartitionReassignmentsResponse)
+			}
+			resps[topic][p] = kadm.ListPartitionReassignmentsResponse{
+				Topic: topic, Partition: p, Replicas: []int32{1, 2, 3, 4}, AddingReplicas: []int32{4}, RemovingReplicas: []int32{1},
+			}
+		}
+	}
+	return resps, nil
+}
+
+// finish moves a partition to its target replicas.
+func (f *fakeAdmin) finish(topic string, partition int32, replicas []int32) {
+	t, _ := f.cluster.Topic(topic)
+	for i := range t.Partitions {
+		if t.Partitions[i].ID == partition {
+			t.Partitions[i].Replicas = replicas
+			t.Partitions[i].ISR = replicas
+			t.Partitions[i].Leader = replicas[0]
+		}
+	}
+}
+
+func movePlan() *Plan {
+	return &Plan{Version: Version, Partitions: []PartitionReassignment{
+		{Topic: "orders", Partition: 0, Replicas: []int32{4, 2, 3}},
+		{Topic: "orders", Partition: 1, Replicas: []int32{2, 3, 1}},
+	}}
+}
+
+func TestCheckDiversity(t *testing.T) {
+	c := testCluster()
+	assert.Empty(t, CheckDiversity(c, movePlan()))
+
+	collapse := &Plan{Version: Version, Partitions: []PartitionReassignment{{Topic: "orders", Partition: 0, Replicas: []int32{1, 4, 2}}}}
+	assert.Equal(t, []DiversityLoss{{Topic: "orders", Partition: 0, Before: 3, After: 2}}, CheckDiversity(c, collapse))
+}
+
+func TestRollback(t *testing.T) {
+	rollback := movePlan().Rollback(testCluster())
+	require.NoError(t, rollback.Validate())
+	assert.Equal(t, []PartitionReassignment{
+		{Topic: "orders", Partition: 0, Replicas: []int32{1, 2, 3}, LogDirs: []string{"any", "any", "any"}},
+		{Topic: "orders", Partition: 1, Replicas: []int32{2, 3, 1}, LogDirs: []string{"any", "any", "any"}},
+	}, rollback.Partitions)
+}
+
+func TestExecuteAndWait(t *testing.T) {
+	admin := newFakeAdmin(2)
+	var seen []Progress
+	exec := NewExecutor(admin, admin,
+		WithPollInterval(time.Millisecond),
+		WithProgress(func(p Progress) { seen = append(seen, p) }),
+	)
+
+	rollback, err := exec.Start(context.Background(), movePlan())
+	require.NoError(t, err)
+	assert.Equal(t, []int32{1, 2, 3}, rollback.Partitions[0].Replicas)
+	require.Len(t, admin.alters, 1)
+	assert.Equal(t, []int32{4, 2, 3}, admin.alters[0]["orders"][0])
+
+	require.NoError(t, exec.Wait(context.Background(), movePlan()))
+	require.Len(t, seen, 3)
+	assert.Equal(t, 0, seen[0].Done)
+	assert.Equal(t, []int32{4}, seen[0].Partitions[0].Adding)
+	assert.Equal(t, []int32{1}, seen[0].Partitions[0].Removing)
+	assert.True(t, seen[2].Complete())
+	assert.Equal(t, []int32{4, 2, 3}, seen[2].Partitions[0].Replicas)
+}
+
+func TestStartRefusesLessDiversePlans(t *testing.T) {
+	admin := newFakeAdmin(0)
+	plan := &Plan{Version: Version, Partitions: []PartitionReassignment{{Topic: "orders", Partition: 0, Replicas: []int32{1, 4, 2}}}}
+
+	_, err := NewExecutor(admin, staticSource{testCluster()}).Start(context.Background(), plan)
+	assert.ErrorIs(t, err, ErrReducesRackDiversity)
+	assert.ErrorContains(t, err, "orders-0 3->2 racks")
+	assert.Empty(t, admin.alters)
+
+	_, err = NewExecutor(admin, staticSource{testCluster()}, WithForce()).Start(context.Background(), plan)
+	assert.NoError(t, err)
+	assert.Len(t, admin.alters, 1)
+}
+
+func TestStartReportsPartitionErrors(t *testing.T) {
+	admin := newFakeAdmin(0)
+	admin.fail = kerr.NoReassignmentInProgress
+	rollback, err := NewExecutor(admin, staticSource{testCluster()}).Start(context.Background(), movePlan())
+	assert.ErrorContains(t, err, "orders-0")
+	assert.ErrorIs(t, err, kerr.NoReassignmentInProgress)
+	assert.NotNil(t, rollback, "rollback plan is returned even when the submit fails")
+}
+
+func TestCancelAndRollback(t *testing.T) {
+	admin := newFakeAdmin(100)
+	exec := NewExecutor(admin, admin, WithPollInterval(time.Millisecond))
+
+	plan := movePlan()
+	rollback, err := exec.Start(context.Background(), plan)
+	require.NoError(t, err)
+	admin.polls["orders"][1] = 0 // partition 1 already finished
+
+	admin.pending = 0
+	require.NoError(t, exec.Rollback(context.Background(), plan, rollback))
+	require.NoError(t, exec.Wait(context.Background(), rollback))
+	require.Len(t, admin.alters, 3)
+
+	cancel := admin.alters[1]
+	assert.Equal(t, kadm.AlterPartitionAssignmentsReq{"orders": {0: nil}}, cancel, "only in-flight partitions are cancelled")
+	assert.Equal(t, []int32{1, 2, 3}, admin.alters[2]["orders"][0])
+	assert.Equal(t, []int32{2, 3, 1}, admin.alters[2]["orders"][1])
+}
+
+func TestWaitStopsOnContext(t *testing.T) {
+	admin := newFakeAdmin(1 << 20)
+	exec := NewExecutor(admin, admin, WithPollInterval(time.Millisecond))
+	_, err := exec.Start(context.Background(), movePlan())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(exec.Wait(ctx, movePlan()), context.DeadlineExceeded))
+}
+
+func TestProgressChecksLiveReplicas(t *testing.T) {
+	admin := newFakeAdmin(100)
+	exec := NewExecutor(admin, admin, WithPollInterval(time.Millisecond))
+	plan := movePlan()
+	_, err := exec.Start(context.Background(), plan)
+	require.NoError(t, err)
+
+	// Someone else cancels the move of partition 0, which keeps its
+	// original replicas.
+	delete(admin.polls["orders"], 0)
+	delete(admin.polls["orders"], 1)
+
+	progress, err := exec.Progress(context.Background(), plan)
+	require.NoError(t, err)
+	assert.Equal(t, 1, progress.Done)
+	assert.False(t, progress.Partitions[0].Done)
+	assert.True(t, progress.Partitions[0].Stopped)
+	assert.Equal(t, []int32{1, 2, 3}, progress.Partitions[0].Replicas)
+	assert.True(t, progress.Partitions[1].Done)
+
+	err = exec.Wait(context.Background(), plan)
+	assert.EqualError(t, err, "reassignment stopped: orders-0 is not being reassigned but has replicas [1 2 3], not [4 2 3]")
+}
@@ -0,0 +1,266 @@
+// Package reassign reads, writes and projects partition reassignment plans in
+// the JSON format used by kafka-reassign-partitions.sh:
+//
+//	{"version":1,"partitions":[{"topic":"orders","partition":0,"replicas":[1,2,3],"log_dirs":["any","any","any"]}]}
+//
+// Plans written here can be executed with the Apache scripts, and files
+// produced by the scripts can be audited before they are executed.
+package reassign
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/topology"
+)
+
+// Version is the only plan format version Kafka understands.
+const Version = 1
+
+// AnyLogDir lets the broker choose the log directory for a replica.
+const AnyLogDir = "any"
+
+// Plan is a reassignment plan.
+type Plan struct {
+	Version    int                     `json:"version"`
+	Partitions []PartitionReassignment `json:"partitions"`
+}
+
+// PartitionReassignment is the target replica list of one partition. LogDirs
+// is optional; when present it has one entry per replica.
+type PartitionReassignment struct {
+	Topic     string   `json:"topic"`
+	Partition int32    `json:"partition"`
+	Replicas  []int32  `json:"replicas"`
+	LogDirs   []string `json:"log_dirs,omitempty"`
+}
+
+// FromAssignments converts planner output into a plan, with every log dir
+// set to "any" as kafka-reassign-partitions.sh --generate does.
+func FromAssignments(assignments ...planner.Assignment) *Plan {
+	p := &Plan{Version: Version, Partitions: []PartitionReassignment{}}
+	for _, a := range assignments {
+		for i, replicas := range a.Replicas {
+			p.Partitions = append(p.Partitions, PartitionReassignment{
+				Topic:     a.Topic,
+				Partition: int32(i),
+				Replicas:  append([]int32(nil), replicas...),
+				LogDirs:   anyLogDirs(len(replicas)),
+			})
+		}
+	}
+	p.sort()
+	return p
+}
+
+// Current returns the current assignment of the given topics (or all
+// non-internal topics) as a plan. Applying it restores today's layout, which
+// is what kafka-reassign-partitions.sh prints as the rollback plan.
+func Current(c *topology.Cluster, topics ...string) *Plan {
+	want := make(map[string]bool, len(topics))
+	for _, t := range topics {
+		want[t] = true
+	}
+
+	p := &Plan{Version: Version, Partitions: []PartitionReassignment{}}
+	for _, t := range c.Topics {
+		if len(want) > 0 && !want[t.Name] || len(want) == 0 && t.Internal {
+			continue
+		}
+		for _, part := range t.Partitions {
+			p.Partitions = append(p.Partitions, PartitionReassignment{
+				Topic:     t.Name,
+				Partition: part.ID,
+				Replicas:  append([]int32(nil), part.Replicas...),
+				LogDirs:   anyLogDirs(len(part.Replicas)),
+			})
+		}
+	}
+	p.sort()
+	return p
+}
+
+// Read parses and validates a plan.
+func Read(r io.Reader) (*Plan, error) {
+	var p Plan
+	if err := json.NewDecoder(r).Decode(&p); err != nil {
+		return nil, fmt.Errorf("decoding reassignment plan: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ReadFile parses and validates a plan file.
+func ReadFile(path string) (*Plan, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Write encodes the plan on a single line, like the Apache scripts do.
+func (p *Plan) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(p)
+}
+
+// WriteFile writes the plan to path.
+func (p *Plan) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Validate checks the plan for the mistakes kafka-reassign-partitions.sh
+// rejects: an unknown version, empty or duplicate replicas, mismatched
+// log_dirs and partitions listed twice.
+func (p *Plan) Validate() error {
+	if p.Version != Version {
+		return fmt.Errorf("unsupported reassignment plan version %d", p.Version)
+	}
+	seen := make(map[string]map[int32]bool)
+	for _, part := range p.Partitions {
+		name := fmt.Sprintf("%s-%d", part.Topic, part.Partition)
+		if part.Topic == "" {
+			return fmt.Errorf("partition %d has no topic", part.Partition)
+		}
+		if part.Partition < 0 {
+			return fmt.Errorf("%s: negative partition", name)
+		}
+		if len(part.Replicas) == 0 {
+			return fmt.Errorf("%s: empty replica list", name)
+		}
+		replicas := make(map[int32]bool, len(part.Replicas))
+		for _, id := range part.Replicas {
+			if replicas[id] {
+				return fmt.Errorf("%s: broker %d listed twice", name, id)
+			}
+			replicas[id] = true
+		}
+		if part.LogDirs != nil && len(part.LogDirs) != len(part.Replicas) {
+			return fmt.Errorf("%s: %d log_dirs for %d replicas", name, len(part.LogDirs), len(part.Replicas))
+		}
+		if seen[part.Topic] == nil {
+			seen[part.Topic] = make(map[int32]bool)
+		}
+		if seen[part.Topic][part.Partition] {
+			return fmt.Errorf("%s: listed twice", name)
+		}
+		seen[part.Topic][part.Partition] = true
+	}
+	return nil
+}
+
+// Check verifies that every partition and broker in the plan exists in the
+// cluster.
+func (p *Plan) Check(c *topology.Cluster) error {
+	for _, part := range p.Partitions {
+		if _, ok := findPartition(c, part.Topic, part.Partition); !ok {
+			return fmt.Errorf("%s-%d: no such partition", part.Topic, part.Partition)
+		}
+		for _, id := range part.Replicas {
+			if _, ok := c.Broker(id); !ok {
+				return fmt.Errorf("%s-%d: no such broker %d", part.Topic, part.Partition, id)
+			}
+		}
+	}
+	return nil
+}
+
+// Project returns a copy of the cluster as it will look once the plan has
+// completed: replica lists replaced, every replica in sync and the preferred
+// leader leading. Partitions not in the plan are unchanged.
+func (p *Plan) Project(c *topology.Cluster) *topology.Cluster {
+	projected := topology.NewCluster(c.Brokers...)
+	projected.ID = c.ID
+
+	targets := p.targets()
+	for _, t := range c.Topics {
+		copied := t
+		copied.Partitions = make([]topology.Partition, len(t.Partitions))
+		for i, part := range t.Partitions {
+			if replicas, ok := targets[part.Topic][part.ID]; ok {
+				part.Replicas = append([]int32(nil), replicas...)
+				part.ISR = append([]int32(nil), replicas...)
+				part.Leader = replicas[0]
+			}
+			copied.Partitions[i] = part
+		}
+		projected.AddTopic(copied)
+	}
+	return projected
+}
+
+// Topics returns the distinct topics in the plan, sorted.
+func (p *Plan) Topics() []string {
+	seen := make(map[string]bool)
+	var topics []string
+	for _, part := range p.Partitions {
+		if !seen[part.Topic] {
+			seen[part.Topic] = true
+			topics = append(topics, part.Topic)
+		}
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func (p *Plan) targets() map[string]map[int32][]int32 {
+	targets := make(map[string]map[int32][]int32)
+	for _, part := range p.Partitions {
+		if targets[part.Topic] == nil {
+			targets[part.Topic] = make(map[int32][]int32)
+		}
+		targets[part.Topic][part.Partition] = part.Replicas
+	}
+	return targets
+}
+
+func (p *Plan) sort() {
+	sort.SliceStable(p.Partitions, func(i, j int) bool {
+		a, b := p.Partitions[i], p.Partitions[j]
+		if a.Topic != b.Topic {
+			return a.Topic < b.Topic
+		}
+		return a.Partition < b.Partition
+	})
+}
+
+func findPartition(c *topology.Cluster, topic string, partition int32) (topology.Partition, bool) {
+	t, ok := c.Topic(topic)
+	if !ok {
+		return topology.Partition{}, false
+	}
+	for _, p := range t.Partitions {
+		if p.ID == partition {
+			return p, true
+		}
+	}
+	return topology.Partition{}, false
+}
+
+func anyLogDirs(n int) []string {
+	dirs := make([]string, n)
+	for i := range dirs {
+		dirs[i] = AnyLogDir
+	}
+	return dirs
+}
@@ -0,0 +1,143 @@
+package reassign
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Output of kafka-reassign-partitions.sh --generate, verbatim.
+const apachePlan = `{"version":1,"partitions":[{"topic":"orders","partition":0,"replicas":[2,3,1],"log_dirs":["any","any","any"]},{"topic":"orders","partition":1,"replicas":[3,1,2],"log_dirs":["any","any","any"]}]}`
+
+func testCluster() *topology.Cluster {
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+		topology.Broker{ID: 4, Rack: "rack-a"},
+	)
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}},
+		{ID: 1, Leader: 2, Replicas: []int32{2, 3, 1}, ISR: []int32{2, 3}},
+	}})
+	c.AddTopic(topology.Topic{Name: "__consumer_offsets", Internal: true, Partitions: []topology.Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1}, ISR: []int32{1}},
+	}})
+	return c
+}
+
+func TestRoundTripApacheFormat(t *testing.T) {
+	p, err := Read(strings.NewReader(apachePlan))
+	require.NoError(t, err)
+	require.Len(t, p.Partitions, 2)
+	assert.Equal(t, PartitionReassignment{
+		Topic: "orders", Partition: 1, Replicas: []int32{3, 1, 2}, LogDirs: []string{"any", "any", "any"},
+	}, p.Partitions[1])
+
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf))
+	assert.Equal(t, apachePlan+"\n", buf.String())
+}
+
+func TestReadWithoutLogDirs(t *testing.T) {
+	p, err := Read(strings.NewReader(`{"version":1,"partitions":[{"topic":"orders","partition":0,"replicas":[1,2]}]}`))
+	require.NoError(t, err)
+	assert.Nil(t, p.Partitions[0].LogDirs)
+
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf))
+	assert.NotContains(t, buf.String(), "log_dirs")
+}
+
+func TestReadRejectsInvalidPlans(t *testing.T) {
+	tests := map[string]string{
+		"bad json":          `{"version":`,
+		"wrong version":     `{"version":2,"partitions":[]}`,
+		"no topic":          `{"version":1,"partitions":[{"partition":0,"replicas":[1]}]}`,
+		"empty replicas":    `{"version":1,"partitions":[{"topic":"t","partition":0,"replicas":[]}]}`,
+		"duplicate broker":  `{"version":1,"partitions":[{"topic":"t","partition":0,"replicas":[1,1]}]}`,
+		"log dirs mismatch": `{"version":1,"partitions":[{"topic":"t","partition":0,"replicas":[1,2],"log_dirs":["any"]}]}`,
+		"duplicate entry":   `{"version":1,"partitions":[{"topic":"t","partition":0,"replicas":[1]},{"topic":"t","partition":0,"replicas":[2]}]}`,
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(input))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFromAssignments(t *testing.T) {
+	p := FromAssignments(
+		planner.Assignment{Topic: "payments", Replicas: [][]int32{{1, 2}}},
+		planner.Assignment{Topic: "orders", Replicas: [][]int32{{2, 3}, {3, 1}}},
+	)
+
+	require.NoError(t, p.Validate())
+	assert.Equal(t, []string{"orders", "payments"}, p.Topics())
+	assert.Equal(t, "orders", p.Partitions[0].Topic)
+	assert.Equal(t, []string{"any", "any"}, p.Partitions[0].LogDirs)
+	assert.Equal(t, "payments", p.Partitions[2].Topic)
+}
+
+func TestCurrent(t *testing.T) {
+	c := testCluster()
+
+	p := Current(c)
+	require.Len(t, p.Partitions, 2, "internal topics are skipped by default")
+	assert.Equal(t, []int32{1, 2, 3}, p.Partitions[0].Replicas)
+
+	p = Current(c, "__consumer_offsets")
+	require.Len(t, p.Partitions, 1)
+	assert.Equal(t, "__consumer_offsets", p.Partitions[0].Topic)
+}
+
+func TestCheck(t *testing.T) {
+	c := testCluster()
+
+	ok := &Plan{Version: 1, Partitions: []PartitionReassignment{{Topic: "orders", Partition: 0, Replicas: []int32{4, 2, 3}}}}
+	assert.NoError(t, ok.Check(c))
+
+	badPartition := &Plan{Version: 1, Partitions: []PartitionReassignment{{Topic: "orders", Partition: 9, Replicas: []int32{1}}}}
+	assert.ErrorContains(t, badPartition.Check(c), "no such partition")
+
+	badBroker := &Plan{Version: 1, Partitions: []PartitionReassignment{{Topic: "orders", Partition: 0, Replicas: []int32{1, 7}}}}
+	assert.ErrorContains(t, badBroker.Check(c), "no such broker 7")
+}
+
+func TestProject(t *testing.T) {
+	c := testCluster()
+	p := &Plan{Version: 1, Partitions: []PartitionReassignment{{Topic: "orders", Partition: 1, Replicas: []int32{4, 1, 2}}}}
+
+	projected := p.Project(c)
+
+	topic, _ := projected.Topic("orders")
+	assert.Equal(t, []int32{1, 2, 3}, topic.Partitions[0].Replicas, "untouched partition")
+	assert.Equal(t, topology.Partition{
+		Topic: "orders", ID: 1, Leader: 4, Replicas: []int32{4, 1, 2}, ISR: []int32{4, 1, 2},
+	}, topic.Partitions[1])
+	assert.Equal(t, 2, projected.RackSpread(topic.Partitions[1]))
+
+	original, _ := c.Topic("orders")
+	assert.Equal(t, []int32{2, 3, 1}, original.Partitions[1].Replicas, "input cluster is not modified")
+}
+
+func TestWriteFileReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	p := FromAssignments(planner.Assignment{Topic: "orders", Replicas: [][]int32{{1, 2, 3}}})
+
+	require.NoError(t, p.WriteFile(path))
+	read, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, p, read)
+
+	_, err = ReadFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
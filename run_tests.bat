@@ -26,8 +26,12 @@ if errorlevel 1 (
 )
 
 REM Wait for Kafka to be ready
-echo [INFO] Waiting for Kafka cluster to be ready (30 seconds)...
-timeout /t 30 /nobreak >nul
+echo [INFO] Waiting for Kafka cluster to be ready...
+go run ./cmd/rackctl wait --bootstrap localhost:9092,localhost:9093,localhost:9094 --brokers 3 --racks 3 --timeout 2m
+if errorlevel 1 (
+    echo [ERROR] Kafka cluster did not become ready
+    exit /b 1
+)
 
 REM Verify brokers are accessible
 echo [INFO] Verifying broker accessibility...
@@ -53,6 +57,7 @@ REM Run tests
 echo [INFO] Running Kafka Rack Awareness Tests...
 echo.
 
+set KAFKA_BROKERS=localhost:9092,localhost:9093,localhost:9094
 go test -v -timeout 10m
 if errorlevel 1 (
     echo.
@@ -39,7 +39,7 @@ docker-compose up -d
 
 # Step 3: Wait for Kafka to be ready
 print_status "$YELLOW" "Waiting for Kafka cluster to be ready..."
-sleep 20
+go run ./cmd/rackctl wait --bootstrap localhost:9092,localhost:9093,localhost:9094 --brokers 3 --racks 3 --timeout 2m
 
 # Check if all containers are running
 if [ $(docker-compose ps -q | wc -l) -eq 4 ]; then
@@ -71,7 +71,7 @@ print_status "$YELLOW" "Running Kafka Rack Awareness Tests..."
 echo ""
 
 # Run all tests with verbose output
-if go test -v -timeout 10m ./... ; then
+if KAFKA_BROKERS=localhost:9092,localhost:9093,localhost:9094 go test -v -timeout 10m ./... ; then
     print_status "$GREEN" "========================================="
     print_status "$GREEN" "✓ All tests passed successfully!"
     print_status "$GREEN" "========================================="
@@ -0,0 +1,216 @@
+package snapshot
+
+import (
+	"slices"
+	"sort"
+
+	"kafka-rack-awareness/topology"
+)
+
+// RackChange is a broker whose rack differs between two snapshots.
+type RackChange struct {
+	Broker int32  `json:"broker"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// PartitionCount is a topic whose partition count differs.
+type PartitionCount struct {
+	Topic string `json:"topic"`
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+}
+
+// LeaderMove is a partition whose leader differs. A leader of -1 means the
+// partition was offline.
+type LeaderMove struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	From      int32  `json:"from"`
+	To        int32  `json:"to"`
+	FromRack  string `json:"from_rack"`
+	ToRack    string `json:"to_rack"`
+}
+
+// ISRChange is a partition whose ISR differs.
+type ISRChange struct {
+	Topic     string  `json:"topic"`
+	Partition int32   `json:"partition"`
+	From      []int32 `json:"from"`
+	To        []int32 `json:"to"`
+	// Removed and Added are the replicas that left and joined the ISR.
+	Removed []int32 `json:"removed"`
+	Added   []int32 `json:"added"`
+	// FromRacks and ToRacks are the racks the ISR spanned.
+	FromRacks []string `json:"from_racks"`
+	ToRacks   []string `json:"to_racks"`
+}
+
+// Shrunk reports whether a replica dropped out of the ISR.
+func (c ISRChange) Shrunk() bool {
+	return len(c.Removed) > 0
+}
+
+// ReplicaChange is a partition whose replica list differs, as after a
+// reassignment.
+type ReplicaChange struct {
+	Topic     string  `json:"topic"`
+	Partition int32   `json:"partition"`
+	From      []int32 `json:"from"`
+	To        []int32 `json:"to"`
+}
+
+// ConfigChange is a topic config that differs. An empty From or To means
+// the config was not set.
+type ConfigChange struct {
+	Topic string `json:"topic"`
+	Key   string `json:"key"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Changes is what differs between two snapshots. Every list is sorted by
+// broker ID or by topic and partition.
+type Changes struct {
+	BrokersAdded    []topology.Broker `json:"brokers_added"`
+	BrokersRemoved  []topology.Broker `json:"brokers_removed"`
+	RackChanges     []RackChange      `json:"rack_changes"`
+	TopicsAdded     []string          `json:"topics_added"`
+	TopicsRemoved   []string          `json:"topics_removed"`
+	PartitionCounts []PartitionCount  `json:"partition_counts"`
+	LeaderMoves     []LeaderMove      `json:"leader_moves"`
+	ISRChanges      []ISRChange       `json:"isr_changes"`
+	ReplicaChanges  []ReplicaChange   `json:"replica_changes"`
+	ConfigChanges   []ConfigChange    `json:"config_changes"`
+}
+
+// Len returns the number of changes.
+func (c *Changes) Len() int {
+	return len(c.BrokersAdded) + len(c.BrokersRemoved) + len(c.RackChanges) +
+		len(c.TopicsAdded) + len(c.TopicsRemoved) + len(c.PartitionCounts) +
+		len(c.LeaderMoves) + len(c.ISRChanges) + len(c.ReplicaChanges) + len(c.ConfigChanges)
+}
+
+// ISRShrinks returns the ISR changes in which a replica dropped out.
+func (c *Changes) ISRShrinks() []ISRChange {
+	var out []ISRChange
+	for _, ch := range c.ISRChanges {
+		if ch.Shrunk() {
+			out = append(out, ch)
+		}
+	}
+	return out
+}
+
+// Diff returns what changed from before to after. Topics in only one of
+// the snapshots are reported as added or removed; their partitions are not
+// listed.
+func Diff(before, after *topology.Cluster) *Changes {
+	ch := &Changes{}
+	for _, b := range after.Brokers {
+		old, ok := before.Broker(b.ID)
+		switch {
+		case !ok:
+			ch.BrokersAdded = append(ch.BrokersAdded, b)
+		case old.Rack != b.Rack:
+			ch.RackChanges = append(ch.RackChanges, RackChange{Broker: b.ID, From: old.Rack, To: b.Rack})
+		}
+	}
+	for _, b := range before.Brokers {
+		if _, ok := after.Broker(b.ID); !ok {
+			ch.BrokersRemoved = append(ch.BrokersRemoved, b)
+		}
+	}
+
+	for _, t := range before.Topics {
+		if _, ok := after.Topic(t.Name); !ok {
+			ch.TopicsRemoved = append(ch.TopicsRemoved, t.Name)
+		}
+	}
+	for _, t := range after.Topics {
+		old, ok := before.Topic(t.Name)
+		if !ok {
+			ch.TopicsAdded = append(ch.TopicsAdded, t.Name)
+			continue
+		}
+		if len(old.Partitions) != len(t.Partitions) {
+			ch.PartitionCounts = append(ch.PartitionCounts, PartitionCount{Topic: t.Name, From: len(old.Partitions), To: len(t.Partitions)})
+		}
+		ch.ConfigChanges = append(ch.ConfigChanges, diffConfigs(t.Name, old.Configs, t.Configs)...)
+		for _, p := range t.Partitions {
+			if prev, ok := partition(old, p.ID); ok {
+				ch.diffPartition(before, after, prev, p)
+			}
+		}
+	}
+	return ch
+}
+
+// diffPartition records how one partition changed.
+func (ch *Changes) diffPartition(before, after *topology.Cluster, prev, p topology.Partition) {
+	if prev.Leader != p.Leader {
+		ch.LeaderMoves = append(ch.LeaderMoves, LeaderMove{
+			Topic:     p.Topic,
+			Partition: p.ID,
+			From:      prev.Leader,
+			To:        p.Leader,
+			FromRack:  before.RackOf(prev.Leader),
+			ToRack:    after.RackOf(p.Leader),
+		})
+	}
+	if removed, added := without(prev.ISR, p.ISR), without(p.ISR, prev.ISR); len(removed) > 0 || len(added) > 0 {
+		ch.ISRChanges = append(ch.ISRChanges, ISRChange{
+			Topic:     p.Topic,
+			Partition: p.ID,
+			From:      prev.ISR,
+			To:        p.ISR,
+			Removed:   removed,
+			Added:     added,
+			FromRacks: before.RacksForISR(prev),
+			ToRacks:   after.RacksForISR(p),
+		})
+	}
+	if !slices.Equal(prev.Replicas, p.Replicas) {
+		ch.ReplicaChanges = append(ch.ReplicaChanges, ReplicaChange{Topic: p.Topic, Partition: p.ID, From: prev.Replicas, To: p.Replicas})
+	}
+}
+
+// diffConfigs returns the configs of a topic that differ, sorted by key.
+func diffConfigs(topic string, before, after map[string]string) []ConfigChange {
+	keys := make(map[string]bool)
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	var out []ConfigChange
+	for k := range keys {
+		if before[k] != after[k] {
+			out = append(out, ConfigChange{Topic: topic, Key: k, From: before[k], To: after[k]})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// partition returns the partition of t with the given ID.
+func partition(t *topology.Topic, id int32) (topology.Partition, bool) {
+	for _, p := range t.Partitions {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return topology.Partition{}, false
+}
+
+// without returns the IDs in a that are not in b.
+func without(a, b []int32) []int32 {
+	var out []int32
+	for _, id := range a {
+		if !slices.Contains(b, id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package snapshot
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	before := ordersCluster()
+	before.AddTopic(topology.Topic{Name: "audit-log", Partitions: []topology.Partition{{ID: 0, Leader: 1, Replicas: []int32{1}, ISR: []int32{1}}}})
+
+	// Broker 3 (rack-c) failed and was replaced by broker 4, broker 2 was
+	// moved to another rack, and orders grew and was reconfigured.
+	after := topology.NewCluster(
+		topology.Broker{ID: 1, Host: "kafka1", Port: 9092, Rack: "rack-a"},
+		topology.Broker{ID: 2, Host: "kafka2", Port: 9092, Rack: "rack-d"},
+		topology.Broker{ID: 4, Host: "kafka4", Port: 9092, Rack: "rack-c"},
+	)
+	after.AddTopic(topology.Topic{
+		Name:    "orders",
+		Configs: map[string]string{"min.insync.replicas": "1", "retention.ms": "60000"},
+		Partitions: []topology.Partition{
+			{ID: 0, Leader: 1, Replicas: []int32{1, 2, 4}, ISR: []int32{1, 2, 4}},
+			{ID: 1, Leader: 3, Replicas: []int32{2, 3, 1}, ISR: []int32{3}},
+			{ID: 2, Leader: -1, Replicas: []int32{3}, ISR: []int32{}},
+		},
+	})
+	after.AddTopic(topology.Topic{Name: "payments", Partitions: []topology.Partition{{ID: 0, Leader: 1, Replicas: []int32{1}, ISR: []int32{1}}}})
+
+	ch := Diff(before, after)
+	assert.Equal(t, []topology.Broker{{ID: 4, Host: "kafka4", Port: 9092, Rack: "rack-c"}}, ch.BrokersAdded)
+	assert.Equal(t, []topology.Broker{{ID: 3, Host: "kafka3", Port: 9092, Rack: "rack-c"}}, ch.BrokersRemoved)
+	assert.Equal(t, []RackChange{{Broker: 2, From: "rack-b", To: "rack-d"}}, ch.RackChanges)
+	assert.Equal(t, []string{"payments"}, ch.TopicsAdded)
+	assert.Equal(t, []string{"__consumer_offsets", "audit-log"}, ch.TopicsRemoved)
+	assert.Equal(t, []PartitionCount{{Topic: "orders", From: 2, To: 3}}, ch.PartitionCounts)
+	assert.Equal(t, []ConfigChange{
+		{Topic: "orders", Key: "min.insync.replicas", From: "2", To: "1"},
+		{Topic: "orders", Key: "retention.ms", To: "60000"},
+	}, ch.ConfigChanges)
+	// Broker 3 is gone from the second snapshot, so its rack is unknown.
+	assert.Equal(t, []LeaderMove{{Topic: "orders", Partition: 1, From: 2, To: 3, FromRack: "rack-b"}}, ch.LeaderMoves)
+	assert.Equal(t, []ReplicaChange{{Topic: "orders", Partition: 0, From: []int32{1, 2, 3}, To: []int32{1, 2, 4}}}, ch.ReplicaChanges)
+
+	assert.Len(t, ch.ISRChanges, 2)
+	shrinks := ch.ISRShrinks()
+	assert.Len(t, shrinks, 2, "partition 0 swapped 3 for 4 and partition 1 lost two replicas")
+	assert.Equal(t, ISRChange{
+		Topic:     "orders",
+		Partition: 1,
+		From:      []int32{2, 3, 1},
+		To:        []int32{3},
+		Removed:   []int32{2, 1},
+		FromRacks: []string{"rack-a", "rack-b", "rack-c"},
+		ToRacks:   []string{},
+	}, shrinks[1])
+	assert.Equal(t, 13, ch.Len())
+}
+
+func TestDiffSameSnapshot(t *testing.T) {
+	ch := Diff(ordersCluster(), ordersCluster())
+	assert.Zero(t, ch.Len())
+
+	grown := ordersCluster()
+	grown.Topics[1].Partitions[0].ISR = []int32{1, 2}
+	ch = Diff(grown, ordersCluster())
+	assert.Equal(t, []ISRChange{{
+		Topic:     "orders",
+		Partition: 0,
+		From:      []int32{1, 2},
+		To:        []int32{1, 2, 3},
+		Added:     []int32{3},
+		FromRacks: []string{"rack-a", "rack-b"},
+		ToRacks:   []string{"rack-a", "rack-b", "rack-c"},
+	}}, ch.ISRChanges)
+	assert.Empty(t, ch.ISRShrinks())
+}
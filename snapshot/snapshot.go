@@ -0,0 +1,153 @@
+// Package snapshot saves cluster snapshots to versioned JSON files and
+// compares them.
+//
+// A snapshot file records everything rack placement depends on: brokers and
+// their racks, topics and their configs, and every partition's leader,
+// replicas and ISR. Taken before and after an incident or a maintenance
+// window, two files and Diff show what moved, which is the evidence an
+// incident review wants attached.
+package snapshot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"kafka-rack-awareness/topology"
+)
+
+// Version is the file format version written by Write. Read accepts files
+// up to this version.
+const Version = 1
+
+// Snapshot is a cluster as it was at one point in time.
+type Snapshot struct {
+	TakenAt time.Time
+	Cluster *topology.Cluster
+}
+
+// fileJSON is the on-disk format. It is separate from the topology types so
+// that they can change without breaking saved files.
+type fileJSON struct {
+	Version   int          `json:"version"`
+	TakenAt   time.Time    `json:"taken_at"`
+	ClusterID string       `json:"cluster_id,omitempty"`
+	Brokers   []brokerJSON `json:"brokers"`
+	Topics    []topicJSON  `json:"topics"`
+}
+
+type brokerJSON struct {
+	ID   int32  `json:"id"`
+	Host string `json:"host,omitempty"`
+	Port int32  `json:"port,omitempty"`
+	Rack string `json:"rack,omitempty"`
+}
+
+type topicJSON struct {
+	Name       string            `json:"name"`
+	Internal   bool              `json:"internal,omitempty"`
+	Configs    map[string]string `json:"configs,omitempty"`
+	Partitions []partitionJSON   `json:"partitions"`
+}
+
+type partitionJSON struct {
+	ID       int32   `json:"id"`
+	Leader   int32   `json:"leader"`
+	Replicas []int32 `json:"replicas"`
+	ISR      []int32 `json:"isr"`
+}
+
+// Write writes s as indented JSON.
+func Write(w io.Writer, s *Snapshot) error {
+	f := fileJSON{
+		Version:   Version,
+		TakenAt:   s.TakenAt.UTC(),
+		ClusterID: s.Cluster.ID,
+		Brokers:   make([]brokerJSON, 0, len(s.Cluster.Brokers)),
+		Topics:    make([]topicJSON, 0, len(s.Cluster.Topics)),
+	}
+	for _, b := range s.Cluster.Brokers {
+		f.Brokers = append(f.Brokers, brokerJSON{ID: b.ID, Host: b.Host, Port: b.Port, Rack: b.Rack})
+	}
+	for _, t := range s.Cluster.Topics {
+		tj := topicJSON{Name: t.Name, Internal: t.Internal, Configs: t.Configs, Partitions: make([]partitionJSON, 0, len(t.Partitions))}
+		for _, p := range t.Partitions {
+			tj.Partitions = append(tj.Partitions, partitionJSON{
+				ID:       p.ID,
+				Leader:   p.Leader,
+				Replicas: nonNil(p.Replicas),
+				ISR:      nonNil(p.ISR),
+			})
+		}
+		f.Topics = append(f.Topics, tj)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// WriteFile writes s to path, replacing the file if it exists.
+func WriteFile(path string, s *Snapshot) error {
+	var buf bytes.Buffer
+	if err := Write(&buf, s); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// Read parses a snapshot file. Files without a version or from a newer
+// version of the format are rejected, as are unknown fields.
+func Read(r io.Reader) (*Snapshot, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var f fileJSON
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("parsing snapshot: %w", err)
+	}
+	switch {
+	case f.Version == 0:
+		return nil, fmt.Errorf("not a snapshot file: no version")
+	case f.Version > Version:
+		return nil, fmt.Errorf("snapshot version %d is newer than the supported version %d", f.Version, Version)
+	}
+
+	brokers := make([]topology.Broker, 0, len(f.Brokers))
+	for _, b := range f.Brokers {
+		brokers = append(brokers, topology.Broker{ID: b.ID, Host: b.Host, Port: b.Port, Rack: b.Rack})
+	}
+	c := topology.NewCluster(brokers...)
+	c.ID = f.ClusterID
+	for _, tj := range f.Topics {
+		t := topology.Topic{Name: tj.Name, Internal: tj.Internal, Configs: tj.Configs}
+		for _, p := range tj.Partitions {
+			t.Partitions = append(t.Partitions, topology.Partition{ID: p.ID, Leader: p.Leader, Replicas: p.Replicas, ISR: p.ISR})
+		}
+		c.AddTopic(t)
+	}
+	return &Snapshot{TakenAt: f.TakenAt, Cluster: c}, nil
+}
+
+// ReadFile parses a snapshot file.
+func ReadFile(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// nonNil returns ids, or an empty slice if ids is nil, so that an empty ISR
+// is written as [] rather than null.
+func nonNil(ids []int32) []int32 {
+	if ids == nil {
+		return []int32{}
+	}
+	return ids
+}
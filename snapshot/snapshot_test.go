@@ -0,0 +1,77 @@
+package snapshot
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ordersCluster() *topology.Cluster {
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Host: "kafka1", Port: 9092, Rack: "rack-a"},
+		topology.Broker{ID: 2, Host: "kafka2", Port: 9092, Rack: "rack-b"},
+		topology.Broker{ID: 3, Host: "kafka3", Port: 9092, Rack: "rack-c"},
+	)
+	c.ID = "cluster-1"
+	c.AddTopic(topology.Topic{
+		Name:    "orders",
+		Configs: map[string]string{"min.insync.replicas": "2"},
+		Partitions: []topology.Partition{
+			{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}, ISR: []int32{1, 2, 3}},
+			{ID: 1, Leader: 2, Replicas: []int32{2, 3, 1}, ISR: []int32{2, 3, 1}},
+		},
+	})
+	c.AddTopic(topology.Topic{Name: "__consumer_offsets", Internal: true, Partitions: []topology.Partition{
+		{ID: 0, Leader: 3, Replicas: []int32{3, 1, 2}, ISR: []int32{3, 1, 2}},
+	}})
+	return c
+}
+
+func TestWriteRead(t *testing.T) {
+	takenAt := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "before.json")
+	require.NoError(t, WriteFile(path, &Snapshot{TakenAt: takenAt, Cluster: ordersCluster()}))
+
+	s, err := ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, takenAt.Equal(s.TakenAt))
+	assert.Equal(t, ordersCluster(), s.Cluster)
+}
+
+func TestWriteFormat(t *testing.T) {
+	c := topology.NewCluster(topology.Broker{ID: 1})
+	c.AddTopic(topology.Topic{Name: "orders", Partitions: []topology.Partition{{ID: 0, Leader: -1, Replicas: []int32{1}}}})
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, &Snapshot{TakenAt: time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC), Cluster: c}))
+	assert.JSONEq(t, `{
+		"version": 1,
+		"taken_at": "2026-10-16T09:30:00Z",
+		"brokers": [{"id": 1}],
+		"topics": [{"name": "orders", "partitions": [{"id": 0, "leader": -1, "replicas": [1], "isr": []}]}]
+	}`, buf.String())
+}
+
+func TestReadRejects(t *testing.T) {
+	tests := map[string]struct {
+		file string
+		want string
+	}{
+		"no version":    {`{"brokers": []}`, "not a snapshot file: no version"},
+		"newer version": {`{"version": 2}`, "snapshot version 2 is newer than the supported version 1"},
+		"unknown field": {`{"version": 1, "leaders": {}}`, `unknown field "leaders"`},
+		"not json":      {`brokers: []`, "parsing snapshot"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.file))
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+//go:build confluent
+
+// The confluent-kafka-go adapter needs cgo and librdkafka, so it is only built
+// with -tags confluent to keep the default build pure Go.
+
+package source
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Confluent reads cluster metadata with a confluent-kafka-go admin client.
+//
+// GetMetadata does not report broker racks, so brokers come from
+// DescribeCluster and partitions from DescribeTopics.
+type Confluent struct {
+	admin *kafka.AdminClient
+}
+
+// NewConfluent returns a metadata source backed by a confluent-kafka-go admin
+// client.
+func NewConfluent(admin *kafka.AdminClient) *Confluent {
+	return &Confluent{admin: admin}
+}
+
+// Snapshot implements topology.MetadataSource.
+func (s *Confluent) Snapshot(ctx context.Context, topics ...string) (*topology.Cluster, error) {
+	cluster, err := s.admin.DescribeCluster(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("describing confluent cluster: %w", err)
+	}
+
+	if len(topics) == 0 {
+		timeout := 30 * time.Second
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+		metadata, err := s.admin.GetMetadata(nil, true, int(timeout.Milliseconds()))
+		if err != nil {
+			return nil, fmt.Errorf("listing confluent topics: %w", err)
+		}
+		for name := range metadata.Topics {
+			topics = append(topics, name)
+		}
+	}
+
+	described, err := s.admin.DescribeTopics(ctx, kafka.NewTopicCollectionOfTopicNames(topics))
+	if err != nil {
+		return nil, fmt.Errorf("describing confluent topics: %w", err)
+	}
+	for _, topic := range described.TopicDescriptions {
+		if topic.Error.Code() != kafka.ErrNoError {
+			return nil, topicError(topic.Name, topic.Error)
+		}
+	}
+	return FromConfluent(cluster, described), nil
+}
+
+// FromConfluent converts DescribeCluster and DescribeTopics results into a
+// cluster. Brokers reported with a nil rack become brokers without a rack.
+func FromConfluent(cluster kafka.DescribeClusterResult, described kafka.DescribeTopicsResult) *topology.Cluster {
+	brokers := make([]topology.Broker, 0, len(cluster.Nodes))
+	for _, node := range cluster.Nodes {
+		brokers = append(brokers, topology.Broker{
+			ID:   int32(node.ID),
+			Host: node.Host,
+			Port: int32(node.Port),
+			Rack: rackOrEmpty(node.Rack),
+		})
+	}
+
+	topics := make([]topology.Topic, 0, len(described.TopicDescriptions))
+	for _, topic := range described.TopicDescriptions {
+		t := topology.Topic{Name: topic.Name, Internal: topic.IsInternal}
+		for _, partition := range topic.Partitions {
+			leader := int32(-1)
+			if partition.Leader != nil {
+				leader = int32(partition.Leader.ID)
+			}
+			t.Partitions = append(t.Partitions, topology.Partition{
+				ID:       int32(partition.Partition),
+				Leader:   leader,
+				Replicas: confluentNodeIDs(partition.Replicas),
+				ISR:      confluentNodeIDs(partition.Isr),
+			})
+		}
+		topics = append(topics, t)
+	}
+
+	id := ""
+	if cluster.ClusterID != nil {
+		id = *cluster.ClusterID
+	}
+	return newCluster(id, brokers, topics)
+}
+
+func confluentNodeIDs(nodes []kafka.Node) []int32 {
+	ids := make([]int32, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, int32(node.ID))
+	}
+	return ids
+}
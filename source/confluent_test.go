@@ -0,0 +1,46 @@
+//go:build confluent
+
+package source
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ topology.MetadataSource = (*Confluent)(nil)
+
+func TestFromConfluent(t *testing.T) {
+	rackA := "rack-a"
+	clusterID := "cluster-1"
+	n1 := kafka.Node{ID: 1, Host: "b1", Port: 9092, Rack: &rackA}
+	n2 := kafka.Node{ID: 2, Host: "b2", Port: 9093}
+
+	cluster := FromConfluent(
+		kafka.DescribeClusterResult{ClusterID: &clusterID, Nodes: []kafka.Node{n2, n1}},
+		kafka.DescribeTopicsResult{TopicDescriptions: []kafka.TopicDescription{{
+			Name: "orders",
+			Partitions: []kafka.TopicPartitionInfo{
+				{Partition: 1, Leader: nil, Replicas: []kafka.Node{n2, n1}},
+				{Partition: 0, Leader: &n1, Replicas: []kafka.Node{n1, n2}, Isr: []kafka.Node{n1}},
+			},
+		}}},
+	)
+
+	assert.Equal(t, "cluster-1", cluster.ID)
+	assert.Equal(t, []topology.Broker{
+		{ID: 1, Host: "b1", Port: 9092, Rack: "rack-a"},
+		{ID: 2, Host: "b2", Port: 9093},
+	}, cluster.Brokers)
+
+	topic, ok := cluster.Topic("orders")
+	require.True(t, ok)
+	assert.Equal(t, []topology.Partition{
+		{Topic: "orders", ID: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1}},
+		{Topic: "orders", ID: 1, Leader: -1, Replicas: []int32{2, 1}, ISR: []int32{}},
+	}, topic.Partitions)
+}
@@ -0,0 +1,183 @@
+package source
+
+import (
+	"context"
+	"fmt"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// Franz reads cluster metadata with a franz-go client.
+type Franz struct {
+	client *kgo.Client
+}
+
+// NewFranz returns a metadata source backed by a franz-go client.
+func NewFranz(client *kgo.Client) *Franz {
+	return &Franz{client: client}
+}
+
+// Snapshot implements topology.MetadataSource. It sends its own metadata
+// request rather than going through kadm, which answers from the client's
+// cache for up to kgo.MetadataMinAge: waits and reassignment progress poll
+// Snapshot and must see leaders, replicas and ISRs as they are now, not as
+// they were before the last reassignment finished.
+func (s *Franz) Snapshot(ctx context.Context, topics ...string) (*topology.Cluster, error) {
+	req := kmsg.NewPtrMetadataRequest()
+	for _, name := range topics {
+		t := kmsg.NewMetadataRequestTopic()
+		t.Topic = kmsg.StringPtr(name)
+		req.Topics = append(req.Topics, t)
+	}
+	resp, err := req.RequestWith(ctx, s.client)
+	if err != nil {
+		return nil, fmt.Errorf("fetching franz-go metadata: %w", err)
+	}
+	for _, t := range resp.Topics {
+		if err := kerr.ErrorForCode(t.ErrorCode); err != nil {
+			return nil, topicError(stringOrEmpty(t.Topic), err)
+		}
+	}
+	return fromMetadataResponse(resp), nil
+}
+
+// fromMetadataResponse converts a raw metadata response into a cluster.
+func fromMetadataResponse(resp *kmsg.MetadataResponse) *topology.Cluster {
+	brokers := make([]topology.Broker, 0, len(resp.Brokers))
+	for _, broker := range resp.Brokers {
+		brokers = append(brokers, topology.Broker{
+			ID:   broker.NodeID,
+			Host: broker.Host,
+			Port: broker.Port,
+			Rack: rackOrEmpty(broker.Rack),
+		})
+	}
+
+	topics := make([]topology.Topic, 0, len(resp.Topics))
+	for _, detail := range resp.Topics {
+		t := topology.Topic{Name: stringOrEmpty(detail.Topic), Internal: detail.IsInternal}
+		for _, partition := range detail.Partitions {
+			t.Partitions = append(t.Partitions, topology.Partition{
+				ID:       partition.Partition,
+				Leader:   partition.Leader,
+				Replicas: append([]int32(nil), partition.Replicas...),
+				ISR:      append([]int32(nil), partition.ISR...),
+			})
+		}
+		topics = append(topics, t)
+	}
+	return newCluster(stringOrEmpty(resp.ClusterID), brokers, topics)
+}
+
+// stringOrEmpty dereferences a nullable string from a raw response.
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// FromFranz converts kadm metadata into a cluster. Brokers reported with a
+// nil rack become brokers without a rack.
+func FromFranz(metadata kadm.Metadata) *topology.Cluster {
+	brokers := make([]topology.Broker, 0, len(metadata.Brokers))
+	for _, broker := range metadata.Brokers {
+		brokers = append(brokers, topology.Broker{
+			ID:   broker.NodeID,
+			Host: broker.Host,
+			Port: broker.Port,
+			Rack: rackOrEmpty(broker.Rack),
+		})
+	}
+
+	topics := make([]topology.Topic, 0, len(metadata.Topics))
+	for _, name := range metadata.Topics.Names() {
+		detail := metadata.Topics[name]
+		t := topology.Topic{Name: detail.Topic, Internal: detail.IsInternal}
+		for _, partition := range detail.Partitions.Sorted() {
+			t.Partitions = append(t.Partitions, topology.Partition{
+				ID:       partition.Partition,
+				Leader:   partition.Leader,
+				Replicas: append([]int32(nil), partition.Replicas...),
+				ISR:      append([]int32(nil), partition.ISR...),
+			})
+		}
+		topics = append(topics, t)
+	}
+	return newCluster(metadata.Cluster, brokers, topics)
+}
+
+// LogDirSizes describes the log directories of every broker and returns the
+// size of each replica. Future replicas of an in-flight log dir move are
+// skipped so they are not counted twice.
+func LogDirSizes(ctx context.Context, admin *kadm.Client, topics ...string) (topology.ReplicaSizes, error) {
+	var set kadm.TopicsSet
+	if len(topics) > 0 {
+		metadata, err := admin.Metadata(ctx, topics...)
+		if err != nil {
+			return nil, fmt.Errorf("fetching franz-go metadata: %w", err)
+		}
+		set = metadata.Topics.TopicsSet()
+	}
+	described, err := admin.DescribeAllLogDirs(ctx, set)
+	if err != nil {
+		return nil, fmt.Errorf("describing log dirs: %w", err)
+	}
+
+	sizes := make(topology.ReplicaSizes)
+	for _, dir := range described.Sorted() {
+		if dir.Err != nil {
+			return nil, fmt.Errorf("describing log dir %s on broker %d: %w", dir.Dir, dir.Broker, dir.Err)
+		}
+		for _, p := range dir.Topics.Sorted() {
+			if !p.IsFuture {
+				sizes.Set(p.Broker, p.Topic, p.Partition, p.Size)
+			}
+		}
+	}
+	return sizes, nil
+}
+
+// TopicConfigs describes the configs of every topic in c and records the
+// given keys, or every config if none are given, in Topic.Configs. Configs
+// left at the broker default are recorded too, so callers see the
+// effective value.
+func TopicConfigs(ctx context.Context, admin *kadm.Client, c *topology.Cluster, keys ...string) error {
+	names := make([]string, 0, len(c.Topics))
+	for _, t := range c.Topics {
+		names = append(names, t.Name)
+	}
+	described, err := admin.DescribeTopicConfigs(ctx, names...)
+	if err != nil {
+		return fmt.Errorf("describing topic configs: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		wanted[k] = true
+	}
+	for _, rc := range described {
+		if rc.Err != nil {
+			return topicError(rc.Name, rc.Err)
+		}
+		t, ok := c.Topic(rc.Name)
+		if !ok {
+			continue
+		}
+		for _, cfg := range rc.Configs {
+			if cfg.Value == nil || (len(wanted) > 0 && !wanted[cfg.Key]) {
+				continue
+			}
+			if t.Configs == nil {
+				t.Configs = make(map[string]string)
+			}
+			t.Configs[cfg.Key] = *cfg.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package source
+
+import (
+	"context"
+	"fmt"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaGo reads cluster metadata with a segmentio/kafka-go client.
+type KafkaGo struct {
+	client *kafka.Client
+}
+
+// NewKafkaGo returns a metadata source backed by a kafka-go client. The
+// client's Addr must be set.
+func NewKafkaGo(client *kafka.Client) *KafkaGo {
+	return &KafkaGo{client: client}
+}
+
+// Snapshot implements topology.MetadataSource.
+func (s *KafkaGo) Snapshot(ctx context.Context, topics ...string) (*topology.Cluster, error) {
+	resp, err := s.client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return nil, fmt.Errorf("fetching kafka-go metadata: %w", err)
+	}
+	for _, topic := range resp.Topics {
+		if topic.Error != nil {
+			return nil, topicError(topic.Name, topic.Error)
+		}
+	}
+	cluster := FromKafkaGoTopics(resp.Brokers, resp.Topics)
+	cluster.ID = resp.ClusterID
+	return cluster, nil
+}
+
+// FromKafkaGo converts the results of kafka.Conn.Brokers and
+// kafka.Conn.ReadPartitions into a cluster.
+func FromKafkaGo(brokers []kafka.Broker, partitions []kafka.Partition) *topology.Cluster {
+	var topics []kafka.Topic
+	index := make(map[string]int)
+	for _, partition := range partitions {
+		i, ok := index[partition.Topic]
+		if !ok {
+			i = len(topics)
+			index[partition.Topic] = i
+			topics = append(topics, kafka.Topic{Name: partition.Topic})
+		}
+		topics[i].Partitions = append(topics[i].Partitions, partition)
+	}
+	return FromKafkaGoTopics(brokers, topics)
+}
+
+// FromKafkaGoTopics converts kafka-go metadata into a cluster.
+func FromKafkaGoTopics(brokers []kafka.Broker, topics []kafka.Topic) *topology.Cluster {
+	normalized := make([]topology.Broker, 0, len(brokers))
+	for _, broker := range brokers {
+		normalized = append(normalized, topology.Broker{
+			ID:   int32(broker.ID),
+			Host: broker.Host,
+			Port: int32(broker.Port),
+			Rack: broker.Rack,
+		})
+	}
+
+	normalizedTopics := make([]topology.Topic, 0, len(topics))
+	for _, topic := range topics {
+		t := topology.Topic{Name: topic.Name, Internal: topic.Internal}
+		for _, partition := range topic.Partitions {
+			t.Partitions = append(t.Partitions, topology.Partition{
+				ID:       int32(partition.ID),
+				Leader:   int32(partition.Leader.ID),
+				Replicas: kafkaGoBrokerIDs(partition.Replicas),
+				ISR:      kafkaGoBrokerIDs(partition.Isr),
+			})
+		}
+		normalizedTopics = append(normalizedTopics, t)
+	}
+	return newCluster("", normalized, normalizedTopics)
+}
+
+func kafkaGoBrokerIDs(brokers []kafka.Broker) []int32 {
+	ids := make([]int32, 0, len(brokers))
+	for _, broker := range brokers {
+		ids = append(ids, int32(broker.ID))
+	}
+	return ids
+}
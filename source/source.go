@@ -0,0 +1,36 @@
+// Package source adapts the metadata APIs of the supported Kafka clients to
+// topology.MetadataSource, so rack audits do not depend on a specific client.
+//
+// Each adapter also exposes a From* function that converts already-fetched
+// client metadata into a topology.Cluster.
+package source
+
+import (
+	"fmt"
+
+	"kafka-rack-awareness/topology"
+)
+
+// rackOrEmpty normalizes an optional rack to the topology convention of ""
+// for brokers without a rack.
+func rackOrEmpty(rack *string) string {
+	if rack == nil {
+		return ""
+	}
+	return *rack
+}
+
+// topicError wraps a per-topic metadata error.
+func topicError(topic string, err error) error {
+	return fmt.Errorf("metadata for topic %q: %w", topic, err)
+}
+
+// newCluster builds a cluster from normalized brokers and topics.
+func newCluster(id string, brokers []topology.Broker, topics []topology.Topic) *topology.Cluster {
+	cluster := topology.NewCluster(brokers...)
+	cluster.ID = id
+	for _, topic := range topics {
+		cluster.AddTopic(topic)
+	}
+	return cluster
+}
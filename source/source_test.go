@@ -0,0 +1,120 @@
+package source
+
+import (
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestFromKafkaGo(t *testing.T) {
+	brokers := []kafka.Broker{
+		{ID: 2, Host: "b2", Port: 9093, Rack: "rack-b"},
+		{ID: 1, Host: "b1", Port: 9092, Rack: "rack-a"},
+		{ID: 3, Host: "b3", Port: 9094},
+	}
+	partitions := []kafka.Partition{
+		{Topic: "orders", ID: 1, Leader: brokers[0], Replicas: []kafka.Broker{brokers[0], brokers[1]}, Isr: []kafka.Broker{brokers[0]}},
+		{Topic: "orders", ID: 0, Leader: brokers[1], Replicas: []kafka.Broker{brokers[1], brokers[2]}, Isr: []kafka.Broker{brokers[1], brokers[2]}},
+	}
+
+	cluster := FromKafkaGo(brokers, partitions)
+
+	assert.Equal(t, []topology.Broker{
+		{ID: 1, Host: "b1", Port: 9092, Rack: "rack-a"},
+		{ID: 2, Host: "b2", Port: 9093, Rack: "rack-b"},
+		{ID: 3, Host: "b3", Port: 9094},
+	}, cluster.Brokers)
+
+	topic, ok := cluster.Topic("orders")
+	require.True(t, ok)
+	assert.Equal(t, []topology.Partition{
+		{Topic: "orders", ID: 0, Leader: 1, Replicas: []int32{1, 3}, ISR: []int32{1, 3}},
+		{Topic: "orders", ID: 1, Leader: 2, Replicas: []int32{2, 1}, ISR: []int32{2}},
+	}, topic.Partitions)
+}
+
+func TestFromFranz(t *testing.T) {
+	metadata := kadm.Metadata{
+		Cluster: "cluster-1",
+		Brokers: kadm.BrokerDetails{
+			{NodeID: 1, Host: "b1", Port: 9092, Rack: strPtr("rack-a")},
+			{NodeID: 2, Host: "b2", Port: 9093, Rack: nil},
+		},
+		Topics: kadm.TopicDetails{
+			"orders": {
+				Topic: "orders",
+				Partitions: kadm.PartitionDetails{
+					1: {Topic: "orders", Partition: 1, Leader: -1, Replicas: []int32{2, 1}},
+					0: {Topic: "orders", Partition: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1, 2}},
+				},
+			},
+			"__consumer_offsets": {Topic: "__consumer_offsets", IsInternal: true},
+		},
+	}
+
+	cluster := FromFranz(metadata)
+
+	assert.Equal(t, "cluster-1", cluster.ID)
+	assert.Equal(t, []topology.Broker{
+		{ID: 1, Host: "b1", Port: 9092, Rack: "rack-a"},
+		{ID: 2, Host: "b2", Port: 9093},
+	}, cluster.Brokers)
+	assert.Equal(t, []int32{2}, cluster.BrokersWithoutRack())
+
+	require.Len(t, cluster.Topics, 2)
+	assert.True(t, cluster.Topics[0].Internal)
+
+	topic, ok := cluster.Topic("orders")
+	require.True(t, ok)
+	require.Len(t, topic.Partitions, 2)
+	assert.Equal(t, int32(0), topic.Partitions[0].ID)
+	assert.Equal(t, int32(-1), topic.Partitions[1].Leader)
+	assert.Equal(t, []string{"rack-a"}, cluster.RacksForPartition(topic.Partitions[1]))
+}
+
+func TestFromMetadataResponse(t *testing.T) {
+	resp := kmsg.NewPtrMetadataResponse()
+	resp.ClusterID = strPtr("cluster-1")
+	resp.Brokers = []kmsg.MetadataResponseBroker{
+		{NodeID: 2, Host: "b2", Port: 9093},
+		{NodeID: 1, Host: "b1", Port: 9092, Rack: strPtr("rack-a")},
+	}
+	resp.Topics = []kmsg.MetadataResponseTopic{
+		{Topic: strPtr("orders"), Partitions: []kmsg.MetadataResponseTopicPartition{
+			{Partition: 1, Leader: 2, Replicas: []int32{2, 1}, ISR: []int32{2}},
+			{Partition: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1, 2}},
+		}},
+		{Topic: strPtr("__consumer_offsets"), IsInternal: true},
+	}
+
+	cluster := fromMetadataResponse(resp)
+
+	assert.Equal(t, "cluster-1", cluster.ID)
+	assert.Equal(t, []topology.Broker{
+		{ID: 1, Host: "b1", Port: 9092, Rack: "rack-a"},
+		{ID: 2, Host: "b2", Port: 9093},
+	}, cluster.Brokers)
+
+	require.Len(t, cluster.Topics, 2)
+	assert.True(t, cluster.Topics[0].Internal)
+
+	topic, ok := cluster.Topic("orders")
+	require.True(t, ok)
+	assert.Equal(t, []topology.Partition{
+		{Topic: "orders", ID: 0, Leader: 1, Replicas: []int32{1, 2}, ISR: []int32{1, 2}},
+		{Topic: "orders", ID: 1, Leader: 2, Replicas: []int32{2, 1}, ISR: []int32{2}},
+	}, topic.Partitions)
+}
+
+var (
+	_ topology.MetadataSource = (*KafkaGo)(nil)
+	_ topology.MetadataSource = (*Franz)(nil)
+)
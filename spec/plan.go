@@ -0,0 +1,203 @@
+package spec
+
+import (
+	"fmt"
+	"sort"
+
+	"kafka-rack-awareness/planner"
+	"kafka-rack-awareness/topology"
+)
+
+// Creation is a topic to create with an explicit replica assignment.
+type Creation struct {
+	Topic      Topic
+	Assignment planner.Assignment
+}
+
+// Addition grows a topic from From to To partitions. Replicas[i] is the
+// replica list of partition From+i.
+type Addition struct {
+	Topic    string
+	From, To int
+	Replicas [][]int32
+}
+
+// ConfigChange sets one topic config. From is the current value, or ""
+// if the snapshot has none.
+type ConfigChange struct {
+	Topic string
+	Key   string
+	From  string
+	To    string
+}
+
+// Plan is what it takes to bring a cluster in line with a spec. Apply it in
+// field order: reassignments before additions, because Kafka requires new
+// partitions to have the replication factor the existing ones already have.
+type Plan struct {
+	Creations []Creation
+	Configs   []ConfigChange
+	Moves     []planner.Move
+	Additions []Addition
+}
+
+// Len returns the number of changes in the plan.
+func (p *Plan) Len() int {
+	return len(p.Creations) + len(p.Configs) + len(p.Moves) + len(p.Additions)
+}
+
+// ConfigKeys returns every config key the spec sets, sorted, so callers can
+// load just those into a snapshot before calling Diff.
+func (f *File) ConfigKeys() []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, t := range f.Topics {
+		for k := range t.Configs {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Option configures Diff.
+type Option func(*config)
+
+type config struct {
+	hierarchy *topology.Hierarchy
+}
+
+// WithHierarchy plans new topics and partitions, and reshapes existing
+// ones, over a rack hierarchy, as planner.WithHierarchy does.
+func WithHierarchy(h *topology.Hierarchy) Option {
+	return func(c *config) { c.hierarchy = h }
+}
+
+// Diff compares the spec against a snapshot. The snapshot's topics must
+// carry their configs (see source.TopicConfigs) for config changes to be
+// detected; a config missing from the snapshot counts as a change.
+//
+// New topics and partitions are assigned by the planner, balanced against
+// everything already placed. Existing partitions that have the wrong
+// replication factor, sit outside placement.racks or span too few racks are
+// reassigned with planner.Reshape.
+func Diff(c *topology.Cluster, f *File, opts ...Option) (*Plan, error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	plan := &Plan{}
+	projected := topology.NewCluster(c.Brokers...)
+	for _, t := range c.Topics {
+		projected.AddTopic(t)
+	}
+
+	for _, t := range f.Topics {
+		brokers, err := eligible(c, t)
+		if err != nil {
+			return nil, err
+		}
+		popts := []planner.Option{planner.WithLoad(planner.LoadOf(projected))}
+		if cfg.hierarchy != nil {
+			popts = append(popts, planner.WithHierarchy(cfg.hierarchy))
+		}
+		p, err := planner.New(brokers, popts...)
+		if err != nil {
+			return nil, fmt.Errorf("topic %q: %w", t.Name, err)
+		}
+
+		current, ok := c.Topic(t.Name)
+		if !ok {
+			if racks := domains(brokers); t.Placement.MinRacks > min(t.ReplicationFactor, racks) {
+				return nil, fmt.Errorf("topic %q: %d replica(s) on %d rack(s) cannot span %d racks",
+					t.Name, t.ReplicationFactor, racks, t.Placement.MinRacks)
+			}
+			a, err := p.Assign(planner.Request{Topic: t.Name, Partitions: t.Partitions, ReplicationFactor: t.ReplicationFactor})
+			if err != nil {
+				return nil, fmt.Errorf("topic %q: %w", t.Name, err)
+			}
+			plan.Creations = append(plan.Creations, Creation{Topic: t, Assignment: a})
+			projected.AddTopic(a.AsTopic())
+			continue
+		}
+
+		have := len(current.Partitions)
+		if t.Partitions < have {
+			return nil, fmt.Errorf("topic %q has %d partitions; Kafka cannot reduce them to %d", t.Name, have, t.Partitions)
+		}
+
+		for _, k := range sortedKeys(t.Configs) {
+			if v, ok := current.Configs[k]; !ok || v != t.Configs[k] {
+				plan.Configs = append(plan.Configs, ConfigChange{Topic: t.Name, Key: k, From: v, To: t.Configs[k]})
+			}
+		}
+
+		moves, err := p.Reshape(c, t.Name, t.ReplicationFactor, t.Placement.MinRacks)
+		if err != nil {
+			return nil, fmt.Errorf("topic %q: %w", t.Name, err)
+		}
+		plan.Moves = append(plan.Moves, moves...)
+
+		if t.Partitions > have {
+			a, err := p.Assign(planner.Request{Topic: t.Name, Partitions: t.Partitions - have, ReplicationFactor: t.ReplicationFactor})
+			if err != nil {
+				return nil, fmt.Errorf("topic %q: %w", t.Name, err)
+			}
+			plan.Additions = append(plan.Additions, Addition{Topic: t.Name, From: have, To: t.Partitions, Replicas: a.Replicas})
+		}
+	}
+	return plan, nil
+}
+
+// eligible returns the brokers a topic may use.
+func eligible(c *topology.Cluster, t Topic) ([]topology.Broker, error) {
+	if len(t.Placement.Racks) == 0 {
+		return c.Brokers, nil
+	}
+	allowed := make(map[string]bool, len(t.Placement.Racks))
+	for _, rack := range t.Placement.Racks {
+		allowed[rack] = true
+	}
+	var brokers []topology.Broker
+	used := make(map[string]bool)
+	for _, b := range c.Brokers {
+		if allowed[b.Rack] {
+			brokers = append(brokers, b)
+			used[b.Rack] = true
+		}
+	}
+	for _, rack := range t.Placement.Racks {
+		if !used[rack] {
+			return nil, fmt.Errorf("topic %q: no brokers in rack %q", t.Name, rack)
+		}
+	}
+	return brokers, nil
+}
+
+// domains counts the failure domains among brokers: racks, plus one per
+// broker without a rack.
+func domains(brokers []topology.Broker) int {
+	racks := make(map[string]bool)
+	n := 0
+	for _, b := range brokers {
+		if !b.HasRack() {
+			n++
+		} else if !racks[b.Rack] {
+			racks[b.Rack] = true
+			n++
+		}
+	}
+	return n
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
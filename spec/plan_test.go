@@ -0,0 +1,107 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+
+	"kafka-rack-awareness/topology"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cluster() *topology.Cluster {
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "rack-a"},
+		topology.Broker{ID: 2, Rack: "rack-b"},
+		topology.Broker{ID: 3, Rack: "rack-c"},
+		topology.Broker{ID: 4, Rack: "rack-d"},
+	)
+	c.AddTopic(topology.Topic{
+		Name:    "orders",
+		Configs: map[string]string{"min.insync.replicas": "1", "retention.ms": "604800000"},
+		Partitions: []topology.Partition{
+			{ID: 0, Leader: 1, Replicas: []int32{1, 2}},
+			{ID: 1, Leader: 2, Replicas: []int32{2, 4}},
+		},
+	})
+	return c
+}
+
+func TestDiff(t *testing.T) {
+	f, err := Read(strings.NewReader(ordersSpec))
+	require.NoError(t, err)
+	plan, err := Diff(cluster(), f)
+	require.NoError(t, err)
+
+	require.Len(t, plan.Creations, 1)
+	assert.Equal(t, "audit-log", plan.Creations[0].Topic.Name)
+	assert.Len(t, plan.Creations[0].Assignment.Replicas, 1)
+
+	assert.Equal(t, []ConfigChange{{Topic: "orders", Key: "min.insync.replicas", From: "1", To: "2"}}, plan.Configs)
+
+	// Both partitions go to RF 3 on racks a-c; partition 1 also leaves
+	// rack-d.
+	require.Len(t, plan.Moves, 2)
+	c := cluster()
+	for _, m := range plan.Moves {
+		assert.Len(t, m.To, 3)
+		assert.Equal(t, []string{"rack-a", "rack-b", "rack-c"}, topology.RacksForBrokers(c.BrokerRacks(), m.To))
+	}
+	assert.Equal(t, []int32{1, 2}, plan.Moves[0].To[:2], "kept replicas stay in order")
+
+	require.Len(t, plan.Additions, 1)
+	assert.Equal(t, 2, plan.Additions[0].From)
+	assert.Equal(t, 6, plan.Additions[0].To)
+	require.Len(t, plan.Additions[0].Replicas, 4)
+	for _, replicas := range plan.Additions[0].Replicas {
+		assert.NotContains(t, replicas, int32(4))
+	}
+	assert.Equal(t, 5, plan.Len())
+}
+
+func TestDiffNoChanges(t *testing.T) {
+	f := &File{Topics: []Topic{{Name: "orders", Partitions: 2, ReplicationFactor: 2, Configs: map[string]string{"retention.ms": "604800000"}}}}
+	plan, err := Diff(cluster(), f)
+	require.NoError(t, err)
+	assert.Zero(t, plan.Len())
+}
+
+func TestDiffWithHierarchy(t *testing.T) {
+	// dc1 has three racks and dc2 one; over the hierarchy every partition
+	// of RF 2 gets a replica in dc2.
+	c := topology.NewCluster(
+		topology.Broker{ID: 1, Rack: "dc1-a"},
+		topology.Broker{ID: 2, Rack: "dc1-b"},
+		topology.Broker{ID: 3, Rack: "dc1-c"},
+		topology.Broker{ID: 4, Rack: "dc2-a"},
+	)
+	h, err := topology.SplitHierarchy("-", "dc", "rack")
+	require.NoError(t, err)
+	f := &File{Topics: []Topic{{Name: "orders", Partitions: 3, ReplicationFactor: 2}}}
+
+	plan, err := Diff(c, f, WithHierarchy(h))
+	require.NoError(t, err)
+	require.Len(t, plan.Creations, 1)
+	for _, replicas := range plan.Creations[0].Assignment.Replicas {
+		assert.Contains(t, replicas, int32(4))
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	tests := map[string]struct {
+		topic Topic
+		want  string
+	}{
+		"shrink":       {Topic{Name: "orders", Partitions: 1, ReplicationFactor: 2}, `topic "orders" has 2 partitions; Kafka cannot reduce them to 1`},
+		"unknown rack": {Topic{Name: "t", Partitions: 1, ReplicationFactor: 1, Placement: Placement{Racks: []string{"rack-z"}}}, `topic "t": no brokers in rack "rack-z"`},
+		"spread":       {Topic{Name: "t", Partitions: 1, ReplicationFactor: 2, Placement: Placement{Racks: []string{"rack-a"}, MinRacks: 2}}, `topic "t": 2 replica(s) on 1 rack(s) cannot span 2 racks`},
+		"rf":           {Topic{Name: "t", Partitions: 1, ReplicationFactor: 5}, `topic "t": replication factor exceeds broker count: RF 5, 4 broker(s)`},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Diff(cluster(), &File{Topics: []Topic{tt.topic}})
+			assert.EqualError(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Package spec reads declarative topic specs and diffs them against a
+// cluster snapshot.
+//
+// A spec file lists the topics a cluster should have:
+//
+//	topics:
+//	  - name: orders
+//	    partitions: 6
+//	    replicationFactor: 3
+//	    configs:
+//	      min.insync.replicas: "2"
+//	    placement:
+//	      racks: [rack-a, rack-b, rack-c]
+//	      minRacks: 3
+//
+// Diff turns a spec into a Plan of topic creations, partition additions,
+// config changes and rack-aware reassignments. Topics that exist on the
+// cluster but not in the spec are left alone, as are configs the spec does
+// not mention.
+package spec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a parsed spec file.
+type File struct {
+	Topics []Topic `yaml:"topics"`
+}
+
+// Topic is the desired state of one topic.
+type Topic struct {
+	Name              string            `yaml:"name"`
+	Partitions        int               `yaml:"partitions"`
+	ReplicationFactor int               `yaml:"replicationFactor"`
+	Configs           map[string]string `yaml:"configs,omitempty"`
+	Placement         Placement         `yaml:"placement,omitempty"`
+}
+
+// Placement constrains where a topic's replicas may live.
+type Placement struct {
+	// Racks lists the racks replicas may be placed in; empty means any.
+	Racks []string `yaml:"racks,omitempty"`
+	// MinRacks is how many racks every partition must span. 0 means
+	// min(replicationFactor, racks), as many as possible.
+	MinRacks int `yaml:"minRacks,omitempty"`
+}
+
+// Read parses and validates a spec. Unknown fields are rejected so typos
+// such as "replicationfactor" do not silently fall back to defaults.
+func Read(r io.Reader) (*File, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var f File
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing topic spec: %w", err)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// ReadFile parses and validates a spec file.
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Validate checks the spec for mistakes that do not need a cluster to
+// detect.
+func (f *File) Validate() error {
+	seen := make(map[string]bool, len(f.Topics))
+	for i, t := range f.Topics {
+		if t.Name == "" {
+			return fmt.Errorf("topic %d has no name", i+1)
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("topic %q is listed twice", t.Name)
+		}
+		seen[t.Name] = true
+		if t.Partitions <= 0 {
+			return fmt.Errorf("topic %q: partitions must be positive, got %d", t.Name, t.Partitions)
+		}
+		if t.ReplicationFactor <= 0 {
+			return fmt.Errorf("topic %q: replicationFactor must be positive, got %d", t.Name, t.ReplicationFactor)
+		}
+		if t.Placement.MinRacks < 0 || t.Placement.MinRacks > t.ReplicationFactor {
+			return fmt.Errorf("topic %q: minRacks must be between 0 and replicationFactor %d, got %d",
+				t.Name, t.ReplicationFactor, t.Placement.MinRacks)
+		}
+	}
+	return nil
+}
+
+// Names returns the topic names in spec order.
+func (f *File) Names() []string {
+	names := make([]string, len(f.Topics))
+	for i, t := range f.Topics {
+		names[i] = t.Name
+	}
+	return names
+}
@@ -0,0 +1,63 @@
+package spec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ordersSpec = `
+topics:
+  - name: orders
+    partitions: 6
+    replicationFactor: 3
+    configs:
+      min.insync.replicas: "2"
+    placement:
+      racks: [rack-a, rack-b, rack-c]
+      minRacks: 3
+  - name: audit-log
+    partitions: 1
+    replicationFactor: 2
+`
+
+func TestRead(t *testing.T) {
+	f, err := Read(strings.NewReader(ordersSpec))
+	require.NoError(t, err)
+	require.Len(t, f.Topics, 2)
+	assert.Equal(t, Topic{
+		Name:              "orders",
+		Partitions:        6,
+		ReplicationFactor: 3,
+		Configs:           map[string]string{"min.insync.replicas": "2"},
+		Placement:         Placement{Racks: []string{"rack-a", "rack-b", "rack-c"}, MinRacks: 3},
+	}, f.Topics[0])
+	assert.Equal(t, []string{"orders", "audit-log"}, f.Names())
+	assert.Equal(t, []string{"min.insync.replicas"}, f.ConfigKeys())
+
+	empty, err := Read(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Empty(t, empty.Topics)
+}
+
+func TestReadRejects(t *testing.T) {
+	tests := map[string]struct {
+		spec string
+		want string
+	}{
+		"unknown field": {"topics:\n  - name: a\n    partitions: 1\n    replicationfactor: 3\n", "field replicationfactor not found"},
+		"no name":       {"topics:\n  - partitions: 1\n    replicationFactor: 1\n", "topic 1 has no name"},
+		"duplicate":     {"topics:\n  - {name: a, partitions: 1, replicationFactor: 1}\n  - {name: a, partitions: 1, replicationFactor: 1}\n", `topic "a" is listed twice`},
+		"partitions":    {"topics:\n  - {name: a, replicationFactor: 1}\n", `topic "a": partitions must be positive, got 0`},
+		"rf":            {"topics:\n  - {name: a, partitions: 1}\n", `topic "a": replicationFactor must be positive, got 0`},
+		"min racks":     {"topics:\n  - {name: a, partitions: 1, replicationFactor: 2, placement: {minRacks: 3}}\n", "minRacks must be between 0 and replicationFactor 2, got 3"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(tt.spec))
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
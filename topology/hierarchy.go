@@ -0,0 +1,136 @@
+package topology
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Hierarchy splits hierarchical rack names such as "dc1-rackA" into levels,
+// outermost first, so spread can be checked per datacenter or zone as well
+// as per rack (Edge Case 6 in docs/KAFKA_RACK_AWARENESS.md).
+type Hierarchy struct {
+	levels []string
+	// split returns the level values of a rack, or nil if it does not fit.
+	split func(rack string) []string
+}
+
+// SplitHierarchy returns a hierarchy whose levels are separated by delim,
+// for example SplitHierarchy("-", "dc", "rack") for "dc1-rackA". The last
+// level keeps any further delimiters, so "dc1-rack-a" is rack "rack-a".
+func SplitHierarchy(delim string, levels ...string) (*Hierarchy, error) {
+	if delim == "" {
+		return nil, fmt.Errorf("empty rack delimiter")
+	}
+	if len(levels) < 2 {
+		return nil, fmt.Errorf("a rack hierarchy needs at least two levels, got %d", len(levels))
+	}
+	return &Hierarchy{
+		levels: append([]string(nil), levels...),
+		split: func(rack string) []string {
+			return nonEmpty(strings.SplitN(rack, delim, len(levels)), len(levels))
+		},
+	}, nil
+}
+
+// RegexpHierarchy returns a hierarchy whose levels are the named groups of
+// pattern, for example `^(?P<region>[a-z]+-[a-z]+-\d)(?P<zone>[a-z])-(?P<rack>.+)$`.
+// Every capturing group must be named.
+func RegexpHierarchy(pattern string) (*Hierarchy, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("rack pattern: %w", err)
+	}
+	levels := re.SubexpNames()[1:]
+	for i, name := range levels {
+		if name == "" {
+			return nil, fmt.Errorf("rack pattern group %d has no name; use (?P<name>...)", i+1)
+		}
+	}
+	if len(levels) < 2 {
+		return nil, fmt.Errorf("a rack hierarchy needs at least two levels, got %d", len(levels))
+	}
+	return &Hierarchy{
+		levels: levels,
+		split: func(rack string) []string {
+			m := re.FindStringSubmatch(rack)
+			if m == nil {
+				return nil
+			}
+			return nonEmpty(m[1:], len(levels))
+		},
+	}, nil
+}
+
+func nonEmpty(parts []string, n int) []string {
+	if len(parts) != n {
+		return nil
+	}
+	for _, p := range parts {
+		if p == "" {
+			return nil
+		}
+	}
+	return parts
+}
+
+// Levels returns the level names, outermost first.
+func (h *Hierarchy) Levels() []string {
+	return append([]string(nil), h.levels...)
+}
+
+func (h *Hierarchy) String() string {
+	return strings.Join(h.levels, "/")
+}
+
+// Parse returns the level values of a rack name, outermost first.
+func (h *Hierarchy) Parse(rack string) ([]string, error) {
+	parts := h.split(rack)
+	if parts == nil {
+		return nil, fmt.Errorf("rack %q does not match the %s hierarchy", rack, h)
+	}
+	return parts, nil
+}
+
+// Domains returns the failure domain a rack belongs to at each level. A
+// domain is named by the rack's levels down to that one joined with "/", so
+// rack-1 in dc1 and rack-1 in dc2 are different domains. The innermost
+// domain is the rack name itself.
+func (h *Hierarchy) Domains(rack string) ([]string, error) {
+	parts, err := h.Parse(rack)
+	if err != nil {
+		return nil, err
+	}
+	domains := make([]string, len(parts))
+	for i := range parts {
+		domains[i] = strings.Join(parts[:i+1], "/")
+	}
+	domains[len(domains)-1] = rack
+	return domains, nil
+}
+
+// Check returns an error naming the first broker whose rack does not fit
+// the hierarchy. Brokers without a rack are ignored.
+func (h *Hierarchy) Check(c *Cluster) error {
+	for _, b := range c.Brokers {
+		if !b.HasRack() {
+			continue
+		}
+		if _, err := h.Parse(b.Rack); err != nil {
+			return fmt.Errorf("broker %d: %w", b.ID, err)
+		}
+	}
+	return nil
+}
+
+// BrokerDomains maps every broker whose rack fits the hierarchy to its
+// domain at the given level, in the form RacksForBrokers expects.
+func (h *Hierarchy) BrokerDomains(c *Cluster, level int) map[int32]string {
+	domains := make(map[int32]string, len(c.Brokers))
+	for id, rack := range c.BrokerRacks() {
+		if ds, err := h.Domains(rack); err == nil {
+			domains[id] = ds[level]
+		}
+	}
+	return domains
+}
@@ -0,0 +1,69 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitHierarchy(t *testing.T) {
+	h, err := SplitHierarchy("-", "dc", "rack")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dc", "rack"}, h.Levels())
+
+	parts, err := h.Parse("dc1-rack-a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dc1", "rack-a"}, parts)
+
+	domains, err := h.Domains("dc1-rack-a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dc1", "dc1-rack-a"}, domains)
+
+	_, err = h.Parse("rackA")
+	assert.EqualError(t, err, `rack "rackA" does not match the dc/rack hierarchy`)
+	_, err = h.Parse("dc1-")
+	assert.Error(t, err)
+
+	_, err = SplitHierarchy("", "dc", "rack")
+	assert.EqualError(t, err, "empty rack delimiter")
+	_, err = SplitHierarchy("-", "rack")
+	assert.EqualError(t, err, "a rack hierarchy needs at least two levels, got 1")
+}
+
+func TestRegexpHierarchy(t *testing.T) {
+	h, err := RegexpHierarchy(`^(?P<region>[a-z]+-[a-z]+-\d)(?P<zone>[a-z])(?:-(?P<rack>.+))$`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"region", "zone", "rack"}, h.Levels())
+
+	domains, err := h.Domains("eu-west-1a-r12")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eu-west-1", "eu-west-1/a", "eu-west-1a-r12"}, domains)
+
+	_, err = h.Parse("eu-west-1a")
+	assert.Error(t, err)
+
+	_, err = RegexpHierarchy(`^(\w+)-(?P<rack>\w+)$`)
+	assert.EqualError(t, err, "rack pattern group 1 has no name; use (?P<name>...)")
+	_, err = RegexpHierarchy(`(`)
+	assert.ErrorContains(t, err, "rack pattern: ")
+}
+
+func TestHierarchyBrokerDomains(t *testing.T) {
+	c := NewCluster(
+		Broker{ID: 1, Rack: "dc1-a"},
+		Broker{ID: 2, Rack: "dc1-b"},
+		Broker{ID: 3, Rack: "dc2-a"},
+		Broker{ID: 4},
+	)
+	h, err := SplitHierarchy("-", "dc", "rack")
+	require.NoError(t, err)
+	require.NoError(t, h.Check(c))
+
+	assert.Equal(t, map[int32]string{1: "dc1", 2: "dc1", 3: "dc2"}, h.BrokerDomains(c, 0))
+	assert.Equal(t, c.BrokerRacks(), h.BrokerDomains(c, 1))
+	assert.Equal(t, []string{"dc1", "dc2"}, RacksForBrokers(h.BrokerDomains(c, 0), []int32{1, 2, 3, 4}))
+
+	c.Brokers = append(c.Brokers, Broker{ID: 5, Rack: "legacy"})
+	assert.EqualError(t, h.Check(c), `broker 5: rack "legacy" does not match the dc/rack hierarchy`)
+}
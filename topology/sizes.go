@@ -0,0 +1,37 @@
+package topology
+
+// ReplicaSizes is the on-disk size in bytes of replicas, keyed by broker,
+// topic and partition, as reported by DescribeLogDirs. Replicas that were
+// not described are simply absent.
+type ReplicaSizes map[int32]map[string]map[int32]int64
+
+// Set records the size of a replica.
+func (s ReplicaSizes) Set(broker int32, topic string, partition int32, bytes int64) {
+	if s[broker] == nil {
+		s[broker] = make(map[string]map[int32]int64)
+	}
+	if s[broker][topic] == nil {
+		s[broker][topic] = make(map[int32]int64)
+	}
+	s[broker][topic][partition] = bytes
+}
+
+// Size returns the size of a replica and whether it is known.
+func (s ReplicaSizes) Size(broker int32, topic string, partition int32) (int64, bool) {
+	bytes, ok := s[broker][topic][partition]
+	return bytes, ok
+}
+
+// PartitionSize returns the size of the largest known replica of a
+// partition on any broker, which is what a new replica will have to copy.
+// Brokers that no longer hold the partition count too, so the size survives
+// projecting a plan that moves every replica.
+func (s ReplicaSizes) PartitionSize(p Partition) int64 {
+	var largest int64
+	for _, topics := range s {
+		if bytes, ok := topics[p.Topic][p.ID]; ok && bytes > largest {
+			largest = bytes
+		}
+	}
+	return largest
+}
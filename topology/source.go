@@ -0,0 +1,11 @@
+package topology
+
+import "context"
+
+// MetadataSource produces cluster snapshots from a live cluster. Adapters for
+// the supported Kafka clients live in the source package.
+type MetadataSource interface {
+	// Snapshot returns the brokers of the cluster and the given topics, or
+	// every topic if none are given.
+	Snapshot(ctx context.Context, topics ...string) (*Cluster, error)
+}
@@ -0,0 +1,225 @@
+// Package topology models the broker, rack and partition layout of a Kafka
+// cluster and provides the rack-spread checks used by the test suite.
+//
+// The types are client-agnostic: broker IDs are int32 as on the wire, and a
+// broker without a configured rack has an empty Rack string.
+package topology
+
+import (
+	"sort"
+)
+
+// Broker is a single Kafka broker and the rack it was configured with.
+type Broker struct {
+	ID   int32
+	Host string
+	Port int32
+	// Rack is the broker.rack setting, or "" if the broker has none.
+	Rack string
+}
+
+// HasRack reports whether the broker has a rack configured.
+func (b Broker) HasRack() bool {
+	return b.Rack != ""
+}
+
+// Rack is a named failure domain and the brokers that live in it.
+type Rack struct {
+	Name    string
+	Brokers []int32
+}
+
+// Partition is the replica layout of one topic partition.
+type Partition struct {
+	Topic string
+	ID    int32
+	// Leader is the current leader broker, or -1 if the partition is offline.
+	Leader   int32
+	Replicas []int32
+	ISR      []int32
+}
+
+// Topic is a topic and its partitions, ordered by partition ID.
+type Topic struct {
+	Name       string
+	Internal   bool
+	Partitions []Partition
+	Configs    map[string]string
+}
+
+// Cluster is a point-in-time view of brokers and topics.
+type Cluster struct {
+	// ID is the cluster ID reported by the brokers, if any.
+	ID      string
+	Brokers []Broker
+	Topics  []Topic
+}
+
+// NewCluster returns a cluster containing the given brokers, sorted by ID.
+func NewCluster(brokers ...Broker) *Cluster {
+	c := &Cluster{Brokers: append([]Broker(nil), brokers...)}
+	sort.Slice(c.Brokers, func(i, j int) bool { return c.Brokers[i].ID < c.Brokers[j].ID })
+	return c
+}
+
+// AddTopic adds or replaces a topic. Partitions are sorted by ID.
+func (c *Cluster) AddTopic(t Topic) {
+	sort.Slice(t.Partitions, func(i, j int) bool { return t.Partitions[i].ID < t.Partitions[j].ID })
+	for i := range t.Partitions {
+		t.Partitions[i].Topic = t.Name
+	}
+	for i := range c.Topics {
+		if c.Topics[i].Name == t.Name {
+			c.Topics[i] = t
+			return
+		}
+	}
+	c.Topics = append(c.Topics, t)
+	sort.Slice(c.Topics, func(i, j int) bool { return c.Topics[i].Name < c.Topics[j].Name })
+}
+
+// Topic returns the named topic.
+func (c *Cluster) Topic(name string) (*Topic, bool) {
+	for i := range c.Topics {
+		if c.Topics[i].Name == name {
+			return &c.Topics[i], true
+		}
+	}
+	return nil, false
+}
+
+// Broker returns the broker with the given ID.
+func (c *Cluster) Broker(id int32) (Broker, bool) {
+	for _, b := range c.Brokers {
+		if b.ID == id {
+			return b, true
+		}
+	}
+	return Broker{}, false
+}
+
+// BrokerIDs returns all broker IDs in ascending order.
+func (c *Cluster) BrokerIDs() []int32 {
+	ids := make([]int32, 0, len(c.Brokers))
+	for _, b := range c.Brokers {
+		ids = append(ids, b.ID)
+	}
+	return ids
+}
+
+// RackOf returns the rack of a broker, or "" if the broker is unknown or has
+// no rack.
+func (c *Cluster) RackOf(id int32) string {
+	b, _ := c.Broker(id)
+	return b.Rack
+}
+
+// BrokerRacks returns the broker ID to rack mapping for every broker that has
+// a rack configured.
+func (c *Cluster) BrokerRacks() map[int32]string {
+	racks := make(map[int32]string, len(c.Brokers))
+	for _, b := range c.Brokers {
+		if b.HasRack() {
+			racks[b.ID] = b.Rack
+		}
+	}
+	return racks
+}
+
+// Racks returns every configured rack with its brokers, sorted by name.
+// Brokers without a rack are not part of any rack.
+func (c *Cluster) Racks() []Rack {
+	byName := make(map[string][]int32)
+	for _, b := range c.Brokers {
+		if b.HasRack() {
+			byName[b.Rack] = append(byName[b.Rack], b.ID)
+		}
+	}
+	racks := make([]Rack, 0, len(byName))
+	for name, ids := range byName {
+		racks = append(racks, Rack{Name: name, Brokers: ids})
+	}
+	sort.Slice(racks, func(i, j int) bool { return racks[i].Name < racks[j].Name })
+	return racks
+}
+
+// RackNames returns the names of all configured racks, sorted.
+func (c *Cluster) RackNames() []string {
+	racks := c.Racks()
+	names := make([]string, 0, len(racks))
+	for _, r := range racks {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// BrokersWithoutRack returns the IDs of brokers that have no rack configured.
+func (c *Cluster) BrokersWithoutRack() []int32 {
+	var ids []int32
+	for _, b := range c.Brokers {
+		if !b.HasRack() {
+			ids = append(ids, b.ID)
+		}
+	}
+	return ids
+}
+
+// RacksForPartition returns the distinct racks holding a replica of p,
+// sorted. Replicas on unknown brokers or brokers without a rack are ignored.
+func (c *Cluster) RacksForPartition(p Partition) []string {
+	return RacksForBrokers(c.BrokerRacks(), p.Replicas)
+}
+
+// RacksForISR returns the distinct racks holding an in-sync replica of p.
+func (c *Cluster) RacksForISR(p Partition) []string {
+	return RacksForBrokers(c.BrokerRacks(), p.ISR)
+}
+
+// RackSpread returns the number of distinct racks holding a replica of p.
+func (c *Cluster) RackSpread(p Partition) int {
+	return len(c.RacksForPartition(p))
+}
+
+// LeadersByRack counts partition leaders per rack. Leaders on brokers without
+// a rack, and offline partitions, are not counted.
+func (c *Cluster) LeadersByRack(partitions []Partition) map[string]int {
+	racks := c.BrokerRacks()
+	counts := make(map[string]int)
+	for _, p := range partitions {
+		if rack, ok := racks[p.Leader]; ok {
+			counts[rack]++
+		}
+	}
+	return counts
+}
+
+// ReplicasByRack counts replicas per rack across the given partitions.
+func (c *Cluster) ReplicasByRack(partitions []Partition) map[string]int {
+	racks := c.BrokerRacks()
+	counts := make(map[string]int)
+	for _, p := range partitions {
+		for _, id := range p.Replicas {
+			if rack, ok := racks[id]; ok {
+				counts[rack]++
+			}
+		}
+	}
+	return counts
+}
+
+// RacksForBrokers maps broker IDs to their distinct racks, sorted. IDs that
+// are missing from brokerRacks are ignored.
+func RacksForBrokers(brokerRacks map[int32]string, ids []int32) []string {
+	seen := make(map[string]bool, len(ids))
+	racks := make([]string, 0, len(ids))
+	for _, id := range ids {
+		rack, ok := brokerRacks[id]
+		if !ok || rack == "" || seen[rack] {
+			continue
+		}
+		seen[rack] = true
+		racks = append(racks, rack)
+	}
+	sort.Strings(racks)
+	return racks
+}
@@ -0,0 +1,117 @@
+package topology
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func threeRackCluster() *Cluster {
+	return NewCluster(
+		Broker{ID: 3, Rack: "rack-c"},
+		Broker{ID: 1, Rack: "rack-a"},
+		Broker{ID: 2, Rack: "rack-b"},
+	)
+}
+
+func TestNewClusterSortsBrokers(t *testing.T) {
+	c := threeRackCluster()
+	assert.Equal(t, []int32{1, 2, 3}, c.BrokerIDs())
+}
+
+func TestRacks(t *testing.T) {
+	c := NewCluster(
+		Broker{ID: 1, Rack: "rack-a"},
+		Broker{ID: 2, Rack: "rack-a"},
+		Broker{ID: 3, Rack: "rack-b"},
+		Broker{ID: 4},
+	)
+
+	racks := c.Racks()
+	require.Len(t, racks, 2)
+	assert.Equal(t, Rack{Name: "rack-a", Brokers: []int32{1, 2}}, racks[0])
+	assert.Equal(t, Rack{Name: "rack-b", Brokers: []int32{3}}, racks[1])
+	assert.Equal(t, []string{"rack-a", "rack-b"}, c.RackNames())
+	assert.Equal(t, []int32{4}, c.BrokersWithoutRack())
+	assert.Equal(t, map[int32]string{1: "rack-a", 2: "rack-a", 3: "rack-b"}, c.BrokerRacks())
+}
+
+func TestRacksForPartition(t *testing.T) {
+	c := NewCluster(
+		Broker{ID: 1, Rack: "rack-a"},
+		Broker{ID: 2, Rack: "rack-b"},
+		Broker{ID: 3, Rack: "rack-b"},
+		Broker{ID: 4},
+	)
+
+	tests := []struct {
+		name     string
+		replicas []int32
+		want     []string
+	}{
+		{"distinct racks", []int32{2, 1}, []string{"rack-a", "rack-b"}},
+		{"shared rack", []int32{2, 3}, []string{"rack-b"}},
+		{"broker without rack", []int32{1, 4}, []string{"rack-a"}},
+		{"unknown broker", []int32{1, 99}, []string{"rack-a"}},
+		{"no replicas", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Partition{Replicas: tt.replicas}
+			assert.Equal(t, tt.want, c.RacksForPartition(p))
+			assert.Equal(t, len(tt.want), c.RackSpread(p))
+		})
+	}
+}
+
+func TestLeadersAndReplicasByRack(t *testing.T) {
+	c := threeRackCluster()
+	partitions := []Partition{
+		{ID: 0, Leader: 1, Replicas: []int32{1, 2, 3}},
+		{ID: 1, Leader: 2, Replicas: []int32{2, 3, 1}},
+		{ID: 2, Leader: 1, Replicas: []int32{1, 3}},
+		{ID: 3, Leader: -1, Replicas: []int32{3}},
+	}
+
+	assert.Equal(t, map[string]int{"rack-a": 2, "rack-b": 1}, c.LeadersByRack(partitions))
+	assert.Equal(t, map[string]int{"rack-a": 3, "rack-b": 2, "rack-c": 4}, c.ReplicasByRack(partitions))
+}
+
+func TestAddTopic(t *testing.T) {
+	c := threeRackCluster()
+	c.AddTopic(Topic{Name: "b", Partitions: []Partition{{ID: 1}, {ID: 0}}})
+	c.AddTopic(Topic{Name: "a"})
+
+	require.Len(t, c.Topics, 2)
+	assert.Equal(t, "a", c.Topics[0].Name)
+
+	topic, ok := c.Topic("b")
+	require.True(t, ok)
+	assert.Equal(t, int32(0), topic.Partitions[0].ID)
+	assert.Equal(t, "b", topic.Partitions[0].Topic)
+
+	c.AddTopic(Topic{Name: "b"})
+	topic, _ = c.Topic("b")
+	assert.Empty(t, topic.Partitions)
+
+	_, ok = c.Topic("missing")
+	assert.False(t, ok)
+}
+
+func TestReplicaSizes(t *testing.T) {
+	sizes := make(ReplicaSizes)
+	sizes.Set(1, "orders", 0, 300)
+	sizes.Set(2, "orders", 0, 500)
+
+	got, ok := sizes.Size(1, "orders", 0)
+	assert.True(t, ok)
+	assert.Equal(t, int64(300), got)
+	_, ok = sizes.Size(3, "orders", 0)
+	assert.False(t, ok)
+
+	assert.Equal(t, int64(500), sizes.PartitionSize(Partition{Topic: "orders", ID: 0, Replicas: []int32{1, 2, 3}}))
+
+	var none ReplicaSizes
+	assert.Zero(t, none.PartitionSize(Partition{Topic: "orders", Replicas: []int32{1}}))
+}